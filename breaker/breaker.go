@@ -0,0 +1,355 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pubgo/x/retry"
+	"github.com/pubgo/xerror"
+)
+
+var (
+	ErrOpen            = errors.New("circuit breaker is open")
+	ErrTooManyRequests = errors.New("circuit breaker is half-open, too many requests")
+)
+
+// State 熔断器状态
+type State int32
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Counts 滑动窗口内的请求统计
+type Counts struct {
+	Requests             uint32
+	Successes            uint32
+	Failures             uint32
+	ConsecutiveSuccesses uint32
+	ConsecutiveFailures  uint32
+}
+
+type bucket struct {
+	successes uint32
+	failures  uint32
+}
+
+type stateChange struct {
+	from State
+	to   State
+}
+
+// New
+// name: 熔断器名字, 状态变化回调时使用
+// 默认配置: 10s 滑动窗口, 至少 20 个请求并且失败率达到 0.5 或者连续失败 5 次时打开, 冷却 5s, 半开状态允许 1 个请求
+func New(name string, opts ...Option) *Breaker {
+	b := &Breaker{
+		name:           name,
+		window:         time.Second * 10,
+		bucketNum:      10,
+		failureRatio:   0.5,
+		minRequests:    20,
+		maxConsecutive: 5,
+		coolDown:       retry.Constant(time.Second * 5),
+		halfOpenMax:    1,
+		isFailure:      isFailure,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if b.bucketNum < 1 {
+		b.bucketNum = 1
+	}
+
+	if b.halfOpenMax < 1 {
+		b.halfOpenMax = 1
+	}
+
+	b.buckets = make([]bucket, b.bucketNum)
+	b.bucketDur = b.window / time.Duration(b.bucketNum)
+	b.bucketStart = time.Now()
+	b.wait = b.coolDown()
+	return b
+}
+
+// Breaker 熔断器, 关闭 -> 打开 -> 半开 -> 关闭
+// 关闭: 请求正常通过, 滑动窗口内失败率或者连续失败次数达到阈值时打开
+// 打开: 请求直接返回 ErrOpen, 冷却时间之后进入半开
+// 半开: 允许有限的请求通过, 全部成功时关闭, 任何一个失败时重新打开, 冷却时间按照策略递增
+type Breaker struct {
+	name string
+
+	window         time.Duration
+	bucketNum      int
+	failureRatio   float64
+	minRequests    uint32
+	maxConsecutive uint32
+	coolDown       retry.Strategy
+	halfOpenMax    uint32
+	isFailure      func(err error) bool
+	onStateChange  func(name string, from, to State)
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	expiry     time.Time
+	opens      uint
+	wait       func(attempt uint) time.Duration
+
+	buckets     []bucket
+	bucketDur   time.Duration
+	bucketStart time.Time
+	cur         int
+
+	consecutiveSuccesses uint32
+	consecutiveFailures  uint32
+	halfOpenReqs         uint32
+
+	changes []stateChange
+}
+
+// Name 熔断器名字
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State 当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.unlock()
+
+	state, _ := b.current(time.Now())
+	return state
+}
+
+// Counts 滑动窗口内的请求统计
+func (b *Breaker) Counts() Counts {
+	b.mu.Lock()
+	defer b.unlock()
+
+	b.current(time.Now())
+
+	var c = Counts{
+		ConsecutiveSuccesses: b.consecutiveSuccesses,
+		ConsecutiveFailures:  b.consecutiveFailures,
+	}
+	for i := range b.buckets {
+		c.Successes += b.buckets[i].successes
+		c.Failures += b.buckets[i].failures
+	}
+	c.Requests = c.Successes + c.Failures
+	return c
+}
+
+// Allow
+// 判断请求是否可以通过, 可以通过时请求结束之后必须调用 done 报告结果
+func (b *Breaker) Allow() (done func(failed bool), err error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	state, gen := b.current(time.Now())
+	switch state {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.halfOpenReqs >= b.halfOpenMax {
+			return nil, ErrTooManyRequests
+		}
+		b.halfOpenReqs++
+	}
+
+	return func(failed bool) { b.done(gen, failed) }, nil
+}
+
+// Do
+// 通过熔断器执行 fn, fn 的 panic 作为错误处理
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	defer func() { done(b.isFailure(err)) }()
+	defer xerror.RespErr(&err)
+	return fn(ctx)
+}
+
+// Retry
+// 使用 retry.Do 执行 fn, 每次尝试都经过熔断器, 熔断器打开时不再重试
+func (b *Breaker) Retry(ctx context.Context, fn func(ctx context.Context) error, opts ...retry.Option) error {
+	return retry.Do(ctx, func(ctx context.Context) error {
+		err := b.Do(ctx, fn)
+		if IsOpen(err) {
+			return retry.Permanent(err)
+		}
+		return err
+	}, opts...)
+}
+
+// IsOpen 判断是否为熔断器拒绝请求的错误
+func IsOpen(err error) bool {
+	return errors.Is(err, ErrOpen) || errors.Is(err, ErrTooManyRequests)
+}
+
+func isFailure(err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled)
+}
+
+func (b *Breaker) done(gen uint64, failed bool) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	now := time.Now()
+	state, cur := b.current(now)
+	if gen != cur {
+		return
+	}
+
+	if failed {
+		b.onFailure(state, now)
+	} else {
+		b.onSuccess(state, now)
+	}
+}
+
+func (b *Breaker) onSuccess(state State, now time.Time) {
+	b.consecutiveSuccesses++
+	b.consecutiveFailures = 0
+
+	switch state {
+	case StateClosed:
+		b.buckets[b.cur].successes++
+	case StateHalfOpen:
+		if b.consecutiveSuccesses >= b.halfOpenMax {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+func (b *Breaker) onFailure(state State, now time.Time) {
+	b.consecutiveFailures++
+	b.consecutiveSuccesses = 0
+
+	switch state {
+	case StateClosed:
+		b.buckets[b.cur].failures++
+		if b.tripped() {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.setState(StateOpen, now)
+	}
+}
+
+func (b *Breaker) tripped() bool {
+	if b.maxConsecutive > 0 && b.consecutiveFailures >= b.maxConsecutive {
+		return true
+	}
+
+	if b.failureRatio <= 0 {
+		return false
+	}
+
+	var total, failures uint32
+	for i := range b.buckets {
+		total += b.buckets[i].successes + b.buckets[i].failures
+		failures += b.buckets[i].failures
+	}
+	return total >= b.minRequests && float64(failures)/float64(total) >= b.failureRatio
+}
+
+// current
+// 打开状态冷却结束之后进入半开, 滑动窗口过期的桶清零
+func (b *Breaker) current(now time.Time) (State, uint64) {
+	if b.state == StateOpen && !now.Before(b.expiry) {
+		b.setState(StateHalfOpen, now)
+	}
+
+	if b.state == StateClosed {
+		b.rotate(now)
+	}
+	return b.state, b.generation
+}
+
+func (b *Breaker) rotate(now time.Time) {
+	if b.bucketDur <= 0 {
+		return
+	}
+
+	n := int64(now.Sub(b.bucketStart) / b.bucketDur)
+	if n <= 0 {
+		return
+	}
+	b.bucketStart = b.bucketStart.Add(time.Duration(n) * b.bucketDur)
+
+	if n > int64(len(b.buckets)) {
+		n = int64(len(b.buckets))
+	}
+
+	for i := int64(0); i < n; i++ {
+		b.cur = (b.cur + 1) % len(b.buckets)
+		b.buckets[b.cur] = bucket{}
+	}
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+
+	b.changes = append(b.changes, stateChange{from: b.state, to: state})
+	b.state = state
+	b.generation++
+
+	b.consecutiveSuccesses = 0
+	b.consecutiveFailures = 0
+	b.halfOpenReqs = 0
+
+	switch state {
+	case StateOpen:
+		b.opens++
+		b.expiry = now.Add(b.wait(b.opens))
+	case StateClosed:
+		b.opens = 0
+		b.wait = b.coolDown()
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+		b.bucketStart = now
+	}
+}
+
+// unlock
+// 释放锁之后调用状态变化回调, 回调中可以访问熔断器
+func (b *Breaker) unlock() {
+	var changes = b.changes
+	b.changes = nil
+	b.mu.Unlock()
+
+	if b.onStateChange == nil {
+		return
+	}
+
+	for _, c := range changes {
+		b.onStateChange(b.name, c.from, c.to)
+	}
+}
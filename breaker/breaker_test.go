@@ -0,0 +1,146 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/breaker"
+	"github.com/pubgo/x/retry"
+)
+
+var errTest = errors.New("test error")
+
+func TestBreaker(t *testing.T) {
+	var mu sync.Mutex
+	var changes []string
+	b := breaker.New("test",
+		breaker.WithConsecutiveFailures(3),
+		breaker.WithCoolDown(time.Millisecond*20),
+		breaker.OnStateChange(func(name string, from, to breaker.State) {
+			mu.Lock()
+			changes = append(changes, from.String()+"->"+to.String())
+			mu.Unlock()
+		}),
+	)
+
+	fail := func(ctx context.Context) error { return errTest }
+	ok := func(ctx context.Context) error { return nil }
+
+	for i := 0; i < 3; i++ {
+		if err := b.Do(context.Background(), fail); err != errTest {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.Do(context.Background(), ok); err != breaker.ErrOpen {
+		t.Fatalf("expect ErrOpen, got %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	if b.State() != breaker.StateHalfOpen {
+		t.Fatalf("expect half-open, got %s", b.State())
+	}
+
+	if err := b.Do(context.Background(), ok); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("expect closed, got %s", b.State())
+	}
+
+	expect := []string{"closed->open", "open->half-open", "half-open->closed"}
+	for i := range expect {
+		if changes[i] != expect[i] {
+			t.Fatalf("expect %v, got %v", expect, changes)
+		}
+	}
+}
+
+func TestBreakerRatio(t *testing.T) {
+	b := breaker.New("test",
+		breaker.WithConsecutiveFailures(0),
+		breaker.WithFailureRatio(0.5, 10),
+		breaker.WithCoolDownStrategy(retry.BinaryExponential(time.Millisecond*10, time.Second)),
+	)
+
+	for i := 0; i < 10; i++ {
+		_ = b.Do(context.Background(), func(ctx context.Context) error {
+			if i%2 == 1 {
+				panic(errTest)
+			}
+			return nil
+		})
+	}
+
+	if c := b.Counts(); b.State() != breaker.StateOpen || c.Failures != 5 {
+		t.Fatalf("expect open, got %s, %+v", b.State(), c)
+	}
+
+	// half-open failed, open again with longer cool down
+	time.Sleep(time.Millisecond * 25)
+	_ = b.Do(context.Background(), func(ctx context.Context) error { return errTest })
+	time.Sleep(time.Millisecond * 25)
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("expect open, got %s", b.State())
+	}
+}
+
+func TestBreakerRetry(t *testing.T) {
+	b := breaker.New("test", breaker.WithConsecutiveFailures(2), breaker.WithCoolDown(time.Hour))
+
+	var n int
+	err := b.Retry(context.Background(), func(ctx context.Context) error {
+		n++
+		return errTest
+	}, retry.WithAttempt(10), retry.WithStrategy(retry.Constant(0)))
+
+	if !breaker.IsOpen(err) || n != 2 {
+		t.Fatalf("expect stop retry when open, got %v, %d", err, n)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	b := breaker.New("http", breaker.WithConsecutiveFailures(2), breaker.WithCoolDown(time.Hour))
+	client := &http.Client{Transport: breaker.Transport(b, nil, nil)}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if _, err := client.Get(srv.URL); !breaker.IsOpen(err) {
+		t.Fatalf("expect ErrOpen, got %v", err)
+	}
+
+	// 拒绝的请求也要关闭 Body
+	body := &closeBody{Reader: strings.NewReader("data")}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, body)
+	if _, err := breaker.Transport(b, nil, nil).RoundTrip(req); !breaker.IsOpen(err) || !body.closed {
+		t.Fatalf("expect ErrOpen and body closed, got %v, %v", err, body.closed)
+	}
+}
+
+type closeBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *closeBody) Close() error {
+	b.closed = true
+	return nil
+}
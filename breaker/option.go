@@ -0,0 +1,66 @@
+package breaker
+
+import (
+	"time"
+
+	"github.com/pubgo/x/retry"
+)
+
+// Option 熔断器配置
+type Option func(b *Breaker)
+
+// WithWindow 滑动窗口的时间长度和桶数量
+func WithWindow(window time.Duration, buckets int) Option {
+	return func(b *Breaker) {
+		b.window = window
+		b.bucketNum = buckets
+	}
+}
+
+// WithFailureRatio 滑动窗口内请求数不少于 minRequests 并且失败率不低于 ratio 时打开, ratio <= 0 表示不使用失败率
+func WithFailureRatio(ratio float64, minRequests uint32) Option {
+	return func(b *Breaker) {
+		b.failureRatio = ratio
+		b.minRequests = minRequests
+	}
+}
+
+// WithConsecutiveFailures 连续失败 n 次时打开, 0 表示不使用连续失败次数
+func WithConsecutiveFailures(n uint32) Option {
+	return func(b *Breaker) {
+		b.maxConsecutive = n
+	}
+}
+
+// WithCoolDown 打开状态的冷却时间
+func WithCoolDown(dur time.Duration) Option {
+	return WithCoolDownStrategy(retry.Constant(dur))
+}
+
+// WithCoolDownStrategy 冷却时间策略, attempt 为连续打开的次数, 例如 retry.BinaryExponential(time.Second, time.Minute)
+func WithCoolDownStrategy(strategy retry.Strategy) Option {
+	return func(b *Breaker) {
+		b.coolDown = strategy
+	}
+}
+
+// WithHalfOpenRequests 半开状态允许通过的请求数, 全部成功时关闭
+func WithHalfOpenRequests(n uint32) Option {
+	return func(b *Breaker) {
+		b.halfOpenMax = n
+	}
+}
+
+// WithIsFailure 判断错误是否计为失败, 默认 context.Canceled 之外的错误都计为失败
+func WithIsFailure(fn func(err error) bool) Option {
+	return func(b *Breaker) {
+		b.isFailure = fn
+	}
+}
+
+// OnStateChange 状态变化回调
+func OnStateChange(fn func(name string, from, to State)) Option {
+	return func(b *Breaker) {
+		b.onStateChange = fn
+	}
+}
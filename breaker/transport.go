@@ -0,0 +1,49 @@
+package breaker
+
+import (
+	"net/http"
+)
+
+// Transport
+// 通过熔断器发送请求的 http.RoundTripper, 可以直接用于 webdav.Client.SetTransport 和 xmlrpc.Client.SetTransport
+// next 为 nil 时使用 http.DefaultTransport
+// isFailure 为 nil 时, 请求出错或者状态码为 5xx, 429 时计为失败
+func Transport(b *Breaker, next http.RoundTripper, isFailure func(resp *http.Response, err error) bool) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if isFailure == nil {
+		isFailure = isRespFailure
+	}
+
+	return &transport{b: b, next: next, isFailure: isFailure}
+}
+
+type transport struct {
+	b         *Breaker
+	next      http.RoundTripper
+	isFailure func(resp *http.Response, err error) bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.b.Allow()
+	if err != nil {
+		// RoundTripper 必须关闭请求的 Body, 即使出错
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	done(t.isFailure(resp, err))
+	return resp, err
+}
+
+func isRespFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return isFailure(err)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
@@ -1,12 +1,10 @@
 module github.com/pubgo/x
 
-go 1.14
+go 1.18
 
 require (
 	github.com/DearMadMan/minhash v0.1.0
 	github.com/GoAdminGroup/go-admin v1.1.5
-	github.com/Masterminds/goutils v1.1.0 // indirect
-	github.com/Masterminds/semver v1.5.0 // indirect
 	github.com/Masterminds/sprig v2.22.0+incompatible
 	github.com/NYTimes/gziphandler v1.1.1
 	github.com/PuerkitoBio/goquery v1.5.0
@@ -15,16 +13,12 @@ require (
 	github.com/RoaringBitmap/roaring v0.4.23
 	github.com/alecthomas/log4go v0.0.0-20180109082532-d146e6b86faa
 	github.com/aliyun/aliyun-oss-go-sdk v2.0.3+incompatible
-	github.com/antchfx/htmlquery v1.2.3 // indirect
-	github.com/aristanetworks/goarista v0.0.0-20210107181124-fad53805024e // indirect
 	github.com/awa/go-iap v1.1.0
 	github.com/boombuler/barcode v1.0.1-0.20180315051053-3c06908149f7
 	github.com/bradfitz/gomemcache v0.0.0-20190329173943-551aad21a668
-	github.com/btcsuite/btcd v0.21.0-beta // indirect
 	github.com/caddyserver/caddy v1.0.4
 	github.com/coocood/freecache v1.1.0
 	github.com/dave/flux v0.0.0-20180702001202-0b08a93f243b
-	github.com/dave/ktest v1.1.3 // indirect
 	github.com/davecgh/go-spew v1.1.1
 	github.com/deckarep/golang-set v1.7.1
 	github.com/denisenkom/go-mssqldb v0.0.0-20191001013358-cfbb681360f0
@@ -33,7 +27,6 @@ require (
 	github.com/dustin/go-humanize v1.0.0
 	github.com/emirpasic/gods v1.12.0
 	github.com/ethereum/go-ethereum v1.9.25
-	github.com/fastly/go-utils v0.0.0-20180712184237-d95a45783239 // indirect
 	github.com/fatih/color v1.9.0
 	github.com/flosch/pongo2 v0.0.0-20190707114632-bbf5a6c351f4
 	github.com/foolin/goview v0.2.0
@@ -44,7 +37,6 @@ require (
 	github.com/gin-gonic/contrib v0.0.0-20191209060500-d6e26eeaa607
 	github.com/gin-gonic/gin v1.4.1-0.20190924141841-9b9f4fab34cc
 	github.com/go-acme/lego/v3 v3.2.0
-	github.com/go-gorp/gorp v2.2.0+incompatible // indirect
 	github.com/go-redis/redis/v7 v7.2.0
 	github.com/go-resty/resty/v2 v2.1.0
 	github.com/go-session/session v3.1.2+incompatible
@@ -61,11 +53,9 @@ require (
 	github.com/gorilla/securecookie v1.1.1
 	github.com/gorilla/sessions v1.2.0
 	github.com/gorilla/websocket v1.4.1
-	github.com/huandu/xstrings v1.3.2 // indirect
 	github.com/imdario/mergo v0.3.11
 	github.com/iris-contrib/blackfriday v2.0.0+incompatible
 	github.com/issue9/identicon v1.0.1
-	github.com/jehiah/go-strftime v0.0.0-20171201141054-1d33003b3869 // indirect
 	github.com/jinzhu/gorm v1.9.11
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/jordan-wright/email v0.0.0-20190819015918-041e0cec78b0
@@ -76,7 +66,6 @@ require (
 	github.com/lestrrat-go/strftime v1.0.3
 	github.com/lib/pq v1.2.0
 	github.com/lucas-clemente/quic-go v0.14.1
-	github.com/mailru/easyjson v0.7.6 // indirect
 	github.com/mattermost/gorp v2.0.0+incompatible
 	github.com/mattn/go-colorable v0.1.4
 	github.com/mattn/go-isatty v0.0.11
@@ -86,7 +75,6 @@ require (
 	github.com/memcachier/mc v2.0.1+incompatible
 	github.com/mholt/certmagic v0.9.0
 	github.com/microcosm-cc/bluemonday v1.0.2
-	github.com/mitchellh/copystructure v1.0.0 // indirect
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/mssola/user_agent v0.5.0
@@ -101,7 +89,6 @@ require (
 	github.com/pborman/uuid v1.2.0
 	github.com/pelletier/go-toml v1.2.0
 	github.com/pkg/sftp v1.11.0
-	github.com/poy/onpar v1.0.1 // indirect
 	github.com/prometheus/client_golang v1.4.1
 	github.com/pubgo/schema v0.0.3
 	github.com/pubgo/xerror v0.3.24
@@ -123,8 +110,6 @@ require (
 	github.com/streadway/amqp v0.0.0-20190827072141-edfb9018d271
 	github.com/stretchr/objx v0.2.0
 	github.com/stretchr/testify v1.4.0
-	github.com/tebeka/strftime v0.1.5 // indirect
-	github.com/throttled/throttled v2.2.5+incompatible // indirect
 	github.com/tidwall/gjson v1.3.2
 	github.com/treewei/blackfriday v2.0.0+incompatible
 	github.com/tylerb/graceful v1.2.15
@@ -156,3 +141,41 @@ require (
 	xorm.io/core v0.7.2
 	xorm.io/xorm v0.8.1
 )
+
+require (
+	github.com/Masterminds/goutils v1.1.0 // indirect
+	github.com/Masterminds/semver v1.5.0 // indirect
+	github.com/antchfx/htmlquery v1.2.3 // indirect
+	github.com/aristanetworks/goarista v0.0.0-20210107181124-fad53805024e // indirect
+	github.com/btcsuite/btcd v0.21.0-beta // indirect
+	github.com/cespare/xxhash v1.1.0 // indirect
+	github.com/dave/ktest v1.1.3 // indirect
+	github.com/fastly/go-utils v0.0.0-20180712184237-d95a45783239 // indirect
+	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/go-gorp/gorp v2.2.0+incompatible // indirect
+	github.com/go-playground/locales v0.12.1 // indirect
+	github.com/go-playground/universal-translator v0.16.0 // indirect
+	github.com/golang/protobuf v1.4.2 // indirect
+	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/huandu/xstrings v1.3.2 // indirect
+	github.com/jehiah/go-strftime v0.0.0-20171201141054-1d33003b3869 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/leodido/go-urn v1.1.0 // indirect
+	github.com/magiconair/properties v1.8.1 // indirect
+	github.com/mailru/easyjson v0.7.6 // indirect
+	github.com/mitchellh/copystructure v1.0.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/poy/onpar v1.0.1 // indirect
+	github.com/pubgo/errors v0.3.13 // indirect
+	github.com/spf13/afero v1.1.2 // indirect
+	github.com/spf13/cast v1.3.0 // indirect
+	github.com/spf13/jwalterweatherman v1.0.0 // indirect
+	github.com/tebeka/strftime v0.1.5 // indirect
+	github.com/throttled/throttled v2.2.5+incompatible // indirect
+	github.com/ugorji/go/codec v1.1.7 // indirect
+	github.com/vmihailenco/tagparser v0.1.0 // indirect
+	golang.org/x/sys v0.0.0-20200824131525-c12d262b63d8 // indirect
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
+	google.golang.org/protobuf v1.25.0 // indirect
+	gopkg.in/go-playground/validator.v9 v9.29.1 // indirect
+)
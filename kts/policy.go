@@ -0,0 +1,372 @@
+package kts
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/fnv"
+)
+
+// Policy RichBox 的淘汰策略
+type Policy int
+
+const (
+	// PolicyLRU 淘汰最久没有访问的 key
+	PolicyLRU Policy = iota
+	// PolicyLFU 淘汰访问次数最少的 key, 次数相同时淘汰最久没有访问的
+	PolicyLFU
+	// PolicyTinyLFU W-TinyLFU, 新 key 先进入窗口 LRU, 离开窗口时和主缓存的淘汰对象比较访问频率
+	PolicyTinyLFU
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "lru"
+	case PolicyLFU:
+		return "lfu"
+	case PolicyTinyLFU:
+		return "tinylfu"
+	default:
+		return "unknown"
+	}
+}
+
+// policy 只记录 key 的顺序, 数据由 RichBox 保存
+type policy interface {
+	add(key string)
+	access(key string)
+	remove(key string)
+	// victim 下一个淘汰的 key, 不会从策略中删除
+	victim() (string, bool)
+	reset()
+}
+
+func newPolicy(p Policy, capacity int) policy {
+	switch p {
+	case PolicyLFU:
+		return newLFU()
+	case PolicyTinyLFU:
+		return newTinyLFU(capacity)
+	default:
+		return newLRU()
+	}
+}
+
+type lruPolicy struct {
+	list  *list.List
+	table map[string]*list.Element
+}
+
+func newLRU() *lruPolicy {
+	return &lruPolicy{list: list.New(), table: make(map[string]*list.Element)}
+}
+
+func (p *lruPolicy) add(key string) {
+	p.table[key] = p.list.PushFront(key)
+}
+
+func (p *lruPolicy) access(key string) {
+	if el := p.table[key]; el != nil {
+		p.list.MoveToFront(el)
+	}
+}
+
+func (p *lruPolicy) remove(key string) {
+	if el := p.table[key]; el != nil {
+		p.list.Remove(el)
+		delete(p.table, key)
+	}
+}
+
+func (p *lruPolicy) victim() (string, bool) {
+	if el := p.list.Back(); el != nil {
+		return el.Value.(string), true
+	}
+	return "", false
+}
+
+func (p *lruPolicy) reset() {
+	p.list.Init()
+	p.table = make(map[string]*list.Element)
+}
+
+type lfuItem struct {
+	key   string
+	freq  uint64
+	tick  uint64
+	index int
+}
+
+// lfuHeap 按 (freq, tick) 排序的最小堆
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	item := x.(*lfuItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+type lfuPolicy struct {
+	heap  lfuHeap
+	table map[string]*lfuItem
+	tick  uint64
+}
+
+func newLFU() *lfuPolicy {
+	return &lfuPolicy{table: make(map[string]*lfuItem)}
+}
+
+func (p *lfuPolicy) add(key string) {
+	p.tick++
+	item := &lfuItem{key: key, freq: 1, tick: p.tick}
+	p.table[key] = item
+	heap.Push(&p.heap, item)
+}
+
+func (p *lfuPolicy) access(key string) {
+	if item := p.table[key]; item != nil {
+		p.tick++
+		item.freq++
+		item.tick = p.tick
+		heap.Fix(&p.heap, item.index)
+	}
+}
+
+func (p *lfuPolicy) remove(key string) {
+	if item := p.table[key]; item != nil {
+		heap.Remove(&p.heap, item.index)
+		delete(p.table, key)
+	}
+}
+
+func (p *lfuPolicy) victim() (string, bool) {
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	return p.heap[0].key, true
+}
+
+func (p *lfuPolicy) reset() {
+	p.heap = nil
+	p.table = make(map[string]*lfuItem)
+}
+
+// sketch count-min sketch, 记录 key 最近的访问频率, 计数总和达到 sampleSize 后所有计数减半
+type sketch struct {
+	rows       [4][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newSketch(capacity int) *sketch {
+	var width = 64
+	for width < capacity {
+		width <<= 1
+	}
+
+	var s = &sketch{mask: uint64(width - 1), sampleSize: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+var sketchSeeds = [4]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+func (s *sketch) hash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func (s *sketch) index(h uint64, i int) uint64 {
+	h ^= sketchSeeds[i]
+	h *= 0x9e3779b97f4a7c15
+	return (h ^ h>>32) & s.mask
+}
+
+func (s *sketch) increment(key string) {
+	var h = s.hash(key)
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < 15 {
+			*c++
+		}
+	}
+
+	if s.additions++; s.additions >= s.sampleSize {
+		s.halve()
+	}
+}
+
+func (s *sketch) estimate(key string) uint8 {
+	var h = s.hash(key)
+	var min uint8 = 15
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+func (s *sketch) halve() {
+	s.additions /= 2
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+}
+
+func (s *sketch) reset() {
+	s.additions = 0
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+}
+
+const (
+	tinyLFUWindow    = 0
+	tinyLFUProbation = 1
+	tinyLFUProtected = 2
+)
+
+type tinyLFUEntry struct {
+	key     string
+	segment int
+}
+
+// tinyLFUPolicy
+// 窗口 LRU 约占 1%, 主缓存为 SLRU, 其中 protected 占 80%
+type tinyLFUPolicy struct {
+	sketch   *sketch
+	segments [3]*list.List
+	table    map[string]*list.Element
+}
+
+func newTinyLFU(capacity int) *tinyLFUPolicy {
+	var p = &tinyLFUPolicy{sketch: newSketch(capacity), table: make(map[string]*list.Element)}
+	for i := range p.segments {
+		p.segments[i] = list.New()
+	}
+	return p
+}
+
+func (p *tinyLFUPolicy) windowSize() int {
+	if n := len(p.table) / 100; n > 1 {
+		return n
+	}
+	return 1
+}
+
+func (p *tinyLFUPolicy) add(key string) {
+	p.sketch.increment(key)
+	p.table[key] = p.segments[tinyLFUWindow].PushFront(&tinyLFUEntry{key: key, segment: tinyLFUWindow})
+
+	// 窗口超出大小时末尾的 key 进入 probation
+	if window := p.segments[tinyLFUWindow]; window.Len() > p.windowSize()+1 {
+		p.move(window.Back(), tinyLFUProbation)
+	}
+}
+
+func (p *tinyLFUPolicy) access(key string) {
+	p.sketch.increment(key)
+
+	el := p.table[key]
+	if el == nil {
+		return
+	}
+
+	entry := el.Value.(*tinyLFUEntry)
+	switch entry.segment {
+	case tinyLFUWindow, tinyLFUProtected:
+		p.segments[entry.segment].MoveToFront(el)
+	case tinyLFUProbation:
+		p.move(el, tinyLFUProtected)
+
+		// protected 超出大小时末尾的 key 降级到 probation
+		var main = p.segments[tinyLFUProbation].Len() + p.segments[tinyLFUProtected].Len()
+		if protected := p.segments[tinyLFUProtected]; protected.Len() > main*8/10 {
+			p.move(protected.Back(), tinyLFUProbation)
+		}
+	}
+}
+
+func (p *tinyLFUPolicy) move(el *list.Element, segment int) {
+	entry := el.Value.(*tinyLFUEntry)
+	p.segments[entry.segment].Remove(el)
+	entry.segment = segment
+	p.table[entry.key] = p.segments[segment].PushFront(entry)
+}
+
+func (p *tinyLFUPolicy) remove(key string) {
+	if el := p.table[key]; el != nil {
+		p.segments[el.Value.(*tinyLFUEntry).segment].Remove(el)
+		delete(p.table, key)
+	}
+}
+
+func (p *tinyLFUPolicy) mainVictim() *list.Element {
+	if el := p.segments[tinyLFUProbation].Back(); el != nil {
+		return el
+	}
+	return p.segments[tinyLFUProtected].Back()
+}
+
+// victim 窗口末尾的 key 和主缓存的淘汰对象比较访问频率, 频率低的被淘汰
+func (p *tinyLFUPolicy) victim() (string, bool) {
+	var candidate = p.segments[tinyLFUWindow].Back()
+	var victim = p.mainVictim()
+	switch {
+	case candidate == nil && victim == nil:
+		return "", false
+	case victim == nil:
+		return candidate.Value.(*tinyLFUEntry).key, true
+	case candidate == nil:
+		return victim.Value.(*tinyLFUEntry).key, true
+	}
+
+	var candidateKey = candidate.Value.(*tinyLFUEntry).key
+	var victimKey = victim.Value.(*tinyLFUEntry).key
+	if p.sketch.estimate(candidateKey) <= p.sketch.estimate(victimKey) {
+		return candidateKey, true
+	}
+
+	// candidate 的访问频率更高, 进入 probation
+	p.move(candidate, tinyLFUProbation)
+	return victimKey, true
+}
+
+func (p *tinyLFUPolicy) reset() {
+	p.sketch.reset()
+	for i := range p.segments {
+		p.segments[i].Init()
+	}
+	p.table = make(map[string]*list.Element)
+}
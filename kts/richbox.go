@@ -1,126 +1,290 @@
 package kts
 
 import (
-	"container/list"
 	"sync"
 	"time"
 )
 
+// EvictReason 数据被删除的原因
+type EvictReason int
+
+const (
+	// EvictCapacity 超出数量或者字节数限制
+	EvictCapacity EvictReason = iota + 1
+	// EvictExpired 过期
+	EvictExpired
+	// EvictDeleted 调用 Delete 删除
+	EvictDeleted
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// Option RichBox 配置
+type Option func(c *RichBox)
+
+// WithPolicy 淘汰策略, 默认 PolicyLRU
+func WithPolicy(p Policy) Option {
+	return func(c *RichBox) {
+		c.policyType = p
+	}
+}
+
+// WithMaxBytes 所有 value 长度之和的上限, <= 0 表示不限制
+func WithMaxBytes(n int64) Option {
+	return func(c *RichBox) {
+		c.maxBytes = n
+	}
+}
+
+// WithOnEvict 数据被删除后的回调, 在锁外调用
+func WithOnEvict(fn func(key string, value []byte, reason EvictReason)) Option {
+	return func(c *RichBox) {
+		c.onEvict = fn
+	}
+}
+
+// Stats 命中统计
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+}
+
+// HitRatio 命中率, 没有访问时为 0
+func (s Stats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
 type richBoxEntry struct {
 	key       string
-	value     interface{}
+	value     []byte
 	createdAt time.Time
 	updatedAt time.Time
-	deletedAt time.Time
+	expireAt  time.Time
+}
+
+func (e *richBoxEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+type evicted struct {
+	key    string
+	value  []byte
+	reason EvictReason
 }
 
 type RichBox struct {
 	mu sync.Mutex
 
-	list  *list.List
-	table map[string]*list.Element
+	table  map[string]*richBoxEntry
+	policy policy
 
+	policyType Policy
 	expiration time.Duration
 	maxLen     int
+	maxBytes   int64
+	bytes      int64
+	onEvict    func(key string, value []byte, reason EvictReason)
+	stats      Stats
 }
 
-func New(maxLen int, expiration time.Duration) *RichBox {
-	return &RichBox{
-		list:  list.New(),
-		table: make(map[string]*list.Element, maxLen),
-
+// New
+// maxLen <= 0 表示不限制数量, expiration <= 0 表示 Set 写入的数据不过期
+func New(maxLen int, expiration time.Duration, opts ...Option) *RichBox {
+	var c = &RichBox{
 		expiration: expiration,
 		maxLen:     maxLen,
 	}
-}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-func (c *RichBox) Get(key string) ([]byte, bool) {
-	return c.get(key)
+	c.table = make(map[string]*richBoxEntry)
+	c.policy = newPolicy(c.policyType, maxLen)
+	return c
 }
 
-func (c *RichBox) get(key string) (interface{}, bool) {
+func (c *RichBox) Get(key string) ([]byte, bool) {
 	c.mu.Lock()
 
-	el := c.table[key]
-	if el == nil {
+	entry := c.table[key]
+	if entry == nil {
+		c.stats.Misses++
+		c.policy.access(key)
 		c.mu.Unlock()
 		return nil, false
 	}
 
-	entry := el.Value.(*richBoxEntry)
-	if time.Since(entry.createdAt) > c.expiration {
-		c.deleteElement(el)
+	if entry.expired(time.Now()) {
+		c.stats.Misses++
+		c.removeEntry(entry)
+		c.stats.Expirations++
 		c.mu.Unlock()
+		c.notify([]evicted{{key: entry.key, value: entry.value, reason: EvictExpired}})
 		return nil, false
 	}
 
-	c.list.MoveToFront(el)
+	c.stats.Hits++
+	c.policy.access(key)
 	value := entry.value
 	c.mu.Unlock()
 	return value, true
 }
 
+// Set 使用 New 的 expiration 作为过期时间
 func (c *RichBox) Set(key string, value []byte) {
+	c.SetWithTTL(key, value, c.expiration)
+}
+
+// SetWithTTL ttl <= 0 表示不过期
+// value 的长度超过 maxBytes 时不保存, 同时删除 key 原来的数据
+func (c *RichBox) SetWithTTL(key string, value []byte, ttl time.Duration) {
+	var now = time.Now()
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = now.Add(ttl)
+	}
+
 	c.mu.Lock()
-	if el := c.table[key]; el != nil {
-		entry := el.Value.(*richBoxEntry)
+	var evicts []evicted
+	if c.maxBytes > 0 && int64(len(value)) > c.maxBytes {
+		if entry := c.table[key]; entry != nil {
+			c.removeEntry(entry)
+			c.stats.Evictions++
+			evicts = append(evicts, evicted{key: entry.key, value: entry.value, reason: EvictCapacity})
+		}
+		c.mu.Unlock()
+		c.notify(evicts)
+		return
+	}
+
+	if entry := c.table[key]; entry != nil {
+		c.bytes += int64(len(value) - len(entry.value))
 		entry.value = value
-		c.promote(el, entry)
+		entry.updatedAt = now
+		entry.expireAt = expireAt
+		c.policy.access(key)
 	} else {
-		c.addNew(key, value)
+		c.table[key] = &richBoxEntry{key: key, value: value, createdAt: now, updatedAt: now, expireAt: expireAt}
+		c.bytes += int64(len(value))
+		c.policy.add(key)
 	}
+
+	evicts = c.check(evicts)
 	c.mu.Unlock()
+	c.notify(evicts)
 }
 
 func (c *RichBox) Delete(key string) bool {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
-	el := c.table[key]
-	if el == nil {
+	entry := c.table[key]
+	if entry == nil {
+		c.mu.Unlock()
 		return false
 	}
 
-	c.deleteElement(el)
+	c.removeEntry(entry)
+	c.mu.Unlock()
+	c.notify([]evicted{{key: entry.key, value: entry.value, reason: EvictDeleted}})
 	return true
 }
 
+// DeleteExpired 删除所有过期的数据
+func (c *RichBox) DeleteExpired() {
+	var now = time.Now()
+	var evicts []evicted
+
+	c.mu.Lock()
+	for _, entry := range c.table {
+		if entry.expired(now) {
+			c.removeEntry(entry)
+			c.stats.Expirations++
+			evicts = append(evicts, evicted{key: entry.key, value: entry.value, reason: EvictExpired})
+		}
+	}
+	c.mu.Unlock()
+	c.notify(evicts)
+}
+
+// Len 数据的数量, 包括过期但是还没有删除的数据
 func (c *RichBox) Len() int {
-	return c.list.Len()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.table)
+}
+
+// Bytes 所有 value 长度之和
+func (c *RichBox) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
 }
 
+// Stats 命中统计
+func (c *RichBox) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Flush 删除所有数据, 不触发回调
 func (c *RichBox) Flush() error {
 	c.mu.Lock()
-	c.list = list.New()
-	c.table = make(map[string]*list.Element, c.maxLen)
+	c.table = make(map[string]*richBoxEntry)
+	c.policy.reset()
+	c.bytes = 0
 	c.mu.Unlock()
 	return nil
 }
 
-func (c *RichBox) addNew(key string, value []byte) {
-	newEntry := &richBoxEntry{
-		key:       key,
-		value:     value,
-		createdAt: time.Now(),
-	}
-	element := c.list.PushFront(newEntry)
-	c.table[key] = element
-	c.check()
+func (c *RichBox) removeEntry(entry *richBoxEntry) {
+	delete(c.table, entry.key)
+	c.policy.remove(entry.key)
+	c.bytes -= int64(len(entry.value))
 }
 
-func (c *RichBox) promote(el *list.Element, entry *richBoxEntry) {
-	entry.createdAt = time.Now()
-	c.list.MoveToFront(el)
+func (c *RichBox) overflow() bool {
+	return (c.maxLen > 0 && len(c.table) > c.maxLen) || (c.maxBytes > 0 && c.bytes > c.maxBytes)
 }
 
-func (c *RichBox) deleteElement(el *list.Element) {
-	c.list.Remove(el)
-	delete(c.table, el.Value.(*richBoxEntry).key)
+// check 按照淘汰策略删除数据, 直到数量和字节数都在限制之内
+func (c *RichBox) check(evicts []evicted) []evicted {
+	for c.overflow() {
+		key, ok := c.policy.victim()
+		if !ok {
+			break
+		}
+
+		entry := c.table[key]
+		c.removeEntry(entry)
+		c.stats.Evictions++
+		evicts = append(evicts, evicted{key: entry.key, value: entry.value, reason: EvictCapacity})
+	}
+	return evicts
 }
 
-func (c *RichBox) check() {
-	for c.list.Len() > c.maxLen {
-		el := c.list.Back()
-		c.deleteElement(el)
+func (c *RichBox) notify(evicts []evicted) {
+	if c.onEvict == nil {
+		return
+	}
+
+	for _, e := range evicts {
+		c.onEvict(e.key, e.value, e.reason)
 	}
 }
@@ -0,0 +1,107 @@
+package kts_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/kts"
+)
+
+func TestRichBoxTTL(t *testing.T) {
+	var reasons = make(map[string]kts.EvictReason)
+	var box = kts.New(0, time.Hour, kts.WithOnEvict(func(key string, value []byte, reason kts.EvictReason) {
+		reasons[key] = reason
+	}))
+
+	box.Set("a", []byte("a"))
+	box.SetWithTTL("b", []byte("b"), time.Millisecond)
+	box.SetWithTTL("c", []byte("c"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := box.Get("b"); ok {
+		t.Fatal("b should be expired")
+	}
+	if v, ok := box.Get("a"); !ok || string(v) != "a" {
+		t.Fatal(string(v), ok)
+	}
+	if _, ok := box.Get("c"); !ok {
+		t.Fatal("c should not expire")
+	}
+	if !box.Delete("c") || box.Len() != 1 {
+		t.Fatal(box.Len())
+	}
+
+	if reasons["b"] != kts.EvictExpired || reasons["c"] != kts.EvictDeleted {
+		t.Fatal(reasons)
+	}
+
+	var stats = box.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Expirations != 1 || stats.HitRatio() < 0.6 {
+		t.Fatal(stats)
+	}
+}
+
+func TestRichBoxMaxBytes(t *testing.T) {
+	for _, p := range []kts.Policy{kts.PolicyLRU, kts.PolicyLFU, kts.PolicyTinyLFU} {
+		t.Run(p.String(), func(t *testing.T) {
+			var evictions int
+			var box = kts.New(0, 0, kts.WithPolicy(p), kts.WithMaxBytes(100), kts.WithOnEvict(func(key string, value []byte, reason kts.EvictReason) {
+				if reason != kts.EvictCapacity {
+					t.Fatal(key, reason)
+				}
+				evictions++
+			}))
+
+			for i := 0; i < 50; i++ {
+				box.Set(strconv.Itoa(i), make([]byte, 10))
+				if box.Bytes() > 100 {
+					t.Fatal(box.Bytes())
+				}
+			}
+
+			if box.Len() != 10 || evictions != 40 || box.Stats().Evictions != 40 {
+				t.Fatal(box.Len(), evictions)
+			}
+
+			// 超过上限的 value 不保存
+			box.Set("big", make([]byte, 101))
+			if _, ok := box.Get("big"); ok {
+				t.Fatal("big should not be stored")
+			}
+		})
+	}
+}
+
+func TestRichBoxPolicy(t *testing.T) {
+	var hot = func(box *kts.RichBox) {
+		for i := 0; i < 5; i++ {
+			box.Get("hot")
+		}
+	}
+
+	// LRU 淘汰最久没有访问的 key
+	var lru = kts.New(3, 0)
+	lru.Set("a", nil)
+	lru.Set("b", nil)
+	lru.Set("c", nil)
+	lru.Get("a")
+	lru.Set("d", nil)
+	if _, ok := lru.Get("b"); ok {
+		t.Fatal("lru: b should be evicted")
+	}
+
+	// LFU 和 TinyLFU 保留访问频率高的 key
+	for _, p := range []kts.Policy{kts.PolicyLFU, kts.PolicyTinyLFU} {
+		var box = kts.New(10, 0, kts.WithPolicy(p))
+		box.Set("hot", nil)
+		hot(box)
+		for i := 0; i < 100; i++ {
+			box.Set(strconv.Itoa(i), nil)
+		}
+
+		if _, ok := box.Get("hot"); !ok || box.Len() != 10 {
+			t.Fatal(p, "hot should be kept", box.Len())
+		}
+	}
+}
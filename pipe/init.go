@@ -1,7 +1,23 @@
 package pipe
 
-import "github.com/pubgo/x/pkg"
+import (
+	"reflect"
+
+	"github.com/pubgo/x/pkg"
+	"github.com/pubgo/xerror"
+)
 
 var _if = pkg.If
 var _isZero = pkg.IsZero
 var _isNone = pkg.IsNone
+
+func _assertFn(fn interface{}) {
+	xerror.Assert(fn == nil || reflect.TypeOf(fn).Kind() != reflect.Func, "the input is not func type(%#v)", fn)
+}
+
+func _interface(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
@@ -14,12 +14,12 @@ type _func struct {
 }
 
 func (t *_func) Pipe(fn interface{}) *_func {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(fn)))
+	_assertFn(fn)
 
 	_fn := reflect.ValueOf(fn)
 	_t := _fn.Type()
 
-	xerror.PanicT(len(t.params) != _t.NumIn(), "the params num is not match(%d,%d)", len(t.params), _t.NumIn())
+	xerror.Assert(len(t.params) != _t.NumIn(), "the params num is not match(%d,%d)", len(t.params), _t.NumIn())
 
 	for i, p := range t.params {
 		if !p.IsValid() {
@@ -30,12 +30,12 @@ func (t *_func) Pipe(fn interface{}) *_func {
 }
 
 func (t *_func) SortBy(swap interface{}) *_func {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(swap)))
+	_assertFn(swap)
 
 	_fn := reflect.ValueOf(swap)
 	_t := _fn.Type()
-	xerror.PanicT(_t.NumIn() != 2, "the func input num is more than 2(%d)", _t.NumIn())
-	xerror.PanicT(_t.Out(0).Kind() != reflect.Bool, "the func output type is not bool(%s)", _t.Out(0).Kind().String())
+	xerror.Assert(_t.NumIn() != 2, "the func input num is more than 2(%d)", _t.NumIn())
+	xerror.Assert(_t.Out(0).Kind() != reflect.Bool, "the func output type is not bool(%s)", _t.Out(0).Kind().String())
 
 	for i := 0; i < len(t.params); i++ {
 		if !t.params[i].IsValid() {
@@ -64,13 +64,13 @@ func (t *_func) P(tags ...string) {
 }
 
 func (t *_func) Map(fn interface{}) *_func {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(fn)))
+	_assertFn(fn)
 
 	_fn := reflect.ValueOf(fn)
 	_t := _fn.Type()
-	xerror.PanicT(_t.NumIn() > 2 || _t.NumIn() == 0, "the func input num is [1,2], now(%d)", _t.NumIn())
-	xerror.PanicT(_t.NumOut() != 1, "the func output num is 1, now(%d)", _t.NumOut())
-	xerror.PanicT(_t.In(_t.NumIn()-1) != _t.Out(0), "the func input output type is not match(%s,%s)", _t.In(_t.NumIn()-1), _t.Out(0))
+	xerror.Assert(_t.NumIn() > 2 || _t.NumIn() == 0, "the func input num is [1,2], now(%d)", _t.NumIn())
+	xerror.Assert(_t.NumOut() != 1, "the func output num is 1, now(%d)", _t.NumOut())
+	xerror.Assert(_t.In(_t.NumIn()-1) != _t.Out(0), "the func input output type is not match(%s,%s)", _t.In(_t.NumIn()-1), _t.Out(0))
 
 	var _res []reflect.Value
 	for i, p := range t.params {
@@ -90,13 +90,13 @@ func (t *_func) Map(fn interface{}) *_func {
 }
 
 func (t *_func) Reduce(fn interface{}) *_func {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(fn)))
+	_assertFn(fn)
 
 	_fn := reflect.ValueOf(fn)
 	_t := _fn.Type()
-	xerror.PanicT(_t.NumIn() != 2, "the func input num is not equal 2(%d)", _t.NumIn())
-	xerror.PanicT(_t.NumOut() != 1, "the func output num is not equal 1(%d)", _t.NumOut())
-	xerror.PanicT(_t.In(0) != _t.In(1) || _t.In(1) != _t.Out(0), "the func input and output type is not match(%s,%s,%s)", _t.In(0), _t.In(1), _t.Out(0))
+	xerror.Assert(_t.NumIn() != 2, "the func input num is not equal 2(%d)", _t.NumIn())
+	xerror.Assert(_t.NumOut() != 1, "the func output num is not equal 1(%d)", _t.NumOut())
+	xerror.Assert(_t.In(0) != _t.In(1) || _t.In(1) != _t.Out(0), "the func input and output type is not match(%s,%s,%s)", _t.In(0), _t.In(1), _t.Out(0))
 
 	if len(t.params) == 0 {
 		return &_func{}
@@ -131,10 +131,10 @@ func (t *_func) Reduce(fn interface{}) *_func {
 }
 
 func (t *_func) Any(fn func(v interface{}) bool) bool {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(fn)))
+	_assertFn(fn)
 
 	for _, p := range t.params {
-		if fn(_if(_isZero(p), nil, p.Interface)) {
+		if fn(_interface(p)) {
 			return true
 		}
 	}
@@ -142,10 +142,10 @@ func (t *_func) Any(fn func(v interface{}) bool) bool {
 }
 
 func (t *_func) Every(fn func(v interface{}) bool) bool {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(fn)))
+	_assertFn(fn)
 
 	for _, p := range t.params {
-		if !fn(_if(!p.IsValid(), nil, p.Interface)) {
+		if !fn(_interface(p)) {
 			return false
 		}
 	}
@@ -154,7 +154,7 @@ func (t *_func) Every(fn func(v interface{}) bool) bool {
 
 func (t *_func) MustNotNil() {
 	for _, p := range t.params {
-		xerror.PanicT(_isZero(p), "nil error")
+		xerror.Assert(_isZero(p), "nil error")
 	}
 }
 
@@ -165,13 +165,13 @@ func (t *_func) FilterNil() *_func {
 }
 
 func (t *_func) Filter(fn interface{}) *_func {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(fn)))
+	_assertFn(fn)
 
 	_fn := reflect.ValueOf(fn)
 	_t := _fn.Type()
-	xerror.PanicT(_t.NumIn() > 2, "the func input num is more than 2(%d)", _t.NumIn())
-	xerror.PanicT(_t.NumOut() != 1, "the func output num is not equal 1(%d)", _t.NumOut())
-	xerror.PanicT(_t.Out(0).Kind() != reflect.Bool, "the func output type is not bool(%s)", _t.Out(0).Kind().String())
+	xerror.Assert(_t.NumIn() > 2, "the func input num is more than 2(%d)", _t.NumIn())
+	xerror.Assert(_t.NumOut() != 1, "the func output num is not equal 1(%d)", _t.NumOut())
+	xerror.Assert(_t.Out(0).Kind() != reflect.Bool, "the func output type is not bool(%s)", _t.Out(0).Kind().String())
 
 	var vs []reflect.Value
 	for i, p := range t.params {
@@ -189,13 +189,13 @@ func (t *_func) Filter(fn interface{}) *_func {
 }
 
 func (t *_func) Each(fn interface{}) {
-	xerror.Panic(xerror.AssertFn(reflect.ValueOf(fn)))
+	_assertFn(fn)
 
 	_fn := reflect.ValueOf(fn)
 	_t := _fn.Type()
-	xerror.PanicT(_t.NumIn() > 2, "the func input num is more than 2(%d)", _t.NumIn())
-	xerror.PanicT(_t.NumIn() == 0, "the func input num is more than 2(%d)", _t.NumIn())
-	xerror.PanicT(_t.NumOut() != 0, "the func output num is not equal 0(%d)", _t.NumOut())
+	xerror.Assert(_t.NumIn() > 2, "the func input num is more than 2(%d)", _t.NumIn())
+	xerror.Assert(_t.NumIn() == 0, "the func input num is more than 2(%d)", _t.NumIn())
+	xerror.Assert(_t.NumOut() != 0, "the func output num is not equal 0(%d)", _t.NumOut())
 
 	for i, p := range t.params {
 		if !p.IsValid() {
@@ -37,8 +37,8 @@ func Map(data interface{}, fn interface{}) IPipe {
 }
 
 // Reduce input array
-func Reduce(data interface{}, code string) IPipe {
-	return ArrayOf(data).Reduce(code)
+func Reduce(data interface{}, fn interface{}) IPipe {
+	return ArrayOf(data).Reduce(fn)
 }
 
 // Any input array
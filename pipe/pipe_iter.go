@@ -0,0 +1,209 @@
+package pipe
+
+import (
+	"bufio"
+	"database/sql"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Iter
+// 惰性迭代器, 每次从数据源拉取一个元素, 多个操作融合在一起执行, 不生成中间 slice
+// 迭代器只能遍历一次, 并且不是并发安全的
+// 提前结束遍历时需要调用 Close 释放数据源, Each, ToData 等结束操作会自动调用 Close
+type Iter[T any] struct {
+	next  func() (T, bool)
+	err   func() error
+	close func()
+}
+
+// Next 拉取下一个元素, 没有元素或者出错时返回 false
+func (t *Iter[T]) Next() (T, bool) {
+	return t.next()
+}
+
+// Err 遍历过程中的错误
+func (t *Iter[T]) Err() error {
+	if t.err == nil {
+		return nil
+	}
+	return t.err()
+}
+
+// Close 释放数据源
+func (t *Iter[T]) Close() {
+	if t.close != nil {
+		t.close()
+	}
+}
+
+// Map 转换每个元素, 类型不变
+func (t *Iter[T]) Map(fn func(v T) T) *Iter[T] {
+	return MapIter(t, fn)
+}
+
+// Filter 保留 fn 返回 true 的元素
+func (t *Iter[T]) Filter(fn func(v T) bool) *Iter[T] {
+	return &Iter[T]{err: t.err, close: t.close, next: func() (T, bool) {
+		for {
+			v, ok := t.next()
+			if !ok || fn(v) {
+				return v, ok
+			}
+		}
+	}}
+}
+
+// Take 最多拉取 n 个元素
+func (t *Iter[T]) Take(n int) *Iter[T] {
+	return &Iter[T]{err: t.err, close: t.close, next: func() (v T, ok bool) {
+		if n <= 0 {
+			return
+		}
+		n--
+		return t.next()
+	}}
+}
+
+// Each 遍历元素
+func (t *Iter[T]) Each(fn func(v T)) error {
+	defer t.Close()
+
+	for {
+		v, ok := t.next()
+		if !ok {
+			return t.Err()
+		}
+		fn(v)
+	}
+}
+
+// ToData 拉取所有元素
+func (t *Iter[T]) ToData() ([]T, error) {
+	var _res []T
+	return _res, t.Each(func(v T) { _res = append(_res, v) })
+}
+
+// ToTyped 拉取所有元素, 转换为 Typed
+func (t *Iter[T]) ToTyped() (*Typed[T], error) {
+	data, err := t.ToData()
+	return From(data), err
+}
+
+// MapIter 惰性转换每个元素为其他类型
+func MapIter[T, U any](t *Iter[T], fn func(v T) U) *Iter[U] {
+	return &Iter[U]{err: t.err, close: t.close, next: func() (u U, ok bool) {
+		v, ok := t.next()
+		if !ok {
+			return
+		}
+		return fn(v), true
+	}}
+}
+
+// Iter 转换为惰性迭代器
+func (t *Typed[T]) Iter() *Iter[T] {
+	return IterOf(t.data)
+}
+
+// IterOf 以 slice 为数据源的迭代器
+func IterOf[T any](data []T) *Iter[T] {
+	var i int
+	return &Iter[T]{next: func() (v T, ok bool) {
+		if i >= len(data) {
+			return
+		}
+		i++
+		return data[i-1], true
+	}}
+}
+
+// IterChan 以 channel 为数据源的迭代器, channel 关闭时结束
+func IterChan[T any](ch <-chan T) *Iter[T] {
+	return &Iter[T]{next: func() (T, bool) {
+		v, ok := <-ch
+		return v, ok
+	}}
+}
+
+// IterLines 按行读取 r 的迭代器, 不包含换行符
+func IterLines(r io.Reader) *Iter[string] {
+	var scanner = bufio.NewScanner(r)
+	var closer, _ = r.(io.Closer)
+	var closeErr error
+	var once sync.Once
+	var closeFn = func() {
+		once.Do(func() {
+			if closer != nil {
+				closeErr = closer.Close()
+			}
+		})
+	}
+
+	return &Iter[string]{
+		err: func() error {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return closeErr
+		},
+		close: closeFn,
+		next: func() (string, bool) {
+			if !scanner.Scan() {
+				return "", false
+			}
+			return scanner.Text(), true
+		},
+	}
+}
+
+var _scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// IterRows
+// 以 sqlx.Rows 为数据源的迭代器, 结束或者出错时关闭 rows
+// T 为 struct 并且没有实现 sql.Scanner 时使用 StructScan, 否则使用 Scan
+func IterRows[T any](rows *sqlx.Rows) *Iter[T] {
+	var _t = reflect.TypeOf((*T)(nil)).Elem()
+	var structScan = _t.Kind() == reflect.Struct && !reflect.PtrTo(_t).Implements(_scannerType)
+
+	var err error
+	var once sync.Once
+	var closeFn = func() {
+		once.Do(func() {
+			if _err := rows.Close(); err == nil {
+				err = _err
+			}
+		})
+	}
+
+	return &Iter[T]{
+		err:   func() error { return err },
+		close: closeFn,
+		next: func() (v T, ok bool) {
+			if err != nil {
+				return
+			}
+
+			if !rows.Next() {
+				err = rows.Err()
+				closeFn()
+				return
+			}
+
+			if structScan {
+				err = rows.StructScan(&v)
+			} else {
+				err = rows.Scan(&v)
+			}
+
+			if err != nil {
+				closeFn()
+				return
+			}
+			return v, true
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package pipe
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIter(t *testing.T) {
+	t.Run("lazy", func(t *testing.T) {
+		var calls int
+		var res, err = IterOf([]int{1, 2, 3, 4, 5, 6}).
+			Map(func(v int) int { calls++; return v * 2 }).
+			Filter(func(v int) bool { return v > 2 }).
+			Take(2).
+			ToData()
+		if err != nil || !reflect.DeepEqual(res, []int{4, 6}) {
+			t.Fatal(res, err)
+		}
+
+		if calls != 3 {
+			t.Fatal("the stages are not lazy", calls)
+		}
+	})
+
+	t.Run("lines", func(t *testing.T) {
+		var res, err = MapIter(IterLines(strings.NewReader("1\n2\n3\n")), func(v string) int {
+			i, _ := strconv.Atoi(v)
+			return i
+		}).ToTyped()
+		if err != nil || res.Reduce(func(acc, v int) int { return acc + v }) != 6 {
+			t.Fatal(res, err)
+		}
+	})
+
+	t.Run("chan", func(t *testing.T) {
+		var ch = make(chan int, 3)
+		ch <- 1
+		ch <- 2
+		ch <- 3
+		close(ch)
+
+		var res, err = IterChan(ch).ToData()
+		if err != nil || !reflect.DeepEqual(res, []int{1, 2, 3}) {
+			t.Fatal(res, err)
+		}
+	})
+}
+
+func TestParallel(t *testing.T) {
+	var data = make([]int, 1000)
+	for i := range data {
+		data[i] = i
+	}
+
+	var expect []string
+	for _, v := range data {
+		if v%3 == 0 {
+			expect = append(expect, strconv.Itoa(v*2))
+		}
+	}
+
+	var run = func(ordered bool) []string {
+		var p = IterOf(data).Parallel(4, ordered).
+			Filter(func(v int) bool { return v%3 == 0 }).
+			Map(func(v int) int { return v * 2 })
+		var res, err = ParallelMap(p, strconv.Itoa).Iter().ToData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	t.Run("ordered", func(t *testing.T) {
+		if res := run(true); !reflect.DeepEqual(res, expect) {
+			t.Fatal(res)
+		}
+	})
+
+	t.Run("unordered", func(t *testing.T) {
+		var res = run(false)
+		sort.Slice(res, func(i, j int) bool {
+			a, _ := strconv.Atoi(res[i])
+			b, _ := strconv.Atoi(res[j])
+			return a < b
+		})
+		if !reflect.DeepEqual(res, expect) {
+			t.Fatal(res)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		var _, err = IterOf(data).Parallel(4, true).Map(func(v int) int {
+			if v == 500 {
+				panic("map error")
+			}
+			return v
+		}).Iter().ToData()
+		if err == nil {
+			t.Fatal("expect error")
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		var it = IterOf(data).Parallel(4, false).Iter().Take(10)
+		var res, err = it.ToData()
+		if err != nil || len(res) != 10 {
+			t.Fatal(res, err)
+		}
+	})
+
+	t.Run("close blocked", func(t *testing.T) {
+		// channel 没有关闭, 上游阻塞在拉取
+		var ch = make(chan int, 1)
+		ch <- 1
+
+		var it = IterChan(ch).Parallel(2, false).Iter()
+		if v, ok := it.Next(); !ok || v != 1 {
+			t.Fatal(v, ok)
+		}
+
+		var closed = make(chan struct{})
+		go func() {
+			it.Close()
+			close(closed)
+		}()
+
+		select {
+		case <-closed:
+		case <-time.After(time.Second * 5):
+			t.Fatal("Close blocks on upstream pull")
+		}
+	})
+}
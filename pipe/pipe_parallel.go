@@ -0,0 +1,270 @@
+package pipe
+
+import (
+	"sync"
+
+	"github.com/pubgo/xerror"
+)
+
+// Parallel
+// 并行执行的 Map, Filter 阶段, 从上游顺序拉取元素, 融合之后的阶段由 n 个 worker 并行执行
+// 最多同时处理 2n 个元素, ordered 为 true 时按照上游的顺序输出
+type Parallel[T any] struct {
+	n       int
+	ordered bool
+	err     func() error
+	close   func()
+
+	// pull 从上游拉取一个元素, 返回在 worker 中执行的处理函数
+	pull func() (work func() (T, bool), ok bool)
+}
+
+// Parallel 并行执行之后的 Map, Filter, 调用 Iter 结束并行阶段
+func (t *Iter[T]) Parallel(n int, ordered bool) *Parallel[T] {
+	if n < 1 {
+		n = 1
+	}
+
+	return &Parallel[T]{n: n, ordered: ordered, err: t.Err, close: t.Close, pull: func() (func() (T, bool), bool) {
+		v, ok := t.next()
+		if !ok {
+			return nil, false
+		}
+		return func() (T, bool) { return v, true }, true
+	}}
+}
+
+// Map 并行转换每个元素, 类型不变
+func (t *Parallel[T]) Map(fn func(v T) T) *Parallel[T] {
+	return ParallelMap(t, fn)
+}
+
+// Filter 并行过滤元素
+func (t *Parallel[T]) Filter(fn func(v T) bool) *Parallel[T] {
+	return t.stage(func(work func() (T, bool)) func() (T, bool) {
+		return func() (T, bool) {
+			v, ok := work()
+			return v, ok && fn(v)
+		}
+	})
+}
+
+func (t *Parallel[T]) stage(fn func(work func() (T, bool)) func() (T, bool)) *Parallel[T] {
+	var p = *t
+	p.pull = func() (func() (T, bool), bool) {
+		work, ok := t.pull()
+		if !ok {
+			return nil, false
+		}
+		return fn(work), true
+	}
+	return &p
+}
+
+// ParallelMap 并行转换每个元素为其他类型
+func ParallelMap[T, U any](t *Parallel[T], fn func(v T) U) *Parallel[U] {
+	return &Parallel[U]{n: t.n, ordered: t.ordered, err: t.err, close: t.close, pull: func() (func() (U, bool), bool) {
+		work, ok := t.pull()
+		if !ok {
+			return nil, false
+		}
+
+		return func() (u U, ok bool) {
+			v, ok := work()
+			if !ok {
+				return
+			}
+			return fn(v), true
+		}, true
+	}}
+}
+
+// Iter
+// 结束并行阶段, 第一次拉取元素时启动 worker
+// 处理函数 panic 时停止处理, 通过 Err 返回错误
+// 上游阻塞在拉取时 Close 不等待拉取返回, 例如 IterChan 的 channel 没有关闭
+func (t *Parallel[T]) Iter() *Iter[T] {
+	type job struct {
+		seq  int
+		work func() (T, bool)
+	}
+
+	type pullResult struct {
+		work func() (T, bool)
+		ok   bool
+		err  error
+	}
+
+	type result struct {
+		seq int
+		v   T
+		ok  bool
+	}
+
+	var (
+		startOnce sync.Once
+		stopOnce  sync.Once
+		started   bool
+		done      = make(chan struct{})
+		pulled    = make(chan struct{})
+		window    = make(chan struct{}, t.n*2)
+		results   = make(chan result, t.n*2)
+		pending   = make(map[int]result)
+		seq       int
+
+		mu  sync.Mutex
+		err error
+	)
+
+	var stop = func() { stopOnce.Do(func() { close(done) }) }
+
+	var getErr = func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return err
+	}
+
+	var setErr = func(e error) {
+		mu.Lock()
+		if err == nil {
+			err = e
+		}
+		mu.Unlock()
+		stop()
+	}
+
+	var start = func() {
+		started = true
+		var jobs = make(chan job, t.n)
+		var reqs = make(chan struct{}, 1)
+		var pulls = make(chan pullResult, 1)
+
+		// 在单独的 goroutine 中从上游拉取, 拉取阻塞时生产者仍然可以响应 done
+		go func() {
+			for range reqs {
+				var r pullResult
+				r.err = xerror.Try(func() { r.work, r.ok = t.pull() })
+				pulls <- r
+			}
+		}()
+
+		go func() {
+			defer close(pulled)
+			defer close(jobs)
+			defer close(reqs)
+
+			for i := 0; ; i++ {
+				select {
+				case window <- struct{}{}:
+				case <-done:
+					return
+				}
+
+				reqs <- struct{}{}
+				var r pullResult
+				select {
+				case r = <-pulls:
+				case <-done:
+					return
+				}
+
+				if r.err != nil {
+					setErr(r.err)
+					return
+				}
+
+				if !r.ok {
+					return
+				}
+
+				var j = job{seq: i, work: r.work}
+
+				select {
+				case jobs <- j:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		wg.Add(t.n)
+		for i := 0; i < t.n; i++ {
+			go func() {
+				defer wg.Done()
+
+				for j := range jobs {
+					var r = result{seq: j.seq}
+					if e := xerror.Try(func() { r.v, r.ok = j.work() }); e != nil {
+						setErr(e)
+						return
+					}
+
+					select {
+					case results <- r:
+					case <-done:
+						return
+					}
+				}
+			}()
+		}
+
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+	}
+
+	var next = func() (v T, ok bool) {
+		startOnce.Do(start)
+
+		for getErr() == nil {
+			if t.ordered {
+				if r, has := pending[seq]; has {
+					delete(pending, seq)
+					seq++
+					<-window
+
+					if r.ok {
+						return r.v, true
+					}
+					continue
+				}
+			}
+
+			r, more := <-results
+			if !more {
+				return
+			}
+
+			if t.ordered {
+				pending[r.seq] = r
+				continue
+			}
+
+			<-window
+			if r.ok {
+				return r.v, true
+			}
+		}
+		return
+	}
+
+	return &Iter[T]{
+		next: next,
+		err: func() error {
+			if err := getErr(); err != nil {
+				return err
+			}
+			return t.err()
+		},
+		close: func() {
+			stop()
+			startOnce.Do(func() {})
+			if started {
+				<-pulled
+			}
+			t.close()
+		},
+	}
+}
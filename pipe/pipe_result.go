@@ -3,6 +3,8 @@ package pipe
 import (
 	"encoding/json"
 	"reflect"
+
+	"github.com/pubgo/xerror"
 )
 
 func (t *_func) ToRaw() []reflect.Value {
@@ -46,11 +48,11 @@ func (t *_func) ToData(fn ...interface{}) interface{} {
 func (t *_func) ToJSON() string {
 	var _res []interface{}
 	for _, _p := range t.params {
-		_res = append(_res, _if(_isNone(_p), "", _p.Interface))
+		_res = append(_res, _if(_p.IsValid(), _interface(_p), ""))
 	}
 
 	dt, err := json.Marshal(_res)
-	xerror.PanicM(err, "data json error")
+	xerror.PanicF(err, "data json error")
 
 	return string(dt)
 }
@@ -0,0 +1,215 @@
+package pipe
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pubgo/xerror"
+)
+
+// Typed
+// 泛型 pipe, 回调的类型在编译期检查, 不使用反射
+// 不改变元素类型的操作为方法, 可以链式调用, 改变元素类型的操作为函数, 例如 MapTo, FlatMap, Chunk, Zip
+type Typed[T any] struct {
+	data []T
+}
+
+// Pair Zip 的结果
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// From input slice
+func From[T any](data []T) *Typed[T] {
+	return &Typed[T]{data: data}
+}
+
+// Of input values
+func Of[T any](data ...T) *Typed[T] {
+	return &Typed[T]{data: data}
+}
+
+// Len 元素数量
+func (t *Typed[T]) Len() int {
+	return len(t.data)
+}
+
+// Map 转换每个元素, 类型不变
+func (t *Typed[T]) Map(fn func(v T) T) *Typed[T] {
+	var _res = make([]T, len(t.data))
+	for i, v := range t.data {
+		_res[i] = fn(v)
+	}
+	return &Typed[T]{data: _res}
+}
+
+// Filter 保留 fn 返回 true 的元素
+func (t *Typed[T]) Filter(fn func(v T) bool) *Typed[T] {
+	var _res []T
+	for _, v := range t.data {
+		if fn(v) {
+			_res = append(_res, v)
+		}
+	}
+	return &Typed[T]{data: _res}
+}
+
+// SortBy 稳定排序, 不修改原始数据
+func (t *Typed[T]) SortBy(less func(a, b T) bool) *Typed[T] {
+	var _res = make([]T, len(t.data))
+	copy(_res, t.data)
+	sort.SliceStable(_res, func(i, j int) bool { return less(_res[i], _res[j]) })
+	return &Typed[T]{data: _res}
+}
+
+// Reduce 从第一个元素开始累积, 没有元素时返回零值
+func (t *Typed[T]) Reduce(fn func(acc, v T) T) T {
+	var acc T
+	for i, v := range t.data {
+		if i == 0 {
+			acc = v
+			continue
+		}
+		acc = fn(acc, v)
+	}
+	return acc
+}
+
+// Any 任意一个元素满足条件
+func (t *Typed[T]) Any(fn func(v T) bool) bool {
+	for _, v := range t.data {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Every 所有元素都满足条件
+func (t *Typed[T]) Every(fn func(v T) bool) bool {
+	for _, v := range t.data {
+		if !fn(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Each 遍历元素
+func (t *Typed[T]) Each(fn func(i int, v T)) {
+	for i, v := range t.data {
+		fn(i, v)
+	}
+}
+
+// ToData 结果数据
+func (t *Typed[T]) ToData() []T {
+	return t.data
+}
+
+// ToString json 格式的结果数据
+func (t *Typed[T]) ToString() string {
+	dt, err := json.Marshal(t.data)
+	xerror.PanicF(err, "data json error")
+	return string(dt)
+}
+
+// P 打印结果数据
+func (t *Typed[T]) P(tags ...string) {
+	for _, v := range t.data {
+		fmt.Printf("%T %v\n", v, v)
+	}
+
+	if len(tags) > 0 {
+		fmt.Println(tags[0])
+	}
+	fmt.Print("\n\n")
+}
+
+// MapTo 转换每个元素为其他类型
+func MapTo[T, U any](t *Typed[T], fn func(v T) U) *Typed[U] {
+	var _res = make([]U, len(t.data))
+	for i, v := range t.data {
+		_res[i] = fn(v)
+	}
+	return &Typed[U]{data: _res}
+}
+
+// FlatMap 转换每个元素为 slice 并展开
+func FlatMap[T, U any](t *Typed[T], fn func(v T) []U) *Typed[U] {
+	var _res []U
+	for _, v := range t.data {
+		_res = append(_res, fn(v)...)
+	}
+	return &Typed[U]{data: _res}
+}
+
+// Fold 从 init 开始累积, 结果类型可以和元素类型不同
+func Fold[T, A any](t *Typed[T], init A, fn func(acc A, v T) A) A {
+	var acc = init
+	for _, v := range t.data {
+		acc = fn(acc, v)
+	}
+	return acc
+}
+
+// GroupBy 按照 key 分组, 组内保持原始顺序
+func GroupBy[T any, K comparable](t *Typed[T], key func(v T) K) map[K][]T {
+	var _res = make(map[K][]T)
+	for _, v := range t.data {
+		k := key(v)
+		_res[k] = append(_res[k], v)
+	}
+	return _res
+}
+
+// Chunk 按照 size 分块, 最后一块可能不足 size
+func Chunk[T any](t *Typed[T], size int) *Typed[[]T] {
+	xerror.Assert(size < 1, "the chunk size must be greater than 0(%d)", size)
+
+	var _res [][]T
+	for i := 0; i < len(t.data); i += size {
+		end := i + size
+		if end > len(t.data) {
+			end = len(t.data)
+		}
+		_res = append(_res, t.data[i:end:end])
+	}
+	return &Typed[[]T]{data: _res}
+}
+
+// Distinct 去重, 保留第一次出现的元素
+func Distinct[T comparable](t *Typed[T]) *Typed[T] {
+	return DistinctBy(t, func(v T) T { return v })
+}
+
+// DistinctBy 按照 key 去重, 保留第一次出现的元素
+func DistinctBy[T any, K comparable](t *Typed[T], key func(v T) K) *Typed[T] {
+	var _res []T
+	var seen = make(map[K]struct{}, len(t.data))
+	for _, v := range t.data {
+		k := key(v)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		_res = append(_res, v)
+	}
+	return &Typed[T]{data: _res}
+}
+
+// Zip 按照位置组合两个 pipe, 长度以较短的为准
+func Zip[A, B any](a *Typed[A], b *Typed[B]) *Typed[Pair[A, B]] {
+	n := len(a.data)
+	if len(b.data) < n {
+		n = len(b.data)
+	}
+
+	var _res = make([]Pair[A, B], n)
+	for i := 0; i < n; i++ {
+		_res[i] = Pair[A, B]{First: a.data[i], Second: b.data[i]}
+	}
+	return &Typed[Pair[A, B]]{data: _res}
+}
@@ -0,0 +1,89 @@
+package pipe
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	var data = From([]int{5, 3, 8, 3, 1, 8, 2})
+
+	t.Run("chain", func(t *testing.T) {
+		var res = data.Filter(func(v int) bool { return v > 2 }).
+			Map(func(v int) int { return v * 10 }).
+			SortBy(func(a, b int) bool { return a < b }).
+			ToData()
+		if !reflect.DeepEqual(res, []int{30, 30, 50, 80, 80}) {
+			t.Fatal(res)
+		}
+
+		if !reflect.DeepEqual(data.ToData(), []int{5, 3, 8, 3, 1, 8, 2}) {
+			t.Fatal("the source data is modified", data.ToData())
+		}
+	})
+
+	t.Run("reduce", func(t *testing.T) {
+		if sum := data.Reduce(func(acc, v int) int { return acc + v }); sum != 30 {
+			t.Fatal(sum)
+		}
+
+		if sum := Of[int]().Reduce(func(acc, v int) int { return acc + v }); sum != 0 {
+			t.Fatal(sum)
+		}
+
+		if s := Fold(data, "", func(acc string, v int) string { return acc + strconv.Itoa(v) }); s != "5383182" {
+			t.Fatal(s)
+		}
+	})
+
+	t.Run("map to", func(t *testing.T) {
+		var res = MapTo(data, strconv.Itoa).ToData()
+		if !reflect.DeepEqual(res, []string{"5", "3", "8", "3", "1", "8", "2"}) {
+			t.Fatal(res)
+		}
+
+		res = FlatMap(Of("a,b", "c"), func(v string) []string { return []string{v, v} }).ToData()
+		if !reflect.DeepEqual(res, []string{"a,b", "a,b", "c", "c"}) {
+			t.Fatal(res)
+		}
+	})
+
+	t.Run("group by", func(t *testing.T) {
+		var res = GroupBy(data, func(v int) bool { return v%2 == 0 })
+		if !reflect.DeepEqual(res, map[bool][]int{true: {8, 8, 2}, false: {5, 3, 3, 1}}) {
+			t.Fatal(res)
+		}
+	})
+
+	t.Run("chunk", func(t *testing.T) {
+		var res = Chunk(data, 3).ToData()
+		if !reflect.DeepEqual(res, [][]int{{5, 3, 8}, {3, 1, 8}, {2}}) {
+			t.Fatal(res)
+		}
+	})
+
+	t.Run("distinct", func(t *testing.T) {
+		var res = Distinct(data).ToData()
+		if !reflect.DeepEqual(res, []int{5, 3, 8, 1, 2}) {
+			t.Fatal(res)
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var res = Zip(data, Of("a", "b")).ToData()
+		if !reflect.DeepEqual(res, []Pair[int, string]{{5, "a"}, {3, "b"}}) {
+			t.Fatal(res)
+		}
+	})
+
+	t.Run("any every", func(t *testing.T) {
+		if !data.Any(func(v int) bool { return v == 1 }) || data.Every(func(v int) bool { return v > 1 }) {
+			t.Fatal("any every")
+		}
+
+		if s := Of(1, 2).ToString(); s != "[1,2]" {
+			t.Fatal(s)
+		}
+	})
+}
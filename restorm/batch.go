@@ -0,0 +1,472 @@
+package restorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pubgo/xerror"
+)
+
+const defaultBatchSize = 500
+
+// WriteResult 批量写入的结果
+type WriteResult struct {
+	// Affected 受影响的行数, mysql 的 upsert 更新一行计为 2
+	Affected int64
+	// IDs 写入记录的自增 ID, 需要通过 WithReturningID 指定
+	IDs []int64
+}
+
+type writeOptions struct {
+	batchSize     int
+	flushInterval time.Duration
+	upsert        bool
+	conflict      []string
+	update        []string
+	updateSet     bool
+	returning     string
+}
+
+// WriteOption 批量写入的配置
+type WriteOption func(o *writeOptions)
+
+// WithBatchSize
+// 一条 INSERT 语句中的最大记录数, 默认为 500
+// 同时受限于 Dialect.MaxParams
+func WithBatchSize(n int) WriteOption {
+	return func(o *writeOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithFlushInterval
+// 只用于 ResCreateStream, 批次中的记录最多等待 d 之后写入, 默认等待批次已满或者 rows 关闭
+func WithFlushInterval(d time.Duration) WriteOption {
+	return func(o *writeOptions) {
+		o.flushInterval = d
+	}
+}
+
+// WithUpsert
+// 插入的记录和 conflict 中的唯一索引冲突时更新记录, 默认更新除 conflict 之外插入的所有字段
+// mysql 根据主键和所有唯一索引判断冲突, conflict 只用于确定更新的字段, mssql 不支持
+func WithUpsert(conflict ...string) WriteOption {
+	return func(o *writeOptions) {
+		o.upsert = true
+		o.conflict = conflict
+	}
+}
+
+// WithUpsertUpdate 冲突时只更新 update 中的字段, 为空时忽略冲突的记录
+func WithUpsertUpdate(update ...string) WriteOption {
+	return func(o *writeOptions) {
+		o.update = update
+		o.updateSet = true
+	}
+}
+
+// WithReturningID
+// 返回自增字段 col 的值
+// postgresql 和 mssql 通过 RETURNING 和 OUTPUT 返回, 同时返回 upsert 更新的记录
+// mysql 和 sqlite 通过 LastInsertId 计算, 要求同一条语句分配的 ID 连续, 不支持 upsert
+// mysql 的 ID 间隔为 auto_increment_increment, innodb_autoinc_lock_mode=2 时多条记录的 ID 不连续, 返回错误
+func WithReturningID(col string) WriteOption {
+	return func(o *writeOptions) {
+		o.returning = col
+	}
+}
+
+// execer *sqlx.Tx 和 *sqlx.DB
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Queryx(query string, args ...interface{}) (*sqlx.Rows, error)
+}
+
+// batchWriter 按照字段分组, 字段相同的连续记录合并为一条 INSERT 语句
+type batchWriter struct {
+	dbName string
+	sql    sqlBuilder
+	opts   writeOptions
+
+	names []string
+	rows  []map[string]interface{}
+	res   WriteResult
+
+	// idStep mysql 自增 ID 的间隔, 为 0 时还没有读取
+	idStep int64
+	// interleaved mysql 的 innodb_autoinc_lock_mode 为 2, 多条记录的 ID 不连续
+	interleaved bool
+}
+
+func (t *RestOrm) newBatchWriter(dbName, tbName string, opts []WriteOption) (*pool, *batchWriter) {
+	var w = &batchWriter{dbName: dbName, opts: writeOptions{batchSize: defaultBatchSize}}
+	for _, opt := range opts {
+		opt(&w.opts)
+	}
+
+	p, _sql := t.builder(dbName, tbName)
+	w.sql = *_sql
+
+	if w.opts.upsert && w.opts.returning != "" {
+		if output, suffix := p.dialect.Returning([]string{w.opts.returning}); output == "" && suffix == "" {
+			p.release()
+			xerror.Assert(true, "dialect(%s) can not return ids of upsert", p.dialect.GetName())
+		}
+	}
+	return p, w
+}
+
+func sortedNames(row map[string]interface{}) []string {
+	var names = make([]string, 0, len(row))
+	for k := range row {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sameNames(names []string, row map[string]interface{}) bool {
+	if len(names) != len(row) {
+		return false
+	}
+
+	for _, name := range names {
+		if _, ok := row[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// full 当前批次是否已满, 一条语句的参数不能超过 Dialect.MaxParams
+func (w *batchWriter) full() bool {
+	var size = w.opts.batchSize
+	if max := w.sql.dialect.MaxParams() / len(w.names); max < size {
+		size = max
+	}
+	return len(w.rows) >= size
+}
+
+// add 添加一条记录, 字段和当前批次不同时先写入当前批次, 添加之后当前批次已满时写入
+func (w *batchWriter) add(row map[string]interface{}, flush func() error) error {
+	if len(row) == 0 {
+		return fmt.Errorf("restorm: empty row of %s.%s", w.dbName, w.sql.table)
+	}
+
+	if len(w.rows) > 0 && !sameNames(w.names, row) {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(w.rows) == 0 {
+		w.names = sortedNames(row)
+	}
+
+	w.rows = append(w.rows, row)
+	if w.full() {
+		return flush()
+	}
+	return nil
+}
+
+// upsert 冲突时更新的字段
+func (w *batchWriter) upsert() (string, error) {
+	if !w.opts.upsert {
+		return "", nil
+	}
+
+	var update = w.opts.update
+	if !w.opts.updateSet {
+		var conflict = make(map[string]bool, len(w.opts.conflict))
+		for _, c := range w.opts.conflict {
+			conflict[c] = true
+		}
+
+		for _, name := range w.names {
+			if !conflict[name] {
+				update = append(update, name)
+			}
+		}
+	}
+
+	for _, c := range append(append([]string{}, w.opts.conflict...), update...) {
+		if _, err := w.sql.column(c); err != nil {
+			return "", err
+		}
+	}
+
+	var clause = w.sql.dialect.Upsert(w.opts.conflict, update)
+	if clause == "" {
+		return "", fmt.Errorf("restorm: dialect(%s) does not support upsert with conflict(%v)", w.sql.dialect.GetName(), w.opts.conflict)
+	}
+	return clause, nil
+}
+
+// flush 写入当前批次
+func (w *batchWriter) flush(ex execer) error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+
+	var _sql = w.sql
+	_sql.args = nil
+
+	upsert, err := w.upsert()
+	if err != nil {
+		return err
+	}
+
+	var returning []string
+	if w.opts.returning != "" {
+		returning = []string{w.opts.returning}
+	}
+
+	query, err := _sql.insertManyString(w.names, w.rows, upsert, returning)
+	if err != nil {
+		return err
+	}
+
+	var n = len(w.rows)
+	w.rows = w.rows[:0]
+
+	if output, suffix := _sql.dialect.Returning(returning); output != "" || suffix != "" {
+		return w.query(ex, query, _sql.args)
+	}
+
+	var step int64 = 1
+	if w.opts.returning != "" && _sql.dialect.GetName() == "mysql" {
+		if step, err = w.autoIncrement(ex, n); err != nil {
+			return err
+		}
+	}
+
+	result, err := ex.Exec(query, _sql.args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	w.res.Affected += affected
+
+	if w.opts.returning == "" {
+		return nil
+	}
+
+	last, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	// mysql 返回第一条记录的 ID, sqlite 返回最后一条记录的 ID
+	var first = last
+	switch _sql.dialect.GetName() {
+	case "mysql":
+	case "sqlite":
+		first = last - int64(n) + 1
+	default:
+		return fmt.Errorf("restorm: dialect(%s) can not return ids", _sql.dialect.GetName())
+	}
+
+	for i := 0; i < n; i++ {
+		w.res.IDs = append(w.res.IDs, first+int64(i)*step)
+	}
+	return nil
+}
+
+// autoIncrement mysql 一条语句写入 n 条记录时 ID 的间隔
+func (w *batchWriter) autoIncrement(ex execer, n int) (int64, error) {
+	if w.idStep == 0 {
+		rows, err := ex.Queryx("SELECT @@auto_increment_increment, @@innodb_autoinc_lock_mode")
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+
+		var step, mode int64 = 1, 0
+		if rows.Next() {
+			if err := rows.Scan(&step, &mode); err != nil {
+				return 0, err
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+
+		w.idStep, w.interleaved = step, mode == 2
+	}
+
+	if w.interleaved && n > 1 {
+		return 0, fmt.Errorf("restorm: ids of a multi-row insert are not consecutive with innodb_autoinc_lock_mode=2, use WithBatchSize(1)")
+	}
+	return w.idStep, nil
+}
+
+// query 通过 RETURNING 读取 ID
+func (w *batchWriter) query(ex execer, query string, args []interface{}) error {
+	rows, err := ex.Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		w.res.IDs = append(w.res.IDs, id)
+		w.res.Affected++
+	}
+	return rows.Err()
+}
+
+// ResCreateBatch
+// 批量写入记录, 所有记录在一个事务中写入, 失败时回滚
+// 字段相同的连续记录合并为一条 INSERT 语句, 参考 WithBatchSize, WithUpsert 和 WithReturningID
+func (t *RestOrm) ResCreateBatch(dbName, tbName string, rows []map[string]interface{}, opts ...WriteOption) (res *WriteResult, err error) {
+	defer xerror.RespErr(&err)
+
+	p, w := t.newBatchWriter(dbName, tbName, opts)
+	defer p.release()
+
+	tx, err := p.db.Beginx()
+	xerror.Panic(err)
+
+	var flush = func() error { return w.flush(tx) }
+	for _, row := range rows {
+		if err = w.add(row, flush); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		xerror.PanicF(tx.Rollback(), "tx rollback error: %s", err)
+		xerror.PanicF(err, "db create error, dbName(%s) tbName(%s)", dbName, tbName)
+	}
+	xerror.PanicF(tx.Commit(), "tx commit error")
+
+	return &w.res, nil
+}
+
+// ResCreateStream
+// 从 rows 中读取记录并且批量写入, 直到 rows 关闭或者 ctx 结束, ctx 结束时写入已经读取的记录
+// 每个批次在一个事务中写入, 出错时之前的批次已经提交, 返回已经提交的结果和错误
+// 参考 WithFlushInterval 限制记录的最长等待时间
+func (t *RestOrm) ResCreateStream(ctx context.Context, dbName, tbName string, rows <-chan map[string]interface{}, opts ...WriteOption) (res *WriteResult, err error) {
+	defer xerror.RespErr(&err)
+
+	p, w := t.newBatchWriter(dbName, tbName, opts)
+	defer p.release()
+
+	// 每个批次使用一个事务, 失败时结果恢复为之前提交的结果
+	var flush = func() error {
+		var committed = w.res
+		tx, err := p.db.Beginx()
+		if err == nil {
+			if err = w.flush(tx); err == nil {
+				err = tx.Commit()
+			} else {
+				tx.Rollback()
+			}
+		}
+
+		if err != nil {
+			w.res = committed
+		}
+		return err
+	}
+
+	// timeout 批次中第一条记录的等待时间, 批次为空时为 nil
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := flush(); err != nil {
+				return &w.res, err
+			}
+			return &w.res, ctx.Err()
+		case <-timeout:
+			timeout = nil
+			if err := flush(); err != nil {
+				return &w.res, err
+			}
+		case row, ok := <-rows:
+			if !ok {
+				return &w.res, flush()
+			}
+
+			if err := w.add(row, flush); err != nil {
+				return &w.res, err
+			}
+		}
+
+		switch {
+		case len(w.rows) == 0 && timeout != nil:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timeout = nil
+		case len(w.rows) > 0 && timeout == nil && w.opts.flushInterval > 0:
+			if timer == nil {
+				timer = time.NewTimer(w.opts.flushInterval)
+			} else {
+				timer.Reset(w.opts.flushInterval)
+			}
+			timeout = timer.C
+		}
+	}
+}
+
+// insertManyString 多条记录的 INSERT 语句, 所有记录的字段都是 names
+func (s *sqlBuilder) insertManyString(names []string, rows []map[string]interface{}, upsert string, returning []string) (string, error) {
+	var cols = make([]string, len(names))
+	for i, name := range names {
+		col, err := s.column(name)
+		if err != nil {
+			return "", err
+		}
+		cols[i] = col
+	}
+
+	for _, name := range returning {
+		if _, err := s.column(name); err != nil {
+			return "", err
+		}
+	}
+
+	var row = "(" + strings.TrimSuffix(strings.Repeat("?,", len(names)), ",") + ")"
+	var values = make([]string, len(rows))
+	for i, r := range rows {
+		values[i] = row
+		for _, name := range names {
+			s.args = append(s.args, r[name])
+		}
+	}
+
+	output, suffix := s.dialect.Returning(returning)
+	return s.statement("INSERT INTO", s._table(), "("+strings.Join(cols, ",")+")", output, "VALUES "+strings.Join(values, ","), upsert, suffix), nil
+}
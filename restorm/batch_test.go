@@ -0,0 +1,166 @@
+package restorm_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/restorm"
+)
+
+func TestResCreateBatch(t *testing.T) {
+	var orm = newOrm(t)
+
+	// 字段不同的记录分批写入, 每批最多 2 条
+	var rows = []map[string]interface{}{
+		{"name": "a", "age": 1},
+		{"name": "b", "age": 2},
+		{"name": "c", "age": 3},
+		{"name": "d"},
+		{"name": "e"},
+	}
+	res, err := orm.ResCreateBatch("test", "user", rows, restorm.WithBatchSize(2), restorm.WithReturningID("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Affected != 5 || !reflect.DeepEqual(res.IDs, []int64{1, 2, 3, 4, 5}) {
+		t.Fatal(res)
+	}
+
+	dts, err := orm.ResGetMany("test", "user", "id,name,age", "", "id", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dts) != 5 || dts[2]["name"] != "c" || dts[2]["age"] != int64(3) || dts[4]["name"] != "e" {
+		t.Fatal(dts)
+	}
+
+	// 冲突时只更新 name
+	rows = []map[string]interface{}{{"id": 1, "name": "x", "age": 10}, {"id": 6, "name": "f", "age": 6}}
+	res, err = orm.ResCreateBatch("test", "user", rows, restorm.WithUpsert("id"), restorm.WithUpsertUpdate("name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Affected != 2 {
+		t.Fatal(res)
+	}
+
+	dts, err = orm.ResGetMany("test", "user", "name,age", "", "", "", "", restorm.Eq("id", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(dts, []map[string]interface{}{{"name": "x", "age": int64(1)}}) {
+		t.Fatal(dts)
+	}
+
+	// 冲突时忽略
+	rows = []map[string]interface{}{{"id": 1, "name": "y"}}
+	if _, err := orm.ResCreateBatch("test", "user", rows, restorm.WithUpsert("id"), restorm.WithUpsertUpdate()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := orm.ResCount("test", "user", restorm.Eq("name", "x")); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+
+	// sqlite 不能通过 LastInsertId 返回 upsert 的 ID
+	if _, err := orm.ResCreateBatch("test", "user", rows, restorm.WithUpsert("id"), restorm.WithReturningID("id")); err == nil {
+		t.Fatal("upsert with returning id should fail")
+	}
+
+	// 出错时整体回滚
+	rows = []map[string]interface{}{{"id": 7, "name": "g"}, {"id": 1, "name": "h"}}
+	if _, err := orm.ResCreateBatch("test", "user", rows, restorm.WithBatchSize(1)); err == nil {
+		t.Fatal("duplicate primary key should fail")
+	}
+
+	if n, err := orm.ResCount("test", "user"); err != nil || n != 6 {
+		t.Fatal(n, err)
+	}
+
+	if _, err := orm.ResCreateBatch("test", "user", []map[string]interface{}{{"name; drop": "a"}}); err == nil {
+		t.Fatal("invalid column should fail")
+	}
+}
+
+func TestResCreateStream(t *testing.T) {
+	var orm = newOrm(t)
+
+	var rows = make(chan map[string]interface{})
+	go func() {
+		defer close(rows)
+		for i := 0; i < 5; i++ {
+			rows <- map[string]interface{}{"name": "a", "age": i}
+		}
+	}()
+
+	res, err := orm.ResCreateStream(context.Background(), "test", "user", rows, restorm.WithBatchSize(2), restorm.WithReturningID("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Affected != 5 || !reflect.DeepEqual(res.IDs, []int64{1, 2, 3, 4, 5}) {
+		t.Fatal(res)
+	}
+
+	// ctx 结束时写入已经读取的记录
+	var ctx, cancel = context.WithCancel(context.Background())
+	rows = make(chan map[string]interface{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			rows <- map[string]interface{}{"name": "b"}
+		}
+		cancel()
+	}()
+
+	res, err = orm.ResCreateStream(ctx, "test", "user", rows, restorm.WithBatchSize(2))
+	if err != context.Canceled {
+		t.Fatal(err)
+	}
+
+	if res.Affected != 3 {
+		t.Fatal(res)
+	}
+
+	if n, err := orm.ResCount("test", "user", restorm.Eq("name", "b")); err != nil || n != 3 {
+		t.Fatal(n, err)
+	}
+
+	// 批次未满时最多等待 WithFlushInterval
+	rows = make(chan map[string]interface{})
+	var done = make(chan *restorm.WriteResult)
+	go func() {
+		res, err := orm.ResCreateStream(context.Background(), "test", "user", rows, restorm.WithBatchSize(100), restorm.WithFlushInterval(10*time.Millisecond))
+		if err != nil {
+			t.Error(err)
+		}
+		done <- res
+	}()
+
+	rows <- map[string]interface{}{"name": "c"}
+	for i := 0; ; i++ {
+		n, err := orm.ResCount("test", "user", restorm.Eq("name", "c"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n == 1 {
+			break
+		}
+
+		if i > 100 {
+			t.Fatal("row is not flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(rows)
+	if res := <-done; res.Affected != 1 {
+		t.Fatal(res)
+	}
+}
@@ -1,13 +1,11 @@
 package restorm
 
-import "github.com/jmoiron/sqlx"
-
 type converter struct {
 	Name      string
 	converter func(interface{}) interface{}
 }
 
-//Config is database connection configuration
+// Config is database connection configuration
 type Config struct {
 	Enable       bool   `toml:"enable" json:"enable"`
 	Driver       string `toml:"driver" json:"driver"`
@@ -16,7 +14,6 @@ type Config struct {
 	MaxIdleConns int    `toml:"max_idle_conns" json:"max_idle_conns"`
 	MaxLifetime  int    `toml:"max_lefttime" json:"max_lefttime"`
 	ShowSql      bool   `toml:"show_sql" json:"show_sql"`
-
-	db   *sqlx.DB
-	colT map[string]map[string]*converter
+	// Dialect 生成 SQL 的方言, 为空时根据 Driver 确定, 参考 DialectByDriver
+	Dialect string `toml:"dialect" json:"dialect"`
 }
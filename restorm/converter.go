@@ -2,8 +2,8 @@ package restorm
 
 import (
 	"database/sql"
-	"math/big"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pubgo/xerror"
@@ -11,21 +11,66 @@ import (
 
 func _ToInt(p string) int {
 	r, err := strconv.Atoi(p)
-	xerror.PanicM(err, "can not convert %s to int", p)
+	xerror.PanicF(err, "can not convert %s to int", p)
 	return r
 }
 
 func _ToFloat(p string) float64 {
 	f, err := strconv.ParseFloat(p, 0)
-	xerror.PanicM(err, "parse float type error,input(%s)", p)
+	xerror.PanicF(err, "parse float type error,input(%s)", p)
 	return f
 }
 
+func _ToBool(p string) bool {
+	// postgresql 的文本格式为 t 和 f, mysql 的 bit(1) 为 \x00 和 \x01
+	switch p {
+	case "\x00":
+		return false
+	case "\x01":
+		return true
+	}
+
+	b, err := strconv.ParseBool(p)
+	xerror.PanicF(err, "parse bool type error,input(%s)", p)
+	return b
+}
+
+// 字符串格式的时间, 不同数据库的格式不同
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+func _ToTime(p string) time.Time {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, p); err == nil {
+			return t
+		}
+	}
+
+	xerror.Assert(true, "time parse error,input(%s)", p)
+	return time.Time{}
+}
+
+// typeName 数据库的字段类型, 去掉长度和 unsigned 等修饰, 例如 VARCHAR(255) 转换为 varchar
+func typeName(name string) string {
+	name = strings.ToLower(name)
+	if i := strings.IndexAny(name, "( "); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
 // Converter 转换
 func Converter(sqlType string) func(interface{}) interface{} {
 	return func(dt interface{}) interface{} {
 		switch sqlType {
-		case "tinyint", "int", "smallint", "mediumint", "bigint":
+		case "tinyint", "int", "smallint", "mediumint", "bigint", "integer",
+			"int2", "int4", "int8", "smallserial", "serial", "bigserial":
 			if _isNone(dt) {
 				return 0
 			}
@@ -33,12 +78,15 @@ func Converter(sqlType string) func(interface{}) interface{} {
 			switch _v := dt.(type) {
 			case int, int64, int32, int16, int8, sql.NullInt64:
 				return _v
+			case float64:
+				return int64(_v)
 			case string:
 				return _ToInt(_v)
 			case []byte:
-				return big.NewInt(0).SetBytes(_v).Int64()
+				return _ToInt(string(_v))
 			}
-		case "char", "enum", "varchar", "longtext", "mediumtext", "text", "tinytext":
+		case "char", "enum", "set", "varchar", "longtext", "mediumtext", "text", "tinytext",
+			"character", "nchar", "nvarchar", "ntext", "citext", "name", "uuid", "uniqueidentifier", "json", "jsonb", "xml":
 			if _isNone(dt) {
 				return ""
 			}
@@ -50,25 +98,25 @@ func Converter(sqlType string) func(interface{}) interface{} {
 				return string(_v)
 			case int:
 				return strconv.Itoa(_v)
+			case int64:
+				return strconv.FormatInt(_v, 10)
 			}
-		case "date", "datetime", "time", "timestamp":
+		case "date", "datetime", "time", "timestamp",
+			"datetime2", "smalldatetime", "datetimeoffset", "timestamptz", "timetz":
 			if _isNone(dt) {
 				return time.Now()
 			}
 
 			switch _v := dt.(type) {
 			case string:
-				t, err := time.Parse(time.RFC3339Nano, _v)
-				xerror.PanicM(err, "time parse error")
-				return t
+				return _ToTime(_v)
 			case time.Time:
 				return _v
 			case []byte:
-				t, err := time.Parse(time.RFC3339Nano, string(_v))
-				xerror.PanicM(err, "time parse error")
-				return t
+				return _ToTime(string(_v))
 			}
-		case "decimal", "double", "float":
+		case "decimal", "double", "float", "real", "numeric",
+			"float4", "float8", "money", "smallmoney", "number":
 			if _isNone(dt) {
 				return 0.0
 			}
@@ -76,13 +124,15 @@ func Converter(sqlType string) func(interface{}) interface{} {
 			switch _v := dt.(type) {
 			case float64, float32:
 				return _v
+			case int64:
+				return float64(_v)
 			case string:
 				return _ToFloat(_v)
 			case []byte:
 				return _ToFloat(string(_v))
 			}
 
-		case "binary", "blob", "longblob", "mediumblob", "varbinary":
+		case "binary", "blob", "tinyblob", "longblob", "mediumblob", "varbinary", "bytea", "image":
 			if _isNone(dt) {
 				return ""
 			}
@@ -93,9 +143,28 @@ func Converter(sqlType string) func(interface{}) interface{} {
 			case []byte:
 				return string(_v)
 			}
+		case "bool", "boolean", "bit":
+			if _isNone(dt) {
+				return false
+			}
+
+			switch _v := dt.(type) {
+			case bool:
+				return _v
+			case int64:
+				return _v != 0
+			case string:
+				return _ToBool(_v)
+			case []byte:
+				return _ToBool(string(_v))
+			}
 		default:
-			xerror.PanicT(true, "unknown type")
+			// 未知的类型保持原样, 例如 sqlite 中没有声明类型的表达式
+			if _v, ok := dt.([]byte); ok {
+				return string(_v)
+			}
+			return dt
 		}
-		return nil
+		return dt
 	}
 }
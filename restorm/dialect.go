@@ -0,0 +1,20 @@
+package restorm
+
+import (
+	"github.com/pubgo/x/xservice/db/dialect"
+)
+
+// Dialect
+// 不同数据库生成 SQL 的差异, 使用 xservice/db/dialect
+// 生成的 SQL 中参数先统一使用 ?, 最后通过 dialect.Rebind 替换
+type Dialect = dialect.Dialect
+
+// DialectByDriver
+// 根据 database/sql 的驱动名或者 dialect 的名字获取 Dialect, 未知的驱动使用 mysql
+func DialectByDriver(driver string) Dialect {
+	var d = dialect.GetDialectByDriver(driver)
+	if d.GetName() == "common" {
+		return dialect.GetDialect()
+	}
+	return d
+}
@@ -0,0 +1,162 @@
+package restorm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xservice/db/dialect"
+)
+
+var userCols = map[string]*converter{"id": nil, "name": nil, "age": nil}
+
+func TestDialectSelect(t *testing.T) {
+	for _, c := range []struct {
+		driver        string
+		limit, offset string
+		order         string
+		want          string
+	}{
+		{"mysql", "10", "20", "-id", "SELECT `name` FROM `user` FORCE INDEX (`idx_age`) WHERE ((`age` > ?) AND (`name` IN (?,?))) ORDER BY `id` DESC LIMIT 10 OFFSET 20;"},
+		{"mysql", "", "20", "", "SELECT `name` FROM `user` FORCE INDEX (`idx_age`) WHERE ((`age` > ?) AND (`name` IN (?,?))) LIMIT 18446744073709551615 OFFSET 20;"},
+		{"sqlite3", "", "20", "", "SELECT `name` FROM `user` WHERE ((`age` > ?) AND (`name` IN (?,?))) LIMIT -1 OFFSET 20;"},
+		{"postgres", "10", "", "name", `SELECT "name" FROM "user" WHERE (("age" > $1) AND ("name" IN ($2,$3))) ORDER BY "name" ASC LIMIT 10;`},
+		{"sqlserver", "10", "", "", "SELECT TOP 10 [name] FROM [user] WITH (INDEX([idx_age])) WHERE (([age] > @p1) AND ([name] IN (@p2,@p3)));"},
+		{"mssql", "10", "20", "", "SELECT [name] FROM [user] WITH (INDEX([idx_age])) WHERE (([age] > @p1) AND ([name] IN (@p2,@p3))) ORDER BY (SELECT NULL) OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY;"},
+		{"mssql", "", "20", "id", "SELECT [name] FROM [user] WITH (INDEX([idx_age])) WHERE (([age] > @p1) AND ([name] IN (@p2,@p3))) ORDER BY [id] ASC OFFSET 20 ROWS;"},
+	} {
+		var s = &sqlBuilder{table: "user", forceIndex: "idx_age", cols: userCols, dialect: DialectByDriver(c.driver)}
+		if err := s.setFields("name"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.setOrderBy(c.order); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.setPage(c.limit, c.offset); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Where(And(Gt("age", 1), In("name", "a", "b"))); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := s.queryString(); got != c.want {
+			t.Errorf("%s:\n got %s\nwant %s", c.driver, got, c.want)
+		}
+
+		if !reflect.DeepEqual(s.args, []interface{}{1, "a", "b"}) {
+			t.Errorf("%s: %v", c.driver, s.args)
+		}
+	}
+}
+
+func TestDialectWrite(t *testing.T) {
+	var data = map[string]interface{}{"name": "a", "age": 1}
+	for _, c := range []struct {
+		driver         string
+		insert, update string
+	}{
+		{"mysql", "INSERT INTO `user` (`age`,`name`) VALUES(?,?);", "UPDATE `user` SET `age`=?,`name`=? WHERE (`id` = ?);"},
+		{"postgresql", `INSERT INTO "user" ("age","name") VALUES($1,$2) RETURNING "id";`, `UPDATE "user" SET "age"=$1,"name"=$2 WHERE ("id" = $3);`},
+		{"mssql", "INSERT INTO [user] ([age],[name]) OUTPUT INSERTED.[id] VALUES(@p1,@p2);", "UPDATE [user] SET [age]=@p1,[name]=@p2 WHERE ([id] = @p3);"},
+	} {
+		var d = DialectByDriver(c.driver)
+		var s = &sqlBuilder{table: "user", cols: userCols, dialect: d}
+		if got, err := s.insertString(data, "id"); err != nil || got != c.insert {
+			t.Errorf("%s:\n got %s %v\nwant %s", c.driver, got, err, c.insert)
+		}
+
+		s = &sqlBuilder{table: "user", cols: userCols, dialect: d}
+		if err := s.Where(Eq("id", 2)); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := s.updateString(data); err != nil || got != c.update {
+			t.Errorf("%s:\n got %s %v\nwant %s", c.driver, got, err, c.update)
+		}
+
+		if !reflect.DeepEqual(s.args, []interface{}{1, "a", 2}) {
+			t.Errorf("%s: %v", c.driver, s.args)
+		}
+	}
+}
+
+func TestDialectUpsert(t *testing.T) {
+	var rows = []map[string]interface{}{{"id": 1, "name": "a"}, {"id": 2, "name": "b"}}
+	for _, c := range []struct {
+		driver           string
+		conflict, update []string
+		want             string
+	}{
+		{"mysql", []string{"id"}, []string{"name"}, "INSERT INTO `user` (`id`,`name`) VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`);"},
+		{"mysql", []string{"id"}, nil, "INSERT INTO `user` (`id`,`name`) VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE `id`=`id`;"},
+		{"sqlite", []string{"id"}, []string{"name"}, "INSERT INTO `user` (`id`,`name`) VALUES (?,?),(?,?) ON CONFLICT (`id`) DO UPDATE SET `name`=excluded.`name`;"},
+		{"postgresql", []string{"id"}, nil, `INSERT INTO "user" ("id","name") VALUES ($1,$2),($3,$4) ON CONFLICT ("id") DO NOTHING;`},
+		{"mssql", nil, nil, "INSERT INTO [user] ([id],[name]) VALUES (@p1,@p2),(@p3,@p4);"},
+	} {
+		var d = DialectByDriver(c.driver)
+		var s = &sqlBuilder{table: "user", cols: userCols, dialect: d}
+		got, err := s.insertManyString([]string{"id", "name"}, rows, d.Upsert(c.conflict, c.update), nil)
+		if err != nil || got != c.want {
+			t.Errorf("%s:\n got %s %v\nwant %s", c.driver, got, err, c.want)
+		}
+
+		if !reflect.DeepEqual(s.args, []interface{}{1, "a", 2, "b"}) {
+			t.Errorf("%s: %v", c.driver, s.args)
+		}
+	}
+
+	if got := DialectByDriver("postgresql").Upsert(nil, []string{"name"}); got != "" {
+		t.Fatal(got)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	for _, c := range []struct {
+		driver, query, want string
+	}{
+		{"postgres", `SELECT * FROM "t?" WHERE a = ? AND b = 'it''s ?' AND c ?? 'k' AND d ??| ?;`, `SELECT * FROM "t?" WHERE a = $1 AND b = 'it''s ?' AND c ? 'k' AND d ?| $2;`},
+		{"postgres", "SELECT ? -- ?\n, /* ? */ ?;", "SELECT $1 -- ?\n, /* ? */ $2;"},
+		{"mssql", "SELECT `?`, N'中?', ?;", "SELECT `?`, N'中?', @p1;"},
+		{"mysql", "SELECT '?', ?;", "SELECT '?', ?;"},
+	} {
+		if got := dialect.Rebind(DialectByDriver(c.driver), c.query); got != c.want {
+			t.Errorf("%s:\n got %s\nwant %s", c.driver, got, c.want)
+		}
+	}
+}
+
+func TestConverter(t *testing.T) {
+	var ts = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, c := range []struct {
+		typ  string
+		in   interface{}
+		want interface{}
+	}{
+		{"INT4", []byte("12"), 12},
+		{"BIGINT UNSIGNED", int64(12), int64(12)},
+		{"character varying", []byte("a"), "a"},
+		{"NVARCHAR(10)", "a", "a"},
+		{"float8", int64(1), float64(1)},
+		{"NUMERIC(10,2)", []byte("1.5"), 1.5},
+		{"bool", []byte("t"), true},
+		{"BOOLEAN", int64(0), false},
+		{"bit", []byte{1}, true},
+		{"timestamptz", "2020-01-02 03:04:05+00", ts},
+		{"DATETIME", []byte("2020-01-02 03:04:05"), ts},
+		{"bytea", []byte("x"), "x"},
+		{"", []byte("x"), "x"},
+		{"geometry", int64(1), int64(1)},
+	} {
+		got := Converter(typeName(c.typ))(c.in)
+		if tm, ok := got.(time.Time); ok {
+			got = tm.UTC()
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s(%v): got %#v, want %#v", c.typ, c.in, got, c.want)
+		}
+	}
+}
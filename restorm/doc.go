@@ -1,4 +1,3 @@
 package restorm
 
-
 // https://github.com/go-gorp/gorp
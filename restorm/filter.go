@@ -0,0 +1,506 @@
+package restorm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidFilter 过滤条件, 字段或者排序等参数不合法
+var ErrInvalidFilter = errors.New("restorm: invalid filter")
+
+func invalidFilter(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidFilter, fmt.Sprintf(format, args...))
+}
+
+// 过滤条件的操作符, 也是 JSON 和查询参数中的名字
+const (
+	opEq      = "eq"
+	opNe      = "ne"
+	opGt      = "gt"
+	opGte     = "gte"
+	opLt      = "lt"
+	opLte     = "lte"
+	opIn      = "in"
+	opLike    = "like"
+	opBetween = "between"
+	opNull    = "null"
+	opAnd     = "and"
+	opOr      = "or"
+	opNot     = "not"
+)
+
+var compareOps = map[string]string{
+	opEq:   "=",
+	opNe:   "<>",
+	opGt:   ">",
+	opGte:  ">=",
+	opLt:   "<",
+	opLte:  "<=",
+	opLike: "LIKE",
+}
+
+// maxFilterDepth 过滤条件的最大嵌套层数
+const maxFilterDepth = 32
+
+// Cond
+// 结构化的过滤条件, 可以作为 ResGetMany 等方法的 filter[0]
+// 字段在生成 SQL 时根据表结构校验, 值全部作为参数传递
+type Cond struct {
+	op     string
+	field  string
+	values []interface{}
+	conds  []*Cond
+}
+
+func compare(op, field string, v interface{}) *Cond {
+	return &Cond{op: op, field: field, values: []interface{}{v}}
+}
+
+// Eq field = v
+func Eq(field string, v interface{}) *Cond { return compare(opEq, field, v) }
+
+// Ne field <> v
+func Ne(field string, v interface{}) *Cond { return compare(opNe, field, v) }
+
+// Gt field > v
+func Gt(field string, v interface{}) *Cond { return compare(opGt, field, v) }
+
+// Gte field >= v
+func Gte(field string, v interface{}) *Cond { return compare(opGte, field, v) }
+
+// Lt field < v
+func Lt(field string, v interface{}) *Cond { return compare(opLt, field, v) }
+
+// Lte field <= v
+func Lte(field string, v interface{}) *Cond { return compare(opLte, field, v) }
+
+// Like field LIKE pattern
+func Like(field string, pattern string) *Cond { return compare(opLike, field, pattern) }
+
+// In field IN (vs...)
+func In(field string, vs ...interface{}) *Cond {
+	return &Cond{op: opIn, field: field, values: vs}
+}
+
+// Between field BETWEEN a AND b
+func Between(field string, a, b interface{}) *Cond {
+	return &Cond{op: opBetween, field: field, values: []interface{}{a, b}}
+}
+
+// IsNull field IS NULL
+func IsNull(field string) *Cond {
+	return &Cond{op: opNull, field: field}
+}
+
+// And 所有条件都满足, 没有条件时为真
+func And(conds ...*Cond) *Cond {
+	return &Cond{op: opAnd, conds: conds}
+}
+
+// Or 任意一个条件满足, 没有条件时为假
+func Or(conds ...*Cond) *Cond {
+	return &Cond{op: opOr, conds: conds}
+}
+
+// Not 条件不满足
+func Not(c *Cond) *Cond {
+	return &Cond{op: opNot, conds: []*Cond{c}}
+}
+
+// Fields 条件中引用的所有字段
+func (c *Cond) Fields() []string {
+	var fields []string
+	var seen = make(map[string]bool)
+	var walk func(c *Cond)
+	walk = func(c *Cond) {
+		if c == nil {
+			return
+		}
+
+		if c.field != "" && !seen[c.field] {
+			seen[c.field] = true
+			fields = append(fields, c.field)
+		}
+
+		for _, sub := range c.conds {
+			walk(sub)
+		}
+	}
+	walk(c)
+	return fields
+}
+
+// build 根据表的字段生成 SQL, cols 为 nil 时只校验字段的格式
+func (c *Cond) build(d Dialect, cols map[string]*converter) (string, []interface{}, error) {
+	var buf strings.Builder
+	var args []interface{}
+	if err := c.write(&buf, &args, d, cols, 0); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), args, nil
+}
+
+func (c *Cond) write(buf *strings.Builder, args *[]interface{}, d Dialect, cols map[string]*converter, depth int) error {
+	if c == nil {
+		return invalidFilter("nil condition")
+	}
+
+	if depth > maxFilterDepth {
+		return invalidFilter("too deeply nested")
+	}
+
+	switch c.op {
+	case opAnd, opOr:
+		if len(c.conds) == 0 {
+			if c.op == opAnd {
+				buf.WriteString("1=1")
+			} else {
+				buf.WriteString("1=0")
+			}
+			return nil
+		}
+
+		for i, sub := range c.conds {
+			if i > 0 {
+				buf.WriteString(" " + strings.ToUpper(c.op) + " ")
+			}
+
+			buf.WriteString("(")
+			if err := sub.write(buf, args, d, cols, depth+1); err != nil {
+				return err
+			}
+			buf.WriteString(")")
+		}
+		return nil
+	case opNot:
+		if len(c.conds) != 1 {
+			return invalidFilter("not requires one condition")
+		}
+
+		buf.WriteString("NOT (")
+		if err := c.conds[0].write(buf, args, d, cols, depth+1); err != nil {
+			return err
+		}
+		buf.WriteString(")")
+		return nil
+	}
+
+	column, err := quoteColumn(d, c.field, cols)
+	if err != nil {
+		return err
+	}
+
+	switch c.op {
+	case opIn:
+		if len(c.values) == 0 {
+			// IN () 不是合法的 SQL, 没有值时没有记录满足
+			buf.WriteString("1=0")
+			return nil
+		}
+		buf.WriteString(column + " IN (" + strings.TrimSuffix(strings.Repeat("?,", len(c.values)), ",") + ")")
+	case opBetween:
+		if len(c.values) != 2 {
+			return invalidFilter("between requires two values of %s", c.field)
+		}
+		buf.WriteString(column + " BETWEEN ? AND ?")
+	case opNull:
+		buf.WriteString(column + " IS NULL")
+	default:
+		sqlOp, ok := compareOps[c.op]
+		if !ok {
+			return invalidFilter("unknown operator %q of %s", c.op, c.field)
+		}
+
+		if len(c.values) != 1 {
+			return invalidFilter("%s requires one value of %s", c.op, c.field)
+		}
+		buf.WriteString(column + " " + sqlOp + " ?")
+	}
+
+	*args = append(*args, c.values...)
+	return nil
+}
+
+// ParseFilter
+// 解析 JSON 格式的过滤条件, 对象中的多个键为 and 的关系
+// 空的对象, 空的 and, or 和 in 不合法, 避免解析为对整个表生效的条件
+//
+//	{"name": "a"}                          name = 'a'
+//	{"age": {"gt": 18, "lte": 60}}         age > 18 AND age <= 60
+//	{"id": [1, 2]}                         id IN (1, 2)
+//	{"deleted_at": null}                   deleted_at IS NULL
+//	{"age": {"between": [18, 60]}}
+//	{"age": {"null": false}}               age IS NOT NULL
+//	{"or": [{"id": 1}, {"name": {"like": "a%"}}]}
+//	{"not": {"id": {"in": [1, 2]}}}
+func ParseFilter(data []byte) (*Cond, error) {
+	var dec = json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, invalidFilter("%s", err)
+	}
+	return parseObject(v, 0)
+}
+
+func parseObject(v interface{}, depth int) (*Cond, error) {
+	if depth > maxFilterDepth {
+		return nil, invalidFilter("too deeply nested")
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, invalidFilter("condition must be an object")
+	}
+
+	if len(obj) == 0 {
+		return nil, invalidFilter("empty condition")
+	}
+
+	var conds []*Cond
+	for _, k := range sortedKeys(obj) {
+		var val = obj[k]
+		switch k {
+		case opAnd, opOr:
+			list, ok := val.([]interface{})
+			if !ok {
+				return nil, invalidFilter("%s requires an array", k)
+			}
+
+			if len(list) == 0 {
+				return nil, invalidFilter("%s requires at least one condition", k)
+			}
+
+			var subs = make([]*Cond, 0, len(list))
+			for _, item := range list {
+				sub, err := parseObject(item, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				subs = append(subs, sub)
+			}
+			conds = append(conds, &Cond{op: k, conds: subs})
+		case opNot:
+			sub, err := parseObject(val, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, Not(sub))
+		default:
+			cs, err := parseField(k, val)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cs...)
+		}
+	}
+
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return And(conds...), nil
+}
+
+func parseField(field string, v interface{}) ([]*Cond, error) {
+	switch v := v.(type) {
+	case nil:
+		return []*Cond{IsNull(field)}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, invalidFilter("in requires at least one value of %s", field)
+		}
+		return []*Cond{In(field, jsonValues(v)...)}, nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil, invalidFilter("no operator of %s", field)
+		}
+
+		var conds []*Cond
+		for _, op := range sortedKeys(v) {
+			c, err := parseOp(field, op, v[op])
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, c)
+		}
+		return conds, nil
+	default:
+		return []*Cond{Eq(field, jsonValue(v))}, nil
+	}
+}
+
+func parseOp(field, op string, v interface{}) (*Cond, error) {
+	switch op {
+	case opIn, opBetween:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, invalidFilter("%s of %s requires an array", op, field)
+		}
+
+		if op == opBetween {
+			if len(list) != 2 {
+				return nil, invalidFilter("between requires two values of %s", field)
+			}
+			return Between(field, jsonValue(list[0]), jsonValue(list[1])), nil
+		}
+
+		if len(list) == 0 {
+			return nil, invalidFilter("in requires at least one value of %s", field)
+		}
+		return In(field, jsonValues(list)...), nil
+	case opNull:
+		null, ok := v.(bool)
+		if !ok {
+			return nil, invalidFilter("null of %s requires a bool", field)
+		}
+		return nullCond(field, null), nil
+	}
+
+	if _, ok := compareOps[op]; !ok {
+		return nil, invalidFilter("unknown operator %q of %s", op, field)
+	}
+
+	switch v.(type) {
+	case nil, []interface{}, map[string]interface{}:
+		return nil, invalidFilter("%s of %s requires a scalar value", op, field)
+	}
+	return compare(op, field, jsonValue(v)), nil
+}
+
+func nullCond(field string, null bool) *Cond {
+	if null {
+		return IsNull(field)
+	}
+	return Not(IsNull(field))
+}
+
+// jsonValue json.Number 转换为 int64 或者 float64
+func jsonValue(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
+
+func jsonValues(vs []interface{}) []interface{} {
+	var res = make([]interface{}, len(vs))
+	for i, v := range vs {
+		res[i] = jsonValue(v)
+	}
+	return res
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// QueryFilter 查询参数中 JSON 格式过滤条件的参数名
+const QueryFilter = "filter"
+
+// ParseQuery
+// 解析查询参数中的过滤条件, 所有条件为 and 的关系, reserved 中的参数不作为过滤条件
+//
+//	name=a               name = 'a'
+//	age__gt=18           age > 18, 操作符为 eq, ne, gt, gte, lt, lte, like
+//	id__in=1,2,3         id IN (1, 2, 3)
+//	age__between=18,60   age BETWEEN 18 AND 60
+//	deleted_at__null=1   deleted_at IS NULL, 值为 false 时为 IS NOT NULL
+//	filter={"or":[...]}  JSON 格式, 参考 ParseFilter
+//
+// 没有过滤条件时返回 nil
+func ParseQuery(query url.Values, reserved ...string) (*Cond, error) {
+	var skip = make(map[string]bool, len(reserved))
+	for _, k := range reserved {
+		skip[k] = true
+	}
+
+	var keys = make([]string, 0, len(query))
+	for k := range query {
+		if !skip[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var conds []*Cond
+	for _, k := range keys {
+		for _, v := range query[k] {
+			if k == QueryFilter {
+				c, err := ParseFilter([]byte(v))
+				if err != nil {
+					return nil, err
+				}
+				conds = append(conds, c)
+				continue
+			}
+
+			var field, op = k, opEq
+			if i := strings.Index(k, "__"); i >= 0 {
+				field, op = k[:i], k[i+2:]
+			}
+
+			c, err := parseQueryOp(field, op, v)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, c)
+		}
+	}
+
+	switch len(conds) {
+	case 0:
+		return nil, nil
+	case 1:
+		return conds[0], nil
+	}
+	return And(conds...), nil
+}
+
+func parseQueryOp(field, op, v string) (*Cond, error) {
+	switch op {
+	case opIn:
+		var vs []interface{}
+		for _, s := range strings.Split(v, ",") {
+			vs = append(vs, s)
+		}
+		return In(field, vs...), nil
+	case opBetween:
+		var vs = strings.Split(v, ",")
+		if len(vs) != 2 {
+			return nil, invalidFilter("between requires two values of %s", field)
+		}
+		return Between(field, vs[0], vs[1]), nil
+	case opNull:
+		null, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidFilter("invalid value %q of %s__null", v, field)
+		}
+		return nullCond(field, null), nil
+	}
+
+	if _, ok := compareOps[op]; !ok {
+		return nil, invalidFilter("unknown operator %q of %s", op, field)
+	}
+	return compare(op, field, v), nil
+}
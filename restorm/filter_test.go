@@ -0,0 +1,142 @@
+package restorm_test
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pubgo/x/restorm"
+)
+
+func names(t *testing.T, orm *restorm.RestOrm, order string, filter ...interface{}) []string {
+	t.Helper()
+
+	dts, err := orm.ResGetMany("test", "user", "name", "", order, "", "", filter...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res = []string{}
+	for _, dt := range dts {
+		res = append(res, dt["name"].(string))
+	}
+	return res
+}
+
+func TestFilter(t *testing.T) {
+	var orm = newOrm(t)
+	err := orm.ResCreateMany("test", "user",
+		map[string]interface{}{"id": 1, "name": "a", "age": 10},
+		map[string]interface{}{"id": 2, "name": "b", "age": 20},
+		map[string]interface{}{"id": 3, "name": "c", "age": 30},
+		map[string]interface{}{"id": 4, "name": "d"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		cond *restorm.Cond
+		want []string
+	}{
+		{restorm.Eq("name", "b"), []string{"b"}},
+		{restorm.Gt("age", 10), []string{"b", "c"}},
+		{restorm.In("id", 1, 3), []string{"a", "c"}},
+		{restorm.In("id"), []string{}},
+		{restorm.Between("age", 15, 30), []string{"b", "c"}},
+		{restorm.IsNull("age"), []string{"d"}},
+		{restorm.Not(restorm.IsNull("age")), []string{"a", "b", "c"}},
+		{restorm.Or(restorm.Eq("id", 1), restorm.And(restorm.Like("name", "%c%"), restorm.Lte("age", 30))), []string{"a", "c"}},
+		{restorm.And(), []string{"a", "b", "c", "d"}},
+		{restorm.Or(), []string{}},
+	} {
+		if got := names(t, orm, "id", c.cond); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %v, want %v", c.cond.Fields(), got, c.want)
+		}
+	}
+
+	cond, err := restorm.ParseFilter([]byte(`{"age": {"gte": 10, "lt": 30}, "or": [{"name": "a"}, {"id": [2, 3]}], "not": {"name": null}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(t, orm, "-id", cond); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Fatal(got)
+	}
+
+	var fields = cond.Fields()
+	sort.Strings(fields)
+	if !reflect.DeepEqual(fields, []string{"age", "id", "name"}) {
+		t.Fatal(fields)
+	}
+
+	cond, err = restorm.ParseQuery(url.Values{"age__between": {"10,20"}, "name__ne": {"a"}, "limit": {"1"}}, "limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(t, orm, "id", cond); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatal(got)
+	}
+
+	if cond, err = restorm.ParseQuery(url.Values{"limit": {"1"}}, "limit"); cond != nil || err != nil {
+		t.Fatal(cond, err)
+	}
+
+	// 字段和条件都根据表结构校验
+	for _, c := range []struct {
+		fields, group, order, limit string
+		filter                      []interface{}
+	}{
+		{fields: "name from user; --"},
+		{fields: "unknown"},
+		{group: "age; drop table user"},
+		{order: "id; drop table user"},
+		{order: "id desc nulls"},
+		{order: "-unknown"},
+		{limit: "1; drop table user"},
+		{limit: "-1"},
+		{filter: []interface{}{restorm.Eq("id = 1 OR 1", 1)}},
+		{filter: []interface{}{restorm.Eq("unknown", 1)}},
+		{filter: []interface{}{restorm.Eq("id", 1), 2}},
+		{filter: []interface{}{1}},
+	} {
+		_, err := orm.ResGetMany("test", "user", c.fields, c.group, c.order, c.limit, "", c.filter...)
+		if !errors.Is(err, restorm.ErrInvalidFilter) {
+			t.Errorf("%+v: %v", c, err)
+		}
+	}
+
+	for _, data := range []string{
+		`[]`,
+		`{"age": {}}`,
+		`{"age": {"regexp": "1"}}`,
+		`{"age": {"gt": [1]}}`,
+		`{"age": {"between": [1]}}`,
+		`{"age": {"null": "yes"}}`,
+		`{"or": {"id": 1}}`,
+		`{}`,
+		`{"and": []}`,
+		`{"or": [{}]}`,
+		`{"id": []}`,
+		`{"not": {"id": {"in": []}}}`,
+	} {
+		if _, err := restorm.ParseFilter([]byte(data)); !errors.Is(err, restorm.ErrInvalidFilter) {
+			t.Errorf("%s: %v", data, err)
+		}
+	}
+
+	if err := orm.ResUpdateMany("test", "user", map[string]interface{}{"age` = 0, `name": 1}, restorm.Eq("id", 1)); !errors.Is(err, restorm.ErrInvalidFilter) {
+		t.Fatal(err)
+	}
+
+	if err := orm.ResDeleteMany("test", "user", restorm.Gte("age", 20)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(t, orm, "id desc"); !reflect.DeepEqual(got, []string{"d", "a"}) {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,450 @@
+package restorm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Op 网关开放的操作
+type Op uint8
+
+const (
+	// OpGet GET /:db/:table
+	OpGet Op = 1 << iota
+	// OpCount GET /:db/:table/count
+	OpCount
+	// OpCreate POST /:db/:table
+	OpCreate
+	// OpUpdate PATCH /:db/:table
+	OpUpdate
+	// OpDelete DELETE /:db/:table
+	OpDelete
+
+	OpRead = OpGet | OpCount
+	OpAll  = OpRead | OpCreate | OpUpdate | OpDelete
+)
+
+const (
+	defaultLimit    = 20
+	defaultMaxLimit = 100
+)
+
+// 查询参数中的保留字段, 其他参数都作为过滤条件
+const (
+	paramFields = "fields"
+	paramOrder  = "order"
+	paramLimit  = "limit"
+	paramOffset = "offset"
+)
+
+type tableRule struct {
+	ops      Op
+	columns  map[string]bool
+	maxLimit int
+}
+
+// TableOption 表的开放规则
+type TableOption func(r *tableRule)
+
+// WithOps 开放的操作, 默认为 OpRead
+func WithOps(ops Op) TableOption {
+	return func(r *tableRule) {
+		r.ops = ops
+	}
+}
+
+// WithColumns 开放的字段, 默认为表的所有字段
+func WithColumns(columns ...string) TableOption {
+	return func(r *tableRule) {
+		r.columns = make(map[string]bool, len(columns))
+		for _, c := range columns {
+			r.columns[c] = true
+		}
+	}
+}
+
+// WithMaxLimit 单次查询的最大条数, 默认为 100
+func WithMaxLimit(n int) TableOption {
+	return func(r *tableRule) {
+		r.maxLimit = n
+	}
+}
+
+// Gateway
+// 将 RestOrm 中的表通过 HTTP 开放为增删改查接口, 只有通过 Allow 开放的表可以访问
+//
+//	GET    /:db/:table?fields=id,name&order=-id&limit=20&offset=0&age__gt=18
+//	GET    /:db/:table/count?age__gt=18
+//	POST   /:db/:table             body 为对象或者对象数组
+//	PATCH  /:db/:table?id=1        body 为需要修改的字段, 必须有过滤条件
+//	DELETE /:db/:table?id=1        必须有过滤条件
+//
+// 过滤条件的格式参考 ParseQuery, 条件中的字段必须是开放的字段
+type Gateway struct {
+	orm   *RestOrm
+	rules map[string]*tableRule
+}
+
+// NewGateway orm 为 nil 时使用 Default
+func NewGateway(orm *RestOrm) *Gateway {
+	if orm == nil {
+		orm = Default()
+	}
+	return &Gateway{orm: orm, rules: make(map[string]*tableRule)}
+}
+
+// Allow 开放 db 中的 table, 需要在 Mount 之前调用
+func (g *Gateway) Allow(db, table string, opts ...TableOption) *Gateway {
+	var r = &tableRule{ops: OpRead, maxLimit: defaultMaxLimit}
+	for _, opt := range opts {
+		opt(r)
+	}
+	g.rules[db+"."+table] = r
+	return g
+}
+
+// Mount 在 r 上注册路由
+func (g *Gateway) Mount(r gin.IRouter) {
+	r.GET("/:db/:table", g.handle(OpGet, g.get))
+	r.GET("/:db/:table/count", g.handle(OpCount, g.count))
+	r.POST("/:db/:table", g.handle(OpCreate, g.create))
+	r.PATCH("/:db/:table", g.handle(OpUpdate, g.update))
+	r.DELETE("/:db/:table", g.handle(OpDelete, g.delete))
+}
+
+// gatewayError 带有 HTTP 状态码的错误
+type gatewayError struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *gatewayError) Error() string { return e.msg }
+
+func badRequest(format string, args ...interface{}) error {
+	return &gatewayError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+func dbError(code string, err error) error {
+	if errors.Is(err, ErrInvalidFilter) {
+		return &gatewayError{status: http.StatusBadRequest, code: code, msg: err.Error()}
+	}
+
+	// 数据库或者表在请求的过程中被删除
+	if errors.Is(err, ErrUnknownDatabase) || errors.Is(err, ErrUnknownTable) {
+		return &gatewayError{status: http.StatusNotFound, code: code, msg: err.Error()}
+	}
+	return &gatewayError{status: http.StatusInternalServerError, code: code, msg: err.Error()}
+}
+
+// request 一次请求的表和可以访问的字段
+type request struct {
+	db      string
+	table   string
+	rule    *tableRule
+	columns map[string]*converter
+}
+
+func (r *request) allowed(column string) bool {
+	if !identRe.MatchString(column) {
+		return false
+	}
+
+	if _, ok := r.columns[column]; !ok {
+		return false
+	}
+	return r.rule.columns == nil || r.rule.columns[column]
+}
+
+func (r *request) checkColumn(column string) error {
+	if !r.allowed(column) {
+		return badRequest("unknown column %q", column)
+	}
+	return nil
+}
+
+func (g *Gateway) handle(op Op, fn func(c *gin.Context, r *request) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var db, table = c.Param("db"), c.Param("table")
+
+		rule, ok := g.rules[db+"."+table]
+		var columns = g.orm.columns(db, table)
+		if !ok || columns == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("table %s.%s not found", db, table)})
+			return
+		}
+
+		if rule.ops&op == 0 {
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"error": fmt.Sprintf("operation not allowed on %s.%s", db, table)})
+			return
+		}
+
+		if err := fn(c, &request{db: db, table: table, rule: rule, columns: columns}); err != nil {
+			var status, code = http.StatusInternalServerError, ""
+			if e, ok := err.(*gatewayError); ok {
+				status, code = e.status, e.code
+			}
+
+			var res = gin.H{"error": err.Error()}
+			if code != "" {
+				res["code"] = code
+			}
+			c.JSON(status, res)
+		}
+	}
+}
+
+// filter 解析查询参数中的过滤条件, 没有条件时返回 nil
+func (r *request) filter(c *gin.Context) (*Cond, error) {
+	cond, err := ParseQuery(c.Request.URL.Query(), paramFields, paramOrder, paramLimit, paramOffset)
+	if err != nil {
+		return nil, badRequest("%s", err)
+	}
+
+	for _, f := range cond.Fields() {
+		if err := r.checkColumn(f); err != nil {
+			return nil, err
+		}
+	}
+	return cond, nil
+}
+
+// fields 查询的字段, 没有指定时为开放的所有字段
+func (r *request) fields(c *gin.Context) (string, error) {
+	var fields = c.Query(paramFields)
+	if fields == "" {
+		if r.rule.columns == nil {
+			return "*", nil
+		}
+
+		var names []string
+		for name := range r.rule.columns {
+			if r.allowed(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		fields = strings.Join(names, ",")
+	}
+
+	for _, f := range strings.Split(fields, ",") {
+		if err := r.checkColumn(f); err != nil {
+			return "", err
+		}
+	}
+	return fields, nil
+}
+
+// order 排序, 例如 -id,name, 字段前面加 - 为降序
+func (r *request) order(c *gin.Context) (string, error) {
+	var order = c.Query(paramOrder)
+	if order == "" {
+		return "", nil
+	}
+
+	for _, item := range strings.Split(order, ",") {
+		f, _, err := parseOrder(item)
+		if err != nil {
+			return "", badRequest("%s", err)
+		}
+
+		if err := r.checkColumn(f); err != nil {
+			return "", err
+		}
+	}
+	return order, nil
+}
+
+// page 分页, limit 超过 maxLimit 时使用 maxLimit
+func (r *request) page(c *gin.Context) (limit, offset int, err error) {
+	limit, offset = defaultLimit, 0
+	if v := c.Query(paramLimit); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return 0, 0, badRequest("invalid limit %q", v)
+		}
+	}
+
+	if r.rule.maxLimit > 0 && limit > r.rule.maxLimit {
+		limit = r.rule.maxLimit
+	}
+
+	if v := c.Query(paramOffset); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, badRequest("invalid offset %q", v)
+		}
+	}
+	return
+}
+
+// decode 解析 body, 数字保持为 json.Number, 避免大整数丢失精度
+func decode(body io.Reader, dst interface{}) error {
+	var dec = json.NewDecoder(body)
+	dec.UseNumber()
+	if err := dec.Decode(dst); err != nil {
+		return badRequest("invalid body: %s", err)
+	}
+	return nil
+}
+
+func (r *request) checkData(data map[string]interface{}) error {
+	if len(data) == 0 {
+		return badRequest("empty data")
+	}
+
+	for k := range data {
+		if err := r.checkColumn(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireFilter 修改和删除必须有过滤条件, 避免误操作整个表
+func (r *request) requireFilter(c *gin.Context) (*Cond, error) {
+	filter, err := r.filter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter == nil {
+		return nil, badRequest("filter is required")
+	}
+
+	// 不引用字段的条件, 例如空的 and, 对整个表生效
+	if len(filter.Fields()) == 0 {
+		return nil, badRequest("filter must reference a field")
+	}
+	return filter, nil
+}
+
+func (g *Gateway) get(c *gin.Context, r *request) error {
+	fields, err := r.fields(c)
+	if err != nil {
+		return err
+	}
+
+	order, err := r.order(c)
+	if err != nil {
+		return err
+	}
+
+	limit, offset, err := r.page(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := r.filter(c)
+	if err != nil {
+		return err
+	}
+
+	dts, err := g.orm.ResGetMany(r.db, r.table, fields, "", order, strconv.Itoa(limit), strconv.Itoa(offset), filter)
+	if err != nil {
+		return dbError(Errs.DbGetError, err)
+	}
+
+	if dts == nil {
+		dts = []map[string]interface{}{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dts})
+	return nil
+}
+
+func (g *Gateway) count(c *gin.Context, r *request) error {
+	filter, err := r.filter(c)
+	if err != nil {
+		return err
+	}
+
+	n, err := g.orm.ResCount(r.db, r.table, filter)
+	if err != nil {
+		return dbError(Errs.DbCountError, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": n})
+	return nil
+}
+
+func (g *Gateway) create(c *gin.Context, r *request) error {
+	var body json.RawMessage
+	if err := decode(c.Request.Body, &body); err != nil {
+		return err
+	}
+
+	// body 为对象或者对象数组
+	var dts []map[string]interface{}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		if err := decode(bytes.NewReader(body), &dts); err != nil {
+			return err
+		}
+	} else {
+		var dt map[string]interface{}
+		if err := decode(bytes.NewReader(body), &dt); err != nil {
+			return err
+		}
+		dts = append(dts, dt)
+	}
+
+	if len(dts) == 0 {
+		return badRequest("empty data")
+	}
+
+	for _, dt := range dts {
+		if err := r.checkData(dt); err != nil {
+			return err
+		}
+	}
+
+	if err := g.orm.ResCreateMany(r.db, r.table, dts...); err != nil {
+		return dbError(Errs.DbCreateError, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": len(dts)})
+	return nil
+}
+
+func (g *Gateway) update(c *gin.Context, r *request) error {
+	filter, err := r.requireFilter(c)
+	if err != nil {
+		return err
+	}
+
+	var data map[string]interface{}
+	if err := decode(c.Request.Body, &data); err != nil {
+		return err
+	}
+
+	if err := r.checkData(data); err != nil {
+		return err
+	}
+
+	if err := g.orm.ResUpdateMany(r.db, r.table, data, filter); err != nil {
+		return dbError(Errs.DbUpdateError, err)
+	}
+
+	c.Status(http.StatusNoContent)
+	return nil
+}
+
+func (g *Gateway) delete(c *gin.Context, r *request) error {
+	filter, err := r.requireFilter(c)
+	if err != nil {
+		return err
+	}
+
+	if err := g.orm.ResDeleteMany(r.db, r.table, filter); err != nil {
+		return dbError(Errs.DbDeleteError, err)
+	}
+
+	c.Status(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,160 @@
+package restorm_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pubgo/x/restorm"
+)
+
+func newOrm(t *testing.T) *restorm.RestOrm {
+	var dsn = filepath.Join(t.TempDir(), "test.db")
+	var orm = restorm.Default()
+	if err := orm.DbConfigAdd(context.Background(), "test", &restorm.Config{Enable: true, Driver: "sqlite3", Dsn: dsn, MaxOpenConns: 1}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { orm.DbConfigDelete("test") })
+
+	_, err := orm.Import("test", strings.NewReader(`
+		CREATE TABLE user (id INTEGER PRIMARY KEY, name VARCHAR(32), age INTEGER, secret VARCHAR(32));
+		CREATE TABLE log (id INTEGER PRIMARY KEY, msg TEXT);
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return orm
+}
+
+type result struct {
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+func do(t *testing.T, h http.Handler, method, url, body string) (int, result) {
+	t.Helper()
+
+	var w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+
+	var res result
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(w.Body.String(), err)
+		}
+	}
+	return w.Code, res
+}
+
+func TestGateway(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var r = gin.New()
+	restorm.NewGateway(newOrm(t)).
+		Allow("test", "user", restorm.WithOps(restorm.OpAll), restorm.WithColumns("id", "name", "age"), restorm.WithMaxLimit(2)).
+		Allow("test", "log").
+		Mount(r.Group("/api"))
+
+	code, res := do(t, r, "POST", "/api/test/user", `[{"id":1,"name":"a","age":10},{"id":2,"name":"b","age":20},{"id":3,"name":"c","age":30}]`)
+	if code != http.StatusCreated {
+		t.Fatal(code, res.Error)
+	}
+
+	if code, res = do(t, r, "POST", "/api/test/user", `{"id":4,"name":"d"}`); code != http.StatusCreated {
+		t.Fatal(code, res.Error)
+	}
+
+	var rows []map[string]interface{}
+	code, res = do(t, r, "GET", "/api/test/user?order=-id&age__gte=20&fields=id,name", "")
+	if code != http.StatusOK {
+		t.Fatal(code, res.Error)
+	}
+	json.Unmarshal(res.Data, &rows)
+	if len(rows) != 2 || rows[0]["name"] != "c" || rows[1]["name"] != "b" || rows[0]["age"] != nil {
+		t.Fatal(rows)
+	}
+
+	// limit 超过 WithMaxLimit
+	code, res = do(t, r, "GET", "/api/test/user?order=id&limit=10&offset=1", "")
+	json.Unmarshal(res.Data, &rows)
+	if code != http.StatusOK || len(rows) != 2 || rows[0]["name"] != "b" {
+		t.Fatal(code, rows)
+	}
+
+	if _, ok := rows[0]["secret"]; ok {
+		t.Fatal("secret column exposed")
+	}
+
+	code, res = do(t, r, "GET", "/api/test/user/count?id__in=1,2,4&age__null=false", "")
+	if code != http.StatusOK || string(res.Data) != "2" {
+		t.Fatal(code, string(res.Data), res.Error)
+	}
+
+	code, res = do(t, r, "GET", "/api/test/user/count?age__between=15,35&filter="+url.QueryEscape(`{"or":[{"name":"b"},{"id":{"gte":3}}]}`), "")
+	if code != http.StatusOK || string(res.Data) != "2" {
+		t.Fatal(code, string(res.Data), res.Error)
+	}
+
+	if code, res = do(t, r, "PATCH", "/api/test/user?name=d", `{"age":40}`); code != http.StatusNoContent {
+		t.Fatal(code, res.Error)
+	}
+
+	if code, res = do(t, r, "GET", "/api/test/user/count?age=40", ""); string(res.Data) != "1" {
+		t.Fatal(code, string(res.Data), res.Error)
+	}
+
+	if code, res = do(t, r, "DELETE", "/api/test/user?age__lt=25", ""); code != http.StatusNoContent {
+		t.Fatal(code, res.Error)
+	}
+
+	if code, res = do(t, r, "GET", "/api/test/user/count", ""); string(res.Data) != "2" {
+		t.Fatal(code, string(res.Data), res.Error)
+	}
+
+	code, res = do(t, r, "GET", "/api/test/log", "")
+	if code != http.StatusOK || string(res.Data) != "[]" {
+		t.Fatal(code, string(res.Data), res.Error)
+	}
+
+	for _, c := range []struct {
+		method, url, body string
+		code              int
+	}{
+		{"GET", "/api/test/secret", "", http.StatusNotFound},
+		{"GET", "/api/other/user", "", http.StatusNotFound},
+		{"POST", "/api/test/log", `{"msg":"x"}`, http.StatusMethodNotAllowed},
+		{"GET", "/api/test/user?fields=secret", "", http.StatusBadRequest},
+		{"GET", "/api/test/user?secret=x", "", http.StatusBadRequest},
+		{"GET", "/api/test/user?order=name%3Bdrop", "", http.StatusBadRequest},
+		{"GET", "/api/test/user?id__regexp=1", "", http.StatusBadRequest},
+		{"GET", "/api/test/user?filter=" + url.QueryEscape(`{"not":{"secret":"x"}}`), "", http.StatusBadRequest},
+		{"GET", "/api/test/user?filter=[]", "", http.StatusBadRequest},
+		{"GET", "/api/test/user?limit=-1", "", http.StatusBadRequest},
+		{"POST", "/api/test/user", `{"secret":"x"}`, http.StatusBadRequest},
+		{"POST", "/api/test/user", `[]`, http.StatusBadRequest},
+		{"POST", "/api/test/user", `{"id":3}`, http.StatusInternalServerError},
+		{"PATCH", "/api/test/user", `{"age":1}`, http.StatusBadRequest},
+		{"DELETE", "/api/test/user", "", http.StatusBadRequest},
+		{"DELETE", "/api/test/user?filter={}", "", http.StatusBadRequest},
+		{"DELETE", "/api/test/user?filter=" + url.QueryEscape(`{"and":[]}`), "", http.StatusBadRequest},
+		{"DELETE", "/api/test/user?filter=" + url.QueryEscape(`{"not":{"id":[]}}`), "", http.StatusBadRequest},
+		{"PATCH", "/api/test/user?filter=" + url.QueryEscape(`{"or":[]}`), `{"age":1}`, http.StatusBadRequest},
+		{"PATCH", "/api/test/user?filter=" + url.QueryEscape(`{"not":{"id":{"in":[]}}}`), `{"age":1}`, http.StatusBadRequest},
+	} {
+		if code, res := do(t, r, c.method, c.url, c.body); code != c.code {
+			t.Errorf("%s %s: %d %s", c.method, c.url, code, res.Error)
+		}
+	}
+
+	// 空的过滤条件不会修改或者删除整个表
+	if code, res = do(t, r, "GET", "/api/test/user/count", ""); string(res.Data) != "2" {
+		t.Fatal(code, string(res.Data), res.Error)
+	}
+}
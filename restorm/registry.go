@@ -0,0 +1,272 @@
+package restorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pubgo/schema"
+	"github.com/pubgo/x/retry"
+	"github.com/pubgo/xerror"
+)
+
+// drainTimeout 替换或者删除数据库时, 等待旧连接池上执行中的请求结束的最长时间
+const drainTimeout = time.Minute
+
+// pool
+// 一个数据库的连接池和表结构, 注册之后 db 和 dialect 不再修改, colT 在 RestOrm.mu 的保护下整体替换
+type pool struct {
+	name    string
+	cfg     Config
+	db      *sqlx.DB
+	dialect Dialect
+	colT    map[string]map[string]*converter
+
+	// inflight 执行中的请求, 替换之后等待请求结束再关闭连接池
+	inflight sync.WaitGroup
+}
+
+func (p *pool) release() {
+	p.inflight.Done()
+}
+
+// drain 等待执行中的请求结束之后关闭连接池, 超过 timeout 时直接关闭
+func (p *pool) drain(timeout time.Duration) error {
+	var done = make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(done)
+	}()
+
+	var timer = time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+	return p.db.Close()
+}
+
+// loadTables 读取数据库中所有表的字段类型
+func loadTables(db *sqlx.DB) (colT map[string]map[string]*converter, err error) {
+	defer xerror.RespErr(&err)
+
+	tbs, err := schema.Tables(db.DB)
+	xerror.PanicF(err, "get tables error")
+
+	colT = make(map[string]map[string]*converter, len(tbs))
+	for name, tps := range tbs {
+		colT[name] = make(map[string]*converter, len(tps))
+		for _, f := range tps {
+			field := typeName(f.DatabaseTypeName())
+			colT[name][f.Name()] = &converter{Name: field, converter: Converter(field)}
+		}
+	}
+	return
+}
+
+// retryable 只有网络错误可以重试, 驱动和 dsn 错误, 认证失败等直接返回
+func retryable(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	return retry.Permanent(err)
+}
+
+// connect 创建连接池并且读取表结构
+func connect(ctx context.Context, name string, conf Config) (p *pool, err error) {
+	db, err := sqlx.Open(conf.Driver, conf.Dsn)
+	if err != nil {
+		return nil, retry.Permanent(fmt.Errorf("db(%s) open error: %w", name, err))
+	}
+
+	defer func() {
+		if err != nil {
+			db.Close()
+		}
+	}()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, retryable(fmt.Errorf("db(%s) connect error: %w", name, err))
+	}
+
+	db.SetMaxOpenConns(conf.MaxOpenConns)
+	db.SetMaxIdleConns(conf.MaxIdleConns)
+	if conf.MaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(conf.MaxLifetime) * time.Second)
+	}
+
+	var dialect = conf.Dialect
+	if dialect == "" {
+		dialect = conf.Driver
+	}
+
+	colT, err := loadTables(db)
+	if err != nil {
+		return nil, retryable(fmt.Errorf("db(%s) load tables error: %w", name, err))
+	}
+
+	return &pool{name: name, cfg: conf, db: db, dialect: DialectByDriver(dialect), colT: colT}, nil
+}
+
+// 数据库连接的重试配置, 网络错误时指数退避重试, 最多重试一分钟
+var dbRetryOpts = []retry.Option{
+	retry.WithAttempt(0),
+	retry.WithMaxElapsed(time.Minute),
+	retry.WithStrategy(retry.Decorrelated(time.Second/2, time.Second*10)),
+}
+
+// acquire 获取数据库的连接池, 使用完之后需要调用 release
+func (t *RestOrm) acquire(name string) (*pool, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, ok := t.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDatabase, name)
+	}
+
+	p.inflight.Add(1)
+	return p, nil
+}
+
+// columns 表的字段, 数据库或者表不存在时返回 nil
+func (t *RestOrm) columns(dbName, tbName string) map[string]*converter {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, ok := t.dbs[dbName]
+	if !ok {
+		return nil
+	}
+	return p.colT[tbName]
+}
+
+// replace 注册新的连接池, 旧的连接池在执行中的请求结束之后关闭
+func (t *RestOrm) replace(name string, p *pool) {
+	t.mu.Lock()
+	old := t.dbs[name]
+	if p == nil {
+		delete(t.dbs, name)
+	} else {
+		t.dbs[name] = p
+	}
+	t.mu.Unlock()
+
+	if old != nil {
+		go old.drain(drainTimeout)
+	}
+}
+
+// open 连接数据库并且替换同名的数据库, 网络错误时重试, ctx 结束时停止重试
+func (t *RestOrm) open(ctx context.Context, name string, cfg Config) error {
+	if !cfg.Enable {
+		t.replace(name, nil)
+		return nil
+	}
+
+	return retry.Do(ctx, func(ctx context.Context) error {
+		p, err := connect(ctx, name, cfg)
+		if err != nil {
+			return err
+		}
+
+		t.replace(name, p)
+		return nil
+	}, dbRetryOpts...)
+}
+
+// DbConfigAdd
+// 添加数据库, 已经存在时替换, 旧的连接池在执行中的请求结束之后关闭
+// cfg.Enable 为 false 时删除数据库
+func (t *RestOrm) DbConfigAdd(ctx context.Context, name string, cfg *Config) error {
+	return t.open(ctx, name, *cfg)
+}
+
+// DbConfigUpdate 同 DbConfigAdd
+func (t *RestOrm) DbConfigUpdate(ctx context.Context, name string, cfg *Config) error {
+	return t.open(ctx, name, *cfg)
+}
+
+// DbUpdate 使用原来的配置重新连接数据库
+func (t *RestOrm) DbUpdate(ctx context.Context, name string) error {
+	t.mu.RLock()
+	p, ok := t.dbs[name]
+	t.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownDatabase, name)
+	}
+	return t.open(ctx, name, p.cfg)
+}
+
+// DbConfigDelete 删除数据库, 等待执行中的请求结束之后关闭连接池
+func (t *RestOrm) DbConfigDelete(name string) error {
+	t.mu.Lock()
+	p, ok := t.dbs[name]
+	delete(t.dbs, name)
+	t.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return p.drain(drainTimeout)
+}
+
+// RefreshSchema
+// 重新读取数据库的表结构, 执行数据库迁移之后调用
+// 通过 Import 执行的 DDL 会自动刷新
+func (t *RestOrm) RefreshSchema(name string) (err error) {
+	p, err := t.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer p.release()
+
+	colT, err := loadTables(p.db)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	p.colT = colT
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *RestOrm) DbStats() map[string]sql.DBStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	stats := make(map[string]sql.DBStats)
+	for k, v := range t.dbs {
+		stats[k] = v.db.Stats()
+	}
+	return stats
+}
+
+func (t *RestOrm) ColTs() map[string]map[string]map[string]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var dt = make(map[string]map[string]map[string]string)
+	for k, v := range t.dbs {
+		dt[k] = make(map[string]map[string]string)
+		for k1, v1 := range v.colT {
+			dt[k][k1] = make(map[string]string)
+			for k2, v := range v1 {
+				dt[k][k1][k2] = v.Name
+			}
+		}
+	}
+	return dt
+}
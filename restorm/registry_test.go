@@ -0,0 +1,169 @@
+package restorm_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pubgo/x/restorm"
+)
+
+// newFile 创建 sqlite 数据库文件, user 表中有 n 条记录
+func newFile(t *testing.T, n int) string {
+	var dsn = filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlx.Connect("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.MustExec("CREATE TABLE user (id INTEGER PRIMARY KEY, name VARCHAR(32))")
+	for i := 0; i < n; i++ {
+		db.MustExec("INSERT INTO user (name) VALUES (?)", "u")
+	}
+	return dsn
+}
+
+func TestRegistryUnknown(t *testing.T) {
+	var orm = restorm.Default()
+	if _, err := orm.ResCount("unknown", "user"); !errors.Is(err, restorm.ErrUnknownDatabase) {
+		t.Fatal(err)
+	}
+
+	if err := orm.DbUpdate(context.Background(), "unknown"); !errors.Is(err, restorm.ErrUnknownDatabase) {
+		t.Fatal(err)
+	}
+
+	var dsn = newFile(t, 1)
+	if err := orm.DbConfigAdd(context.Background(), "registry", &restorm.Config{Enable: true, Driver: "sqlite3", Dsn: dsn}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orm.ResCount("registry", "unknown"); !errors.Is(err, restorm.ErrUnknownTable) {
+		t.Fatal(err)
+	}
+
+	// 在外部执行迁移, 刷新之后才能访问新的表
+	db := sqlx.MustConnect("sqlite3", dsn)
+	db.MustExec("CREATE TABLE log (id INTEGER PRIMARY KEY, msg TEXT)")
+	db.Close()
+
+	if _, err := orm.ResCount("registry", "log"); !errors.Is(err, restorm.ErrUnknownTable) {
+		t.Fatal(err)
+	}
+
+	if err := orm.RefreshSchema("registry"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := orm.ResCount("registry", "log"); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+
+	// Import 自动刷新表结构
+	if _, err := orm.Import("registry", strings.NewReader("ALTER TABLE log ADD COLUMN level INTEGER;")); err != nil {
+		t.Fatal(err)
+	}
+
+	if orm.ColTs()["registry"]["log"]["level"] != "integer" {
+		t.Fatal(orm.ColTs()["registry"]["log"])
+	}
+
+	// Enable 为 false 时删除数据库
+	if err := orm.DbConfigUpdate(context.Background(), "registry", &restorm.Config{Driver: "sqlite3", Dsn: dsn}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orm.ResCount("registry", "user"); !errors.Is(err, restorm.ErrUnknownDatabase) {
+		t.Fatal(err)
+	}
+
+	if _, ok := orm.DbStats()["registry"]; ok {
+		t.Fatal("registry is not deleted")
+	}
+}
+
+func TestRegistryConfigError(t *testing.T) {
+	var orm = restorm.Default()
+	var ctx = context.Background()
+
+	// 配置错误不重试
+	var start = time.Now()
+	if err := orm.DbConfigAdd(ctx, "bad", &restorm.Config{Enable: true, Driver: "unknown", Dsn: "x"}); err == nil {
+		t.Fatal("unknown driver should fail")
+	}
+	if err := orm.DbConfigAdd(ctx, "bad", &restorm.Config{Enable: true, Driver: "sqlite3", Dsn: "/nonexistent/dir/test.db"}); err == nil {
+		t.Fatal("invalid dsn should fail")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal(time.Since(start))
+	}
+
+	if _, err := orm.ResCount("bad", "user"); !errors.Is(err, restorm.ErrUnknownDatabase) {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	var orm = restorm.Default()
+	var a = &restorm.Config{Enable: true, Driver: "sqlite3", Dsn: newFile(t, 1), MaxOpenConns: 2}
+	var b = &restorm.Config{Enable: true, Driver: "sqlite3", Dsn: newFile(t, 2), MaxOpenConns: 2}
+	if err := orm.DbConfigAdd(context.Background(), "reload", a); err != nil {
+		t.Fatal(err)
+	}
+	defer orm.DbConfigDelete("reload")
+
+	// 替换连接池的过程中执行中的请求不会失败
+	var stop = make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				dts, err := orm.ResGetMany("reload", "user", "id,name", "", "id", "", "")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if len(dts) != 1 && len(dts) != 2 {
+					t.Errorf("unexpected rows: %v", dts)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		var cfg = a
+		if i%2 == 0 {
+			cfg = b
+		}
+
+		if err := orm.DbConfigUpdate(context.Background(), "reload", cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := orm.DbUpdate(context.Background(), "reload"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if n, err := orm.ResCount("reload", "user"); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+}
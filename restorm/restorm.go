@@ -1,14 +1,11 @@
 package restorm
 
 import (
-	"database/sql"
+	"fmt"
+	"sync"
+
 	"github.com/jmoiron/sqlx"
-	"github.com/pubgo/x/retry"
 	"github.com/pubgo/xerror"
-	"github.com/pubgo/schema"
-	"strings"
-	"sync"
-	"time"
 )
 
 var once sync.Once
@@ -17,165 +14,91 @@ var _db *RestOrm
 func Default() *RestOrm {
 	once.Do(func() {
 		_db = &RestOrm{
-			cfg: make(map[string]*Config),
+			dbs: make(map[string]*pool),
 		}
 	})
 	return _db
 }
 
+// RestOrm
+// 按照数据库和表名增删改查, 数据库可以在运行中添加, 替换和删除
 type RestOrm struct {
-	cfg map[string]*Config
+	mu  sync.RWMutex
+	dbs map[string]*pool
 }
 
-// colsTransfer get field type and name
-func (t *RestOrm) colsTransfer(name string, cfg *Config) (err error) {
-	defer xerror.RespErr(&err)
-
-	tbs, err := schema.Tables(cfg.db.DB)
-	xerror.PanicM(err, "get tables error")
+// builder
+// 获取数据库的连接池和表的 sqlBuilder, 使用完之后需要调用 pool.release
+// 数据库或者表不存在时 panic
+func (t *RestOrm) builder(dbName, tbName string) (*pool, *sqlBuilder) {
+	p, err := t.acquire(dbName)
+	xerror.Panic(err)
 
-	if cfg.colT == nil {
-		cfg.colT = make(map[string]map[string]*converter)
-	}
+	t.mu.RLock()
+	cols := p.colT[tbName]
+	t.mu.RUnlock()
 
-	for name, tps := range tbs {
-		cfg.colT[name] = make(map[string]*converter)
-		for _, f := range tps {
-			field := strings.ToLower(f.DatabaseTypeName())
-			cfg.colT[name][f.Name()] = &converter{Name: field, converter: Converter(field)}
-		}
+	if cols == nil {
+		p.release()
+		xerror.Panic(fmt.Errorf("%w: %s.%s", ErrUnknownTable, dbName, tbName))
 	}
-	return
-}
-
-func (t *RestOrm) DbStats() map[string]sql.DBStats {
-	stats := make(map[string]sql.DBStats)
-	for k, v := range t.cfg {
-		stats[k] = v.db.Stats()
-	}
-	return stats
+	return p, &sqlBuilder{table: tbName, cols: cols, dialect: p.dialect}
 }
 
-func (t *RestOrm) ColTs() map[string]map[string]map[string]string {
-	var dt = make(map[string]map[string]map[string]string)
-	for k, v := range t.cfg {
-		dt[k] = make(map[string]map[string]string)
-		for k1, v1 := range v.colT {
-			dt[k][k1] = make(map[string]string)
-			for k2, v := range v1 {
-				dt[k][k1][k2] = v.Name
-			}
-		}
-	}
-	return dt
-}
-
-func (t *RestOrm) dbConnect(key string, conf *Config) (err error) {
+// ResCreate
+// 创建一条记录, 返回 returning 中字段的值
+// postgresql 和 mssql 通过 RETURNING 和 OUTPUT 返回任意字段
+// 其他数据库只能返回一个自增字段, 值为 LastInsertId
+func (t *RestOrm) ResCreate(dbName, tbName string, data map[string]interface{}, returning ...string) (res map[string]interface{}, err error) {
 	defer xerror.RespErr(&err)
 
-	if !conf.Enable {
-		return
-	}
+	p, _sql := t.builder(dbName, tbName)
+	defer p.release()
+	query, err := _sql.insertString(data, returning...)
+	xerror.Panic(err)
 
-	db, err := sqlx.Connect(conf.Driver, conf.Dsn)
-	xerror.PanicM(err, "%s connect error", conf.Dsn)
-	xerror.PanicM(db.Ping(), "ping error")
+	if output, suffix := _sql.dialect.Returning(returning); output != "" || suffix != "" {
+		rows, err := p.db.Queryx(query, _sql.args...)
+		xerror.PanicF(err, "db create error, dbName(%s) tbName(%s) input(%v)", dbName, tbName, data)
 
-	db.SetMaxOpenConns(conf.MaxOpenConns)
-	db.SetMaxIdleConns(conf.MaxIdleConns)
-	if conf.MaxLifetime > 0 {
-		db.SetConnMaxLifetime(time.Duration(conf.MaxLifetime) * time.Second)
+		dts, err := rows2Map(_sql.cols, rows)
+		xerror.Panic(err)
+		xerror.Assert(len(dts) != 1, "db create error, dbName(%s) tbName(%s) returned %d rows", dbName, tbName, len(dts))
+		return dts[0], nil
 	}
-	conf.db = db
 
-	// update table fields type
-	xerror.Panic(t.colsTransfer(key, conf))
-	return
-}
+	xerror.Assert(len(returning) > 1, "dialect(%s) can only return one auto increment column", _sql.dialect.GetName())
 
-func (t *RestOrm) DbConfigAdd(name string, cfg *Config) {
-	retry.Retry(time.Second, func(_ uint, _ time.Duration) {
-		xerror.PanicM(t.dbConnect(name, cfg), "db connect error")
-		t.cfg[name] = cfg
-	})
-}
+	result, err := p.db.Exec(query, _sql.args...)
+	xerror.PanicF(err, "db create error, dbName(%s) tbName(%s) input(%v)", dbName, tbName, data)
 
-func (t *RestOrm) DbConfigDelete(name string) {
-	if db, ok := t.cfg[name]; ok {
-		retry.WithConstant(time.Second).WithHandle(func(_ uint, _ time.Duration) {
-			xerror.PanicM(db.db.Close, "db(%s) close error", name)
-			delete(t.cfg, name)
-		}).Do()
+	res = make(map[string]interface{})
+	if len(returning) == 1 {
+		id, err := result.LastInsertId()
+		xerror.Panic(err)
+		res[returning[0]] = id
 	}
-}
-
-func (t *RestOrm) DbUpdate(name string) (err error) {
-	defer xerror.RespErr(&err)
-
-	cfg, ok := t.cfg[name]
-	xerror.PanicT(!ok, "db(%s) does not exist", name)
-
-	retry.WithConstant(time.Second).WithHandle(func(_ uint, _ time.Duration) {
-		xerror.PanicM(t.dbConnect(name, cfg), "db(%s) update error", name)
-		t.cfg[name] = cfg
-	}).Do()
-
-	return
-}
-
-func (t *RestOrm) DbConfigUpdate(name string, cfg *Config) (err error) {
-	defer xerror.RespErr(&err)
-
-	retry.WithConstant(time.Second).WithHandle(func(_ uint, _ time.Duration) {
-		xerror.PanicM(t.dbConnect(name, cfg), "db(%s) config update error", name)
-		t.cfg[name] = cfg
-	}).Do()
-
 	return
 }
 
 // 创建记录
-func (t *RestOrm) ResCreateMany(dbName, tbName string, dts ...map[string]interface{}) (err error) {
-	defer xerror.RespErr(&err)
-
-	_db := t.cfg[dbName].db
-	_tx, err := _db.Beginx()
-	xerror.Panic(err)
-
-	_sql := &sqlBuilder{table: tbName}
-	for _, dt := range dts {
-		_, err := _tx.Exec(_sql.insertString(dt), _sql.args...)
-		if err != nil {
-			xerror.PanicM(_tx.Rollback, "tx rollback error: %s", err)
-		}
-
-		xerror.PanicMM(err, func(err xerror.IErr) {
-			err.SetErr("db create error")
-			err.M("input", dt)
-			err.M("dbName", dbName)
-			err.M("tbName", tbName)
-		})
-	}
-	xerror.PanicM(_tx.Commit(), "tx commit error")
-
-	return
+// 所有记录在一个事务中批量写入, 参考 ResCreateBatch
+func (t *RestOrm) ResCreateMany(dbName, tbName string, dts ...map[string]interface{}) error {
+	_, err := t.ResCreateBatch(dbName, tbName, dts)
+	return err
 }
 
 // 删除记录
+// filter[0] 为 *Cond 或者原始的 SQL 条件, 参考 sqlBuilder.Where
 func (t *RestOrm) ResDeleteMany(dbName, tbName string, filter ...interface{}) (err error) {
 	defer xerror.RespErr(&err)
 
-	_db := t.cfg[dbName].db
-	_sql := &sqlBuilder{table: tbName}
-	_sql.Where(filter...)
+	p, _sql := t.builder(dbName, tbName)
+	defer p.release()
+	xerror.Panic(_sql.Where(filter...))
 
-	_, err = _db.Exec(_sql.deleteString(), _sql.args...)
-	xerror.PanicMM(err, func(err xerror.IErr) {
-		err.SetErr("db delete error")
-		err.M("input", filter)
-		err.M("dbName", dbName)
-		err.M("tbName", tbName)
-	})
+	_, err = p.db.Exec(_sql.deleteString(), _sql.args...)
+	xerror.PanicF(err, "db delete error, dbName(%s) tbName(%s) input(%v)", dbName, tbName, filter)
 
 	return
 }
@@ -184,20 +107,17 @@ func (t *RestOrm) ResDeleteMany(dbName, tbName string, filter ...interface{}) (e
 func (t *RestOrm) ResUpdateMany(dbName, tbName string, data map[string]interface{}, filter ...interface{}) (err error) {
 	defer xerror.RespErr(&err)
 
-	xerror.PanicT(_isNone(data), "update data is nil")
+	xerror.Assert(_isNone(data), "update data is nil")
 
-	_db := t.cfg[dbName].db
-	_sql := &sqlBuilder{table: tbName}
-	_sql.Where(filter...)
+	p, _sql := t.builder(dbName, tbName)
+	defer p.release()
+	xerror.Panic(_sql.Where(filter...))
 
-	_, err = _db.Exec(_sql.updateString(data), _sql.args...)
-	xerror.PanicMM(err, func(err xerror.IErr) {
-		err.SetErr("db update error")
-		err.M("input", filter)
-		err.M("dbName", dbName)
-		err.M("tbName", tbName)
-		err.M("data", data)
-	})
+	query, err := _sql.updateString(data)
+	xerror.Panic(err)
+
+	_, err = p.db.Exec(query, _sql.args...)
+	xerror.PanicF(err, "db update error, dbName(%s) tbName(%s) input(%v) data(%v)", dbName, tbName, filter, data)
 
 	return
 }
@@ -205,71 +125,65 @@ func (t *RestOrm) ResUpdateMany(dbName, tbName string, data map[string]interface
 func (t *RestOrm) ResCount(dbName, tbName string, filter ...interface{}) (c int64, err error) {
 	defer xerror.RespErr(&err)
 
-	_db := t.cfg[dbName].db
-	_sql := &sqlBuilder{table: tbName}
-	_sql.Where(filter...)
+	p, _sql := t.builder(dbName, tbName)
+	defer p.release()
+	xerror.Panic(_sql.Where(filter...))
 
-	xerror.PanicMM(_db.Select(&c, _sql.countString(), _sql.args...), func(err xerror.IErr) {
-		err.SetErr("db count error")
-		err.M("input", filter)
-		err.M("dbName", dbName)
-		err.M("tbName", tbName)
-	})
+	xerror.PanicF(p.db.Get(&c, _sql.countString(), _sql.args...), "db count error, dbName(%s) tbName(%s) input(%v)", dbName, tbName, filter)
 	return
 }
 
-func (t *RestOrm) rows2Map(dbName, tbName string, rows *sqlx.Rows) (res []map[string]interface{}, err error) {
+// rows2Map 根据表的字段类型转换查询结果
+func rows2Map(cols map[string]*converter, rows *sqlx.Rows) (res []map[string]interface{}, err error) {
 	defer xerror.RespErr(&err)
+	defer rows.Close()
+
+	cons, err := rows.ColumnTypes()
+	xerror.Panic(err)
 
 	var dts []map[string]interface{}
 	for rows.Next() {
 		dest := make(map[string]interface{})
 
-		cons, err := rows.ColumnTypes()
-		xerror.Panic(err)
-
 		values := make([]interface{}, len(cons))
 		for i := range values {
 			values[i] = new(interface{})
 		}
 
-		_tb := t.cfg[dbName].colT[tbName]
 		xerror.Panic(rows.Scan(values...))
 		for i, column := range cons {
 			k := column.Name()
-			if _fn, ok := _tb[k]; ok {
-				dest[k] = _fn.converter(values[i])
+			v := *(values[i].(*interface{}))
+			if _fn, ok := cols[k]; ok {
+				dest[k] = _fn.converter(v)
 			} else {
-				dest[k] = Converter(strings.ToLower(column.DatabaseTypeName()))(values[i])
+				dest[k] = Converter(typeName(column.DatabaseTypeName()))(v)
 			}
 		}
 		dts = append(dts, dest)
 	}
+	xerror.Panic(rows.Err())
 
 	res = dts
 	return
 }
 
 // 查询
+// fields 和 groupBy 为逗号分隔的字段, order 的格式为 -id,name 或者 id desc,name asc
+// 字段都根据表结构校验, limit 和 offset 必须是非负整数
 func (t *RestOrm) ResGetMany(dbName, tbName string, fields string, groupBy string, order string, limit, offset string, filter ...interface{}) (dts []map[string]interface{}, err error) {
 	defer xerror.RespErr(&err)
 
-	_db := t.cfg[dbName].db
-	_sql := &sqlBuilder{table: tbName, fields: fields}
-	_sql.groupBy = groupBy
-	_sql.orderBy = order
-	_sql.limit = limit
-	_sql.offset = offset
-	_sql.Where(filter...)
-
-	rows, err := _db.Queryx(_sql.queryString(), _sql.args...)
-	xerror.PanicMM(err, func(err xerror.IErr) {
-		err.SetErr("db get error")
-		err.M("input", filter)
-		err.M("dbName", dbName)
-		err.M("tbName", tbName)
-		err.M("sql", _sql)
-	})
+	p, _sql := t.builder(dbName, tbName)
+	defer p.release()
+	xerror.Panic(_sql.setFields(fields))
+	xerror.Panic(_sql.setGroupBy(groupBy))
+	xerror.Panic(_sql.setOrderBy(order))
+	xerror.Panic(_sql.setPage(limit, offset))
+	xerror.Panic(_sql.Where(filter...))
+
+	rows, err := p.db.Queryx(_sql.queryString(), _sql.args...)
+	xerror.PanicF(err, "db get error, dbName(%s) tbName(%s) input(%v) sql(%s)", dbName, tbName, filter, _sql.queryString())
 
-	return t.rows2Map(dbName, tbName, rows)
+	return rows2Map(_sql.cols, rows)
 }
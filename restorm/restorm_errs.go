@@ -1,5 +1,14 @@
 package restorm
 
+import "errors"
+
+var (
+	// ErrUnknownDatabase 数据库没有注册
+	ErrUnknownDatabase = errors.New("restorm: unknown database")
+	// ErrUnknownTable 表不存在, 表是新创建的时候需要调用 RestOrm.RefreshSchema
+	ErrUnknownTable = errors.New("restorm: unknown table")
+)
+
 var Errs = struct {
 	DbCreateError    string
 	DbDeleteError    string
@@ -0,0 +1,42 @@
+package restorm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pubgo/x/restorm"
+)
+
+func TestResCreate(t *testing.T) {
+	var orm = newOrm(t)
+
+	for i, name := range []string{"a", "b", "c"} {
+		res, err := orm.ResCreate("test", "user", map[string]interface{}{"name": name, "age": i}, "id")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res["id"] != int64(i+1) {
+			t.Fatal(res)
+		}
+	}
+
+	if _, err := orm.ResCreate("test", "user", map[string]interface{}{"name": "d"}, "id", "name"); err == nil {
+		t.Fatal("sqlite can only return one column")
+	}
+
+	// sqlite 只有 offset 时使用 LIMIT -1
+	dts, err := orm.ResGetMany("test", "user", "id,name", "", "id", "", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want = []map[string]interface{}{{"id": int64(2), "name": "b"}, {"id": int64(3), "name": "c"}}
+	if !reflect.DeepEqual(dts, want) {
+		t.Fatal(dts)
+	}
+
+	if d := restorm.DialectByDriver("sqlite3"); d.GetName() != "sqlite" || d.Placeholder(2) != "?" {
+		t.Fatal(d.GetName())
+	}
+}
@@ -2,9 +2,16 @@ package restorm
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/pubgo/x/xservice/db/dialect"
 )
 
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type sqlBuilder struct {
 	hint       string
 	table      string
@@ -16,20 +23,131 @@ type sqlBuilder struct {
 	limit      string
 	offset     string
 	args       []interface{}
+
+	// cols 表的字段, 用于校验字段名, 为 nil 时只校验字段的格式
+	cols    map[string]*converter
+	dialect Dialect
 }
 
-func (s *sqlBuilder) limitFormat() string {
-	if s.limit != "" {
-		return "LIMIT " + s.limit
+// quoteColumn 校验字段名并且加上引号
+func quoteColumn(d Dialect, name string, cols map[string]*converter) (string, error) {
+	if !identRe.MatchString(name) {
+		return "", invalidFilter("invalid column %q", name)
 	}
-	return ""
+
+	if cols != nil {
+		if _, ok := cols[name]; !ok {
+			return "", invalidFilter("unknown column %q", name)
+		}
+	}
+	return d.Quote(name), nil
+}
+
+func (s *sqlBuilder) column(name string) (string, error) {
+	return quoteColumn(s.dialect, name, s.cols)
 }
 
-func (s *sqlBuilder) offsetFormat() string {
-	if s.offset != "" {
-		return "OFFSET " + s.offset
+// columnList 逗号分隔的字段列表
+func (s *sqlBuilder) columnList(list string) (string, error) {
+	var cols []string
+	for _, name := range strings.Split(list, ",") {
+		col, err := s.column(strings.TrimSpace(name))
+		if err != nil {
+			return "", err
+		}
+		cols = append(cols, col)
 	}
-	return ""
+	return strings.Join(cols, ","), nil
+}
+
+// setFields 查询的字段, 为空或者 * 时查询所有字段
+func (s *sqlBuilder) setFields(fields string) (err error) {
+	if fields = strings.TrimSpace(fields); fields == "" || fields == "*" {
+		s.fields = "*"
+		return nil
+	}
+
+	s.fields, err = s.columnList(fields)
+	return
+}
+
+// setGroupBy 分组的字段
+func (s *sqlBuilder) setGroupBy(groupBy string) (err error) {
+	if groupBy = strings.TrimSpace(groupBy); groupBy == "" {
+		return nil
+	}
+
+	s.groupBy, err = s.columnList(groupBy)
+	return
+}
+
+// setOrderBy 排序, 格式为 -id,name 或者 id desc,name asc
+func (s *sqlBuilder) setOrderBy(order string) error {
+	if strings.TrimSpace(order) == "" {
+		return nil
+	}
+
+	var items []string
+	for _, item := range strings.Split(order, ",") {
+		name, desc, err := parseOrder(item)
+		if err != nil {
+			return err
+		}
+
+		col, err := s.column(name)
+		if err != nil {
+			return err
+		}
+
+		if desc {
+			col += " DESC"
+		} else {
+			col += " ASC"
+		}
+		items = append(items, col)
+	}
+	s.orderBy = strings.Join(items, ",")
+	return nil
+}
+
+// parseOrder 解析一个排序字段, 返回字段名和是否降序
+func parseOrder(item string) (name string, desc bool, err error) {
+	var parts = strings.Fields(item)
+	switch len(parts) {
+	case 1:
+		name = parts[0]
+		if strings.HasPrefix(name, "-") {
+			name, desc = name[1:], true
+		}
+	case 2:
+		name = parts[0]
+		switch strings.ToLower(parts[1]) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return "", false, invalidFilter("invalid order %q", item)
+		}
+	default:
+		return "", false, invalidFilter("invalid order %q", item)
+	}
+	return name, desc, nil
+}
+
+// setPage limit 和 offset 必须是非负整数
+func (s *sqlBuilder) setPage(limit, offset string) error {
+	for _, v := range []string{limit, offset} {
+		if v == "" {
+			continue
+		}
+
+		if n, err := strconv.ParseUint(v, 10, 63); err != nil || n > 1<<53 {
+			return invalidFilter("invalid limit or offset %q", v)
+		}
+	}
+
+	s.limit, s.offset = limit, offset
+	return nil
 }
 
 func (s *sqlBuilder) orderFormat() string {
@@ -40,9 +158,11 @@ func (s *sqlBuilder) orderFormat() string {
 }
 
 func (s *sqlBuilder) _table() string {
-	table := "`" + s.table + "`"
+	table := s.dialect.Quote(s.table)
 	if s.forceIndex != "" {
-		table += fmt.Sprintf(" force index(%s)", s.forceIndex)
+		if hint := s.dialect.ForceIndex(s.forceIndex); hint != "" {
+			table += " " + hint
+		}
 	}
 	return table
 }
@@ -54,61 +174,136 @@ func (s *sqlBuilder) groupFormat() string {
 	return ""
 }
 
-//queryString Assemble the query statement
+// queryString Assemble the query statement
 func (s *sqlBuilder) queryString() string {
 	if s.fields == "" {
 		s.fields = "*"
 	}
-	return fmt.Sprintf("%sSELECT %s FROM %s %s %s %s %s %s;", s.hint, s.fields, s._table(), s.where, s.groupFormat(), s.orderFormat(), s.limitFormat(), s.offsetFormat())
+	top, page := s.dialect.Paginate(s.limit, s.offset, s.orderBy != "")
+	return s.statement(s.hint+"SELECT "+top+s.fields, "FROM", s._table(), s.where, s.groupFormat(), s.orderFormat(), page)
 }
 
-//countString Assemble the count statement
+// countString Assemble the count statement
 func (s *sqlBuilder) countString() string {
-	return fmt.Sprintf("%sSELECT count(*) FROM %s %s;", s.hint, s._table(), s.where)
+	return s.statement(s.hint+"SELECT count(*) FROM", s._table(), s.where)
 }
 
-//insertString Assemble the insert statement
-func (s *sqlBuilder) insertString(params map[string]interface{}) string {
-	var cols, vls []string
-	for k, v := range params {
-		cols = append(cols, fmt.Sprintf("`%s`", k))
+// sortedColumns 按照字段名排序, 保证生成的 SQL 稳定
+func (s *sqlBuilder) sortedColumns(params map[string]interface{}) ([]string, []string, error) {
+	var names = make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var cols = make([]string, len(names))
+	for i, name := range names {
+		col, err := s.column(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		cols[i] = col
+	}
+	return names, cols, nil
+}
+
+// insertString Assemble the insert statement, returning 为插入之后返回的字段
+func (s *sqlBuilder) insertString(params map[string]interface{}, returning ...string) (string, error) {
+	names, cols, err := s.sortedColumns(params)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range returning {
+		if _, err := s.column(name); err != nil {
+			return "", err
+		}
+	}
+
+	var vls []string
+	for _, k := range names {
 		vls = append(vls, "?")
-		s.args = append(s.args, v)
+		s.args = append(s.args, params[k])
 	}
-	return fmt.Sprintf("INSERT INTO %s (%s) VALUES(%s);", s._table(), strings.Join(cols, ","), strings.Join(vls, ","))
+
+	output, suffix := s.dialect.Returning(returning)
+	return s.statement("INSERT INTO", s._table(), "("+strings.Join(cols, ",")+")", output, "VALUES("+strings.Join(vls, ",")+")", suffix), nil
 }
 
-//updateString Assemble the update statement
-func (s *sqlBuilder) updateString(params map[string]interface{}) string {
+// updateString Assemble the update statement
+func (s *sqlBuilder) updateString(params map[string]interface{}) (string, error) {
+	names, cols, err := s.sortedColumns(params)
+	if err != nil {
+		return "", err
+	}
+
 	var updateFields []string
 	args := make([]interface{}, 0)
 
-	for k := range params {
-		updateFields = append(updateFields, fmt.Sprintf("%s=?", fmt.Sprintf("`%s`", k)))
+	for i, k := range names {
+		updateFields = append(updateFields, fmt.Sprintf("%s=?", cols[i]))
 		args = append(args, params[k])
 	}
 	s.args = append(args, s.args...)
-	return fmt.Sprintf("UPDATE %s SET %s %s;", s._table(), strings.Join(updateFields, ","), s.where)
+	return s.statement("UPDATE", s._table(), "SET", strings.Join(updateFields, ","), s.where), nil
 }
 
-//deleteString Assemble the delete statement
+// deleteString Assemble the delete statement
 func (s *sqlBuilder) deleteString() string {
-	return fmt.Sprintf("DELETE FROM %s %s;", s._table(), s.where)
+	return s.statement("DELETE FROM", s._table(), s.where)
 }
 
-func (s *sqlBuilder) Where(filter ...interface{}) {
+// statement 用空格连接不为空的部分, 并且替换参数的占位符
+func (s *sqlBuilder) statement(parts ...string) string {
+	var buf strings.Builder
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(p)
+	}
+	buf.WriteString(";")
+	return dialect.Rebind(s.dialect, buf.String())
+}
+
+// Where
+// filter[0] 为 *Cond 时根据表结构生成条件, 不能有其他参数
+// filter[0] 为 string 时作为原始的 SQL 条件, filter[1:] 为参数, 只能用于可信的输入
+func (s *sqlBuilder) Where(filter ...interface{}) error {
 	str := ""
 	var args []interface{}
 	_l := len(filter)
 	if _l > 0 {
-		str = filter[0].(string)
-		if _l > 1 {
-			args = filter[1:]
+		switch f := filter[0].(type) {
+		case *Cond:
+			if _l > 1 {
+				return invalidFilter("unexpected arguments after *Cond")
+			}
+
+			if f == nil {
+				return nil
+			}
+
+			var err error
+			if str, args, err = f.build(s.dialect, s.cols); err != nil {
+				return err
+			}
+		case string:
+			str = f
+			if _l > 1 {
+				args = filter[1:]
+			}
+		default:
+			return invalidFilter("unsupported filter type %T", filter[0])
 		}
 	}
 
 	if str == "" {
-		return
+		return nil
 	}
 
 	if s.where != "" {
@@ -118,7 +313,7 @@ func (s *sqlBuilder) Where(filter ...interface{}) {
 	}
 
 	if args == nil || len(args) == 0 {
-		return
+		return nil
 	}
 
 	if s.args == nil {
@@ -126,4 +321,5 @@ func (s *sqlBuilder) Where(filter ...interface{}) {
 	} else {
 		s.args = append(s.args, args...)
 	}
+	return nil
 }
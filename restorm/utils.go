@@ -9,7 +9,7 @@ import (
 	"strings"
 )
 
-//Import SQL DDL from sql file
+// Import SQL DDL from sql file
 func (t *RestOrm) Import(name string, f io.Reader) (res []sql.Result, err error) {
 	defer xerror.RespErr(&err)
 
@@ -32,16 +32,23 @@ func (t *RestOrm) Import(name string, f io.Reader) (res []sql.Result, err error)
 		return 0, nil, nil
 	})
 
+	p, err := t.acquire(name)
+	xerror.Panic(err)
+	defer p.release()
+
 	var results []sql.Result
 	for scanner.Scan() {
 		query := strings.Trim(scanner.Text(), " \t\n\r")
 		if len(query) > 0 {
-			result, err := t.cfg[name].db.Exec(query)
-			xerror.PanicM(err, "Import Exec error, db(%s)", name)
+			result, err := p.db.Exec(query)
+			xerror.PanicF(err, "Import Exec error, db(%s)", name)
 			results = append(results, result)
 		}
 	}
 
+	// 更新表结构, 导入的 DDL 可能修改了表
+	xerror.Panic(t.RefreshSchema(name))
+
 	res = results
 	return
 }
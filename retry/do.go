@@ -0,0 +1,171 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pubgo/xerror"
+)
+
+// Option 重试配置
+type Option func(opts *options)
+
+type options struct {
+	attempt    uint
+	maxElapsed time.Duration
+	strategy   Strategy
+	jitter     Transformation
+	retryIf    func(err error) bool
+	onRetry    func(attempt uint, err error, wait time.Duration)
+	budget     *Budget
+}
+
+// WithAttempt 最大尝试次数, 0 表示不限制, 默认 RetryTimes
+func WithAttempt(attempt uint) Option {
+	return func(opts *options) {
+		opts.attempt = attempt
+	}
+}
+
+// WithMaxElapsed 重试的最长时间, 下一次重试超过该时间时不再重试
+func WithMaxElapsed(dur time.Duration) Option {
+	return func(opts *options) {
+		opts.maxElapsed = dur
+	}
+}
+
+// WithStrategy 重试等待时间策略, 默认 Constant(RetryWait)
+func WithStrategy(strategy Strategy) Option {
+	return func(opts *options) {
+		opts.strategy = strategy
+	}
+}
+
+// WithJitter 对等待时间进行随机调整, 例如 FullJitter(), EqualJitter()
+func WithJitter(jitter Transformation) Option {
+	return func(opts *options) {
+		opts.jitter = jitter
+	}
+}
+
+// RetryIf 判断错误是否可以重试, 返回 false 时不再重试
+func RetryIf(fn func(err error) bool) Option {
+	return func(opts *options) {
+		opts.retryIf = fn
+	}
+}
+
+// OnRetry 每次重试之前调用
+func OnRetry(fn func(attempt uint, err error, wait time.Duration)) Option {
+	return func(opts *options) {
+		opts.onRetry = fn
+	}
+}
+
+// WithBudget 重试预算, 预算不足时不再重试, 例如 DefaultBudget
+func WithBudget(budget *Budget) Option {
+	return func(opts *options) {
+		opts.budget = budget
+	}
+}
+
+// Do
+// 执行 fn, 出错或者 panic 时按照策略重试, 直到成功, 次数用完, 超时, ctx 结束或者错误不可重试
+// 返回最后一次的错误, ctx 结束时返回 ctx 的错误
+func Do(ctx context.Context, fn func(ctx context.Context) error, opts ...Option) (err error) {
+	var _opts = &options{attempt: RetryTimes, strategy: Constant(RetryWait)}
+	for _, opt := range opts {
+		opt(_opts)
+	}
+
+	if fn == nil {
+		return ErrHandleNil
+	}
+
+	var wait = _opts.strategy()
+	var start = time.Now()
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for i := uint(1); ; i++ {
+		err = try(ctx, fn)
+		if err == nil {
+			_opts.budget.deposit()
+			return nil
+		}
+		_opts.budget.withdraw()
+
+		var _err *permanentError
+		if errors.As(err, &_err) {
+			return _err.err
+		}
+
+		if _opts.attempt > 0 && i >= _opts.attempt {
+			return
+		}
+
+		if _opts.retryIf != nil && !_opts.retryIf(err) {
+			return
+		}
+
+		dur := wait(i)
+		if _opts.jitter != nil && dur > 0 {
+			dur = _opts.jitter(dur)
+		}
+
+		if _opts.maxElapsed > 0 && time.Since(start)+dur > _opts.maxElapsed {
+			return
+		}
+
+		if !_opts.budget.allow() {
+			return
+		}
+
+		if _opts.onRetry != nil {
+			_opts.onRetry(i, err, dur)
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(dur)
+		} else {
+			timer.Reset(dur)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func try(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer xerror.RespErr(&err)
+	return fn(ctx)
+}
+
+// Permanent 包装不可重试的错误, Do 遇到该错误时直接返回 err
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断是否为不可重试的错误
+func IsPermanent(err error) bool {
+	var _err *permanentError
+	return errors.As(err, &_err)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (t *permanentError) Error() string { return t.err.Error() }
+func (t *permanentError) Unwrap() error { return t.err }
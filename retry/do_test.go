@@ -0,0 +1,117 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/retry"
+	"github.com/pubgo/xerror"
+)
+
+var errTest = errors.New("test error")
+
+func TestDo(t *testing.T) {
+	var n uint
+	var retries []uint
+	err := retry.Do(context.Background(), func(ctx context.Context) error {
+		n++
+		if n < 3 {
+			xerror.Panic(errTest)
+		}
+		return nil
+	},
+		retry.WithStrategy(retry.Constant(time.Millisecond)),
+		retry.OnRetry(func(attempt uint, err error, wait time.Duration) { retries = append(retries, attempt) }),
+	)
+
+	if err != nil || n != 3 || len(retries) != 2 {
+		t.Fatalf("expect success after 3 attempts, got %v, %d, %v", err, n, retries)
+	}
+}
+
+func TestDoStop(t *testing.T) {
+	var n int
+	err := retry.Do(context.Background(), func(ctx context.Context) error {
+		n++
+		return retry.Permanent(errTest)
+	})
+	if err != errTest || n != 1 {
+		t.Fatalf("expect permanent error, got %v, %d", err, n)
+	}
+
+	n = 0
+	err = retry.Do(context.Background(), func(ctx context.Context) error {
+		n++
+		return errTest
+	}, retry.WithStrategy(retry.Constant(time.Millisecond)), retry.RetryIf(func(err error) bool { return err != errTest }))
+	if err != errTest || n != 1 {
+		t.Fatalf("expect not retryable, got %v, %d", err, n)
+	}
+
+	n = 0
+	err = retry.Do(context.Background(), func(ctx context.Context) error {
+		n++
+		return errTest
+	}, retry.WithAttempt(0), retry.WithMaxElapsed(time.Millisecond*50), retry.WithStrategy(retry.Constant(time.Millisecond*20)))
+	if err != errTest || n != 3 {
+		t.Fatalf("expect max elapsed, got %v, %d", err, n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		return errTest
+	}, retry.WithAttempt(0), retry.WithStrategy(retry.Decorrelated(time.Millisecond, time.Millisecond*5)), retry.WithJitter(retry.FullJitter()))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	budget := retry.NewBudget(10, 1)
+
+	var n int
+	for i := 0; i < 10; i++ {
+		_ = retry.Do(context.Background(), func(ctx context.Context) error {
+			n++
+			return errTest
+		}, retry.WithAttempt(0), retry.WithStrategy(retry.Constant(0)), retry.WithBudget(budget))
+	}
+
+	// 10 tokens, the first call retries until tokens <= 5, then every call fails once
+	if n != 14 || budget.Tokens() != 0 {
+		t.Fatalf("expect budget exhausted, got %d, %f", n, budget.Tokens())
+	}
+}
+
+func TestFibonacci(t *testing.T) {
+	var expect = []time.Duration{1, 2, 3, 5, 8}
+	for i := 0; i < 2; i++ {
+		wait := retry.Fibonacci(1)()
+		for j := range expect {
+			if d := wait(uint(j + 1)); d != expect[j] {
+				t.Fatalf("expect %d, got %d", expect[j], d)
+			}
+		}
+	}
+}
+
+func TestExponential(t *testing.T) {
+	wait := retry.BinaryExponential(time.Millisecond, time.Second)()
+	if d := wait(3); d != time.Millisecond*8 {
+		t.Fatalf("expect 8ms, got %s", d)
+	}
+
+	// 超过 max 或者溢出时返回 max
+	for _, attempt := range []uint{10, 40, 64, 100, 10000} {
+		if d := wait(attempt); d != time.Second {
+			t.Fatalf("expect 1s for attempt %d, got %s", attempt, d)
+		}
+	}
+
+	if d := retry.Fibonacci(time.Second)()(200); d <= 0 {
+		t.Fatalf("expect positive wait, got %d", d)
+	}
+}
@@ -0,0 +1,8 @@
+package retry
+
+import "errors"
+
+var (
+	ErrHandleNil = errors.New("handle func is nil")
+	ErrType      = errors.New("type error")
+)
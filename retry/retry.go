@@ -1,9 +1,9 @@
 package retry
 
 import (
+	"context"
 	"github.com/pubgo/x/pkg/randutil"
-	"github.com/pubgo/x/xtry"
-	"log"
+	"github.com/pubgo/xerror"
 	"math"
 	"time"
 )
@@ -26,6 +26,8 @@ type retry struct {
 	transformation Transformation
 }
 
+// Retry
+// Deprecated: use Do
 func Retry(factor time.Duration, handle ...interface{}) error {
 	_r := &retry{factor: factor}
 	for _, h := range handle {
@@ -45,7 +47,7 @@ func Retry(factor time.Duration, handle ...interface{}) error {
 		case *retry:
 			_r = hdl
 		default:
-			log.Fatalf("type error %#v", hdl)
+			return xerror.WrapF(ErrType, "%#v", hdl)
 		}
 	}
 	return _r.Do()
@@ -106,15 +108,8 @@ func (t *retry) BinaryExponential() *retry {
 
 // Fibonacci 斐波那契递增
 func (t *retry) Fibonacci() *retry {
-	fibonacci := func() func() int {
-		a, b := 1, 1
-		return func() int {
-			a, b = b, a+b
-			return a
-		}
-	}()
-	t.strategy = func(_ uint) time.Duration {
-		return t.factor * time.Duration(fibonacci())
+	t.strategy = func(attempt uint) time.Duration {
+		return scale(t.factor, fibonacci(attempt))
 	}
 	return t
 }
@@ -126,32 +121,34 @@ func (t *retry) Go() (err error) {
 
 // Do 运行
 func (t *retry) Do() (err error) {
+	if t.handle == nil {
+		return ErrHandleNil
+	}
+
 	if t.delay > 0 {
 		time.Sleep(t.delay)
 	}
 
-	if t.attempt < 1 {
-		t.attempt = math.MaxInt32
+	var strategy = t.strategy
+	if strategy == nil {
+		strategy = func(_ uint) time.Duration { return t.factor }
 	}
 
-	if t.handle == nil {
-		log.Fatalln("handle func is nil")
+	var opts = []Option{
+		WithAttempt(t.attempt),
+		WithStrategy(func() func(uint) time.Duration { return strategy }),
+		WithJitter(t.transformation),
 	}
-
-	var dur time.Duration
-	for i := uint(1); i <= t.attempt && t.deadline.Before(time.Now()); i++ {
-		if err = xtry.Try(func() {
-			if t.transformation != nil {
-				dur = t.transformation(t.strategy(i))
-			}
-
-			t.handle(i, dur)
-		})()(); err == nil {
-			return
-		}
-		time.Sleep(dur)
+	if !t.deadline.IsZero() {
+		opts = append(opts, WithMaxElapsed(time.Until(t.deadline)))
 	}
-	return
+
+	var attempt uint
+	return Do(context.Background(), func(ctx context.Context) error {
+		attempt++
+		t.handle(attempt, strategy(attempt))
+		return nil
+	}, opts...)
 }
 
 // Deadline 重试截止日期
@@ -0,0 +1,156 @@
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Strategy 重试等待时间策略
+// 每次 Do 调用 Strategy 生成新的等待时间函数, 状态不会在多次调用之间共享
+type Strategy func() func(attempt uint) time.Duration
+
+// Constant 固定等待时间
+func Constant(factor time.Duration) Strategy {
+	return func() func(uint) time.Duration {
+		return func(_ uint) time.Duration {
+			return factor
+		}
+	}
+}
+
+// Incremental 线性递增
+func Incremental(factor time.Duration) Strategy {
+	return func() func(uint) time.Duration {
+		return func(attempt uint) time.Duration {
+			return scale(factor, float64(attempt))
+		}
+	}
+}
+
+// Exponential 指数递增, 最大不超过 max
+func Exponential(factor time.Duration, base float64, max time.Duration) Strategy {
+	return func() func(uint) time.Duration {
+		return func(attempt uint) time.Duration {
+			if d := scale(factor, math.Pow(base, float64(attempt))); d < max {
+				return d
+			}
+			return max
+		}
+	}
+}
+
+// BinaryExponential 二进制指数递增, 最大不超过 max
+func BinaryExponential(factor, max time.Duration) Strategy {
+	return Exponential(factor, 2, max)
+}
+
+// Fibonacci 斐波那契递增
+func Fibonacci(factor time.Duration) Strategy {
+	return func() func(uint) time.Duration {
+		return func(attempt uint) time.Duration {
+			return scale(factor, fibonacci(attempt))
+		}
+	}
+}
+
+// scale factor*n, 溢出时返回 time.Duration 的最大值
+func scale(factor time.Duration, n float64) time.Duration {
+	if d := float64(factor) * n; d < math.MaxInt64 {
+		return time.Duration(d)
+	}
+	return math.MaxInt64
+}
+
+// Decorrelated 去相关抖动, 等待时间在 [base, 上一次等待时间*3) 之间随机, 最大不超过 max
+//
+// Inspired by https://www.awsarchitectureblog.com/2015/03/backoff.html
+func Decorrelated(base, max time.Duration) Strategy {
+	return func() func(uint) time.Duration {
+		var sleep = base
+		return func(_ uint) time.Duration {
+			if n := int64(sleep*3 - base); n > 0 {
+				sleep = base + time.Duration(rand.Int63n(n))
+			}
+
+			if sleep > max {
+				sleep = max
+			}
+			return sleep
+		}
+	}
+}
+
+func fibonacci(n uint) float64 {
+	var a, b float64 = 1, 2
+	for i := uint(1); i < n && !math.IsInf(a, 1); i++ {
+		a, b = b, a+b
+	}
+	return a
+}
+
+// DefaultBudget 进程级别的重试预算
+var DefaultBudget = NewBudget(100, 0.1)
+
+// NewBudget
+// 基于令牌的重试预算, 令牌数低于 maxTokens 的一半时不再重试
+// 每次失败消耗一个令牌, 每次成功增加 ratio 个令牌, 例如 ratio 为 0.1 时, 大约每 10 次成功可以支撑 1 次重试
+//
+// Inspired by https://github.com/grpc/proposal/blob/master/A6-client-retries.md#throttling-retry-attempts-and-hedged-rpcs
+func NewBudget(maxTokens, ratio float64) *Budget {
+	return &Budget{max: maxTokens, tokens: maxTokens, ratio: ratio}
+}
+
+// Budget 重试预算, 防止依赖故障时出现重试风暴
+type Budget struct {
+	mu     sync.Mutex
+	max    float64
+	tokens float64
+	ratio  float64
+}
+
+// Tokens 当前令牌数
+func (t *Budget) Tokens() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.tokens
+}
+
+func (t *Budget) allow() bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.tokens > t.max/2
+}
+
+func (t *Budget) deposit() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tokens += t.ratio; t.tokens > t.max {
+		t.tokens = t.max
+	}
+}
+
+func (t *Budget) withdraw() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tokens--; t.tokens < 0 {
+		t.tokens = 0
+	}
+}
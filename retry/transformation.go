@@ -16,9 +16,7 @@ type Transformation func(duration time.Duration) time.Duration
 //
 // Inspired by https://www.awsarchitectureblog.com/2015/03/backoff.html
 func (t *retry) Full() Transformation {
-	return func(duration time.Duration) time.Duration {
-		return time.Duration(rand.Int63n(int64(duration)))
-	}
+	return FullJitter()
 }
 
 // Equal creates a Transformation that transforms a duration into a result
@@ -28,6 +26,18 @@ func (t *retry) Full() Transformation {
 //
 // Inspired by https://www.awsarchitectureblog.com/2015/03/backoff.html
 func (t *retry) Equal() Transformation {
+	return EqualJitter()
+}
+
+// FullJitter same as Full, for Do
+func FullJitter() Transformation {
+	return func(duration time.Duration) time.Duration {
+		return time.Duration(rand.Int63n(int64(duration)))
+	}
+}
+
+// EqualJitter same as Equal, for Do
+func EqualJitter() Transformation {
 	return func(duration time.Duration) time.Duration {
 		return (duration / 2) + time.Duration(rand.Int63n(int64(duration))/2)
 	}
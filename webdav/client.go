@@ -3,7 +3,9 @@ package gowebdav
 import (
 	"bytes"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -337,6 +339,42 @@ func (c *Client) ReadStream(path string) (io.ReadCloser, error) {
 	return nil, newPathError("ReadStream", path, rs.StatusCode)
 }
 
+// ReadStreamRange reads the stream starting at offset for length bytes,
+// length < 0 reads until the end of the file
+func (c *Client) ReadStreamRange(path string, offset, length int64) (io.ReadCloser, error) {
+	rs, err := c.req("GET", path, nil, func(r *http.Request) {
+		if length < 0 {
+			r.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+		} else {
+			r.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+		}
+	})
+	if err != nil {
+		return nil, newPathErrorErr("ReadStreamRange", path, err)
+	}
+
+	switch rs.StatusCode {
+	case 206:
+		return rs.Body, nil
+	case 200:
+		// the server ignored the Range header, skip and limit the body ourselves
+		if _, err := io.CopyN(ioutil.Discard, rs.Body, offset); err != nil {
+			rs.Body.Close()
+			return nil, newPathErrorErr("ReadStreamRange", path, err)
+		}
+		if length < 0 {
+			return rs.Body, nil
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(rs.Body, length), rs.Body}, nil
+	}
+
+	rs.Body.Close()
+	return nil, newPathError("ReadStreamRange", path, rs.StatusCode)
+}
+
 // Write writes data to a given path
 func (c *Client) Write(path string, data []byte, _ os.FileMode) error {
 	s := c.put(path, bytes.NewReader(data))
@@ -28,6 +28,19 @@ func newPathErrorErr(op string, path string, err error) error {
 	}
 }
 
+// IsErrCode returns true if err is a *os.PathError carrying the given http status code
+func IsErrCode(err error, code int) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err != nil && pe.Err.Error() == strconv.Itoa(code)
+	}
+	return false
+}
+
+// IsErrNotFound returns true if the remote file does not exist
+func IsErrNotFound(err error) bool {
+	return IsErrCode(err, 404)
+}
+
 // PathEscape escapes all segemnts of a given path
 func PathEscape(path string) string {
 	s := strings.Split(path, "/")
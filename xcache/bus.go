@@ -0,0 +1,53 @@
+package xcache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+var _ xcache_abc.Bus = (*localBus)(nil)
+
+// NewLocalBus 进程内的 xcache_abc.Bus, 同步调用订阅者, 用于测试和单实例部署
+func NewLocalBus() xcache_abc.Bus {
+	return &localBus{subs: make(map[string]map[int]func(msg []byte))}
+}
+
+type localBus struct {
+	mu   sync.RWMutex
+	seq  int
+	subs map[string]map[int]func(msg []byte)
+}
+
+func (b *localBus) Publish(_ context.Context, channel string, msg []byte) error {
+	b.mu.RLock()
+	var handlers = make([]func(msg []byte), 0, len(b.subs[channel]))
+	for _, h := range b.subs[channel] {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(append([]byte(nil), msg...))
+	}
+	return nil
+}
+
+func (b *localBus) Subscribe(_ context.Context, channel string, handler func(msg []byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	var id = b.seq
+	if b.subs[channel] == nil {
+		b.subs[channel] = make(map[int]func(msg []byte))
+	}
+	b.subs[channel][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[channel], id)
+	}, nil
+}
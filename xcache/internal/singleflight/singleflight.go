@@ -1,6 +1,12 @@
 package singleflight
 
-import "sync"
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPanic 执行中的 fn panic
+var ErrPanic = errors.New("singleflight: fn panicked")
 
 // call is an in-flight or completed Do call
 type call struct {
@@ -35,12 +41,24 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	g.m[key] = c
 	g.mu.Unlock()
 
-	c.val, c.err = fn()
-	c.wg.Done()
+	g.doCall(c, key, fn)
+	return c.val, c.err
+}
 
-	g.mu.Lock()
-	delete(g.m, key)
-	g.mu.Unlock()
+// doCall fn panic 时等待中的调用返回 ErrPanic, panic 继续向上传递
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	var normal bool
+	defer func() {
+		if !normal {
+			c.err = ErrPanic
+		}
+		c.wg.Done()
 
-	return c.val, c.err
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+	}()
+
+	c.val, c.err = fn()
+	normal = true
 }
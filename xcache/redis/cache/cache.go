@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v7"
+	"github.com/pubgo/x/xcache/internal/lrucache"
+	"github.com/pubgo/x/xcache/internal/singleflight"
 )
 
 var ErrCacheMiss = errs.New("cache: key is missing")
@@ -55,14 +57,14 @@ func (item *Item) exp() time.Duration {
 }
 
 type Codec struct {
-	Redis xservice.IRedis
+	Redis IRedis
 
-	localCache *xservice.Cache
+	localCache *lrucache.Cache
 
 	Marshal   func(interface{}) ([]byte, error)
 	Unmarshal func([]byte, interface{}) error
 
-	group xservice.Group
+	group singleflight.Group
 
 	hits        uint64
 	misses      uint64
@@ -72,7 +74,7 @@ type Codec struct {
 
 // UseLocalCache causes Codec to cache items in local LRU cache.
 func (cd *Codec) UseLocalCache(maxLen int, expiration time.Duration) {
-	cd.localCache = xservice.New(maxLen, expiration)
+	cd.localCache = lrucache.New(maxLen, expiration)
 }
 
 // Set caches the item.
@@ -89,7 +91,7 @@ func (cd *Codec) set(ctx context.Context, key string, obj interface{}, exp time.
 	defer xerror.RespErr(&err)
 
 	b, err = cd.Marshal(obj)
-	xerror.PanicM(err, "cache: Marshal key=%q failed: %s", key, err)
+	xerror.PanicF(err, "cache: Marshal key=%q failed: %s", key, err)
 
 	if cd.localCache != nil {
 		cd.localCache.Set(key, b)
@@ -103,7 +105,7 @@ func (cd *Codec) set(ctx context.Context, key string, obj interface{}, exp time.
 	}
 
 	err = cd.Redis.Set(key, b, exp).Err()
-	xerror.PanicM(err, "cache: Set key=%q failed: %s", key, err)
+	xerror.PanicF(err, "cache: Set key=%q failed: %s", key, err)
 
 	return b, err
 }
@@ -137,7 +139,7 @@ func (cd *Codec) get(ctx context.Context, key string, object interface{}, onlyLo
 	}
 
 	err = cd.Unmarshal(b, object)
-	xerror.PanicM(err, "cache: key=%q Unmarshal(%T) failed: %s", key, object, err)
+	xerror.PanicF(err, "cache: key=%q Unmarshal(%T) failed: %s", key, object, err)
 
 	return nil
 }
@@ -169,7 +171,7 @@ func (cd *Codec) getBytes(key string, onlyLocalCache bool) ([]byte, error) {
 			return nil, ErrCacheMiss
 		}
 
-		xerror.PanicM(err, "cache: Get key=%q failed: %s", key, err)
+		xerror.PanicF(err, "cache: Get key=%q failed: %s", key, err)
 		return nil, err
 	}
 	atomic.AddUint64(&cd.hits, 1)
@@ -201,7 +203,7 @@ func (cd *Codec) Once(item *Item) error {
 			_ = cd.Delete(item.Key)
 			return cd.Once(item)
 		}
-		xerror.PanicM(err, "cache: key=%q Unmarshal(%T) failed: %s", item.Key, item.Object, err)
+		xerror.PanicF(err, "cache: key=%q Unmarshal(%T) failed: %s", item.Key, item.Object, err)
 		return err
 	}
 
@@ -266,7 +268,7 @@ func (cd *Codec) DeleteContext(ctx context.Context, key string) error {
 	}
 
 	deleted, err := cd.Redis.Del(key).Result()
-	xerror.PanicM(err, "cache: Del key=%q failed: %s", key, err)
+	xerror.PanicF(err, "cache: Del key=%q failed: %s", key, err)
 
 	if deleted == 0 {
 		return ErrCacheMiss
@@ -0,0 +1,141 @@
+package xcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xcache"
+	"github.com/pubgo/x/xcache/xcache_abc"
+	"github.com/pubgo/x/xcache/xcache_memory"
+)
+
+func testTagCache(t *testing.T, c xcache_abc.TagCache) {
+	var ctx = context.Background()
+	var exists = func(key string) bool {
+		_, err := c.Get(ctx, key)
+		if err != nil && !errors.Is(err, xcache_abc.ErrCacheMiss) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	var set = func(key string, tags ...string) {
+		if err := c.SetWithTags(ctx, key, []byte(key), xcache_abc.DEFAULT, tags...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set("article:1", "article", "user:1")
+	set("article:2", "article", "user:2")
+	set("link:1", "link", "user:1")
+	set("user:42:profile")
+	set("user:42:links")
+	set("user:420:profile")
+
+	if err := c.InvalidateTags(ctx, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	if exists("article:1") || exists("link:1") || !exists("article:2") {
+		t.Fatal("InvalidateTags user:1")
+	}
+
+	if err := c.InvalidateTags(ctx, "article", "unknown"); err != nil {
+		t.Fatal(err)
+	}
+	if exists("article:2") {
+		t.Fatal("InvalidateTags article")
+	}
+
+	if err := c.DeletePrefix(ctx, "user:42:"); err != nil {
+		t.Fatal(err)
+	}
+	if exists("user:42:profile") || exists("user:42:links") || !exists("user:420:profile") {
+		t.Fatal("DeletePrefix user:42:")
+	}
+
+	// 失效之后重新写入
+	set("article:1", "article")
+	if !exists("article:1") {
+		t.Fatal("rewrite after InvalidateTags")
+	}
+}
+
+func TestTagCache(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testTagCache(t, xcache.Tags(xcache_memory.NewCache(time.Minute)))
+	})
+
+	t.Run("versioned", func(t *testing.T) {
+		testTagCache(t, xcache.NewVersioned(xcache_memory.NewCache(time.Minute)))
+	})
+}
+
+func TestVersioned(t *testing.T) {
+	var ctx = context.Background()
+	var c = xcache.NewVersioned(xcache_memory.NewCache(time.Minute))
+
+	if err := c.DeletePrefix(ctx, "user"); !errors.Is(err, xcache_abc.ErrNotSupport) {
+		t.Fatal(err)
+	}
+
+	// 计数器不受失效影响
+	if _, err := c.Increment(ctx, "user:count", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeletePrefix(ctx, "user:"); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get(ctx, "user:count"); err != nil || string(val) != "2" {
+		t.Fatal(string(val), err)
+	}
+
+	if err := c.SetMulti(ctx, map[string][]byte{"user:1:a": []byte("a"), "user:2:b": []byte("b")}, xcache_abc.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeletePrefix(ctx, "user:1:"); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := c.GetMulti(ctx, []string{"user:1:a", "user:2:b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := items["user:1:a"]; ok || string(items["user:2:b"]) != "b" {
+		t.Fatal(items)
+	}
+}
+
+func TestVersionedEvicted(t *testing.T) {
+	var ctx = context.Background()
+	var store = xcache_memory.NewCache(time.Minute)
+	var c = xcache.NewVersioned(store)
+
+	if err := c.SetWithTags(ctx, "a", []byte("a"), xcache_abc.DEFAULT, "t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InvalidateTags(ctx, "t"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 版本号被淘汰之后, 失效之前写入的数据不会重新生效
+	if err := store.Delete(ctx, xcache_abc.KeyPrefix+":ver:t:t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetWithTags(ctx, "b", []byte("b"), xcache_abc.DEFAULT, "t"); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := c.GetMulti(ctx, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := items["a"]; ok || string(items["b"]) != "b" {
+		t.Fatal(items)
+	}
+
+	if ttl, err := store.TTL(ctx, xcache_abc.KeyPrefix+":ver:t:t"); err != nil || ttl != xcache_abc.FOREVER {
+		t.Fatal(ttl, err)
+	}
+}
@@ -0,0 +1,311 @@
+package xcache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/pubgo/x/xcache/xcache_abc"
+	"github.com/pubgo/xerror"
+)
+
+var _ xcache_abc.Cache = (*Tiered)(nil)
+
+// TieredOption Tiered 配置
+type TieredOption func(t *Tiered)
+
+// WithBus 通过 bus 的 channel 广播失效消息, 例如 xcache_redis.NewBus(client)
+func WithBus(bus xcache_abc.Bus, channel string) TieredOption {
+	return func(t *Tiered) {
+		t.bus = bus
+		t.channel = channel
+	}
+}
+
+// WithLocalExpire 本地缓存的最长过期时间, 默认 1 分钟, 从 remote 回填或者以 DEFAULT 写入时不超过 remote 中剩余的过期时间
+func WithLocalExpire(expire time.Duration) TieredOption {
+	return func(t *Tiered) {
+		t.localExpire = expire
+	}
+}
+
+// TieredStats 每一层的命中统计
+type TieredStats struct {
+	Hits        uint64
+	Misses      uint64
+	LocalHits   uint64
+	LocalMisses uint64
+}
+
+type invalidation struct {
+	ID    string   `json:"id"`
+	Keys  []string `json:"keys,omitempty"`
+	Flush bool     `json:"flush,omitempty"`
+}
+
+// NewTiered
+// 两级缓存, local 为本地缓存, remote 为远程缓存
+// 写操作先写 remote, 然后更新本地缓存并广播失效消息, 其他实例收到消息之后删除本地缓存
+// 没有配置 bus 时, 其他实例的本地缓存在过期之前可能是旧数据
+func NewTiered(local, remote xcache_abc.Cache, opts ...TieredOption) (*Tiered, error) {
+	var id = make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var t = &Tiered{local: local, remote: remote, id: hex.EncodeToString(id), localExpire: time.Minute}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.bus == nil {
+		return t, nil
+	}
+
+	cancel, err := t.bus.Subscribe(context.Background(), t.channel, t.onInvalidate)
+	if err != nil {
+		return nil, xerror.WrapF(err, "cache: Subscribe channel=%q failed", t.channel)
+	}
+	t.cancel = cancel
+	return t, nil
+}
+
+// Tiered 两级缓存
+type Tiered struct {
+	local       xcache_abc.Cache
+	remote      xcache_abc.Cache
+	localExpire time.Duration
+
+	id      string
+	bus     xcache_abc.Bus
+	channel string
+	cancel  func()
+
+	// gen 本地缓存失效的次数, 从 remote 读取期间发生失效时不回填本地缓存
+	gen uint64
+
+	hits        uint64
+	misses      uint64
+	localHits   uint64
+	localMisses uint64
+}
+
+// Close 取消订阅失效消息
+func (t *Tiered) Close() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
+// Stats 每一层的命中统计
+func (t *Tiered) Stats() *TieredStats {
+	return &TieredStats{
+		Hits:        atomic.LoadUint64(&t.hits),
+		Misses:      atomic.LoadUint64(&t.misses),
+		LocalHits:   atomic.LoadUint64(&t.localHits),
+		LocalMisses: atomic.LoadUint64(&t.localMisses),
+	}
+}
+
+func (t *Tiered) onInvalidate(msg []byte) {
+	var inv invalidation
+	if err := json.Unmarshal(msg, &inv); err != nil || inv.ID == t.id {
+		return
+	}
+
+	atomic.AddUint64(&t.gen, 1)
+	if inv.Flush {
+		_ = t.local.Flush(context.Background())
+		return
+	}
+	_ = t.local.DeleteMulti(context.Background(), inv.Keys)
+}
+
+func (t *Tiered) publish(ctx context.Context, inv invalidation) error {
+	if t.bus == nil {
+		return nil
+	}
+
+	inv.ID = t.id
+	msg, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return xerror.WrapF(t.bus.Publish(ctx, t.channel, msg), "cache: Publish channel=%q failed", t.channel)
+}
+
+func (t *Tiered) expire(expire time.Duration) time.Duration {
+	if expire <= 0 || expire > t.localExpire {
+		return t.localExpire
+	}
+	return expire
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := t.local.Get(ctx, key)
+	if err == nil {
+		atomic.AddUint64(&t.localHits, 1)
+		return val, nil
+	}
+	atomic.AddUint64(&t.localMisses, 1)
+
+	var gen = atomic.LoadUint64(&t.gen)
+	val, err = t.remote.Get(ctx, key)
+	if err != nil {
+		atomic.AddUint64(&t.misses, 1)
+		return nil, err
+	}
+	atomic.AddUint64(&t.hits, 1)
+
+	if expire, ok := t.backfillExpire(ctx, key); ok && atomic.LoadUint64(&t.gen) == gen {
+		_ = t.local.Set(ctx, key, val, expire)
+	}
+	return val, nil
+}
+
+// backfillExpire
+// 回填本地缓存的过期时间, 不超过 remote 中剩余的过期时间, remote 不支持 TTL 时使用 localExpire
+// remote 中已经过期时不回填
+func (t *Tiered) backfillExpire(ctx context.Context, key string) (time.Duration, bool) {
+	ttl, err := t.remote.TTL(ctx, key)
+	switch {
+	case err == nil && ttl == xcache_abc.FOREVER:
+		return t.localExpire, true
+	case err == nil && ttl > 0:
+		return t.expire(ttl), true
+	case errors.Is(err, xcache_abc.ErrNotSupport):
+		return t.localExpire, true
+	default:
+		return 0, false
+	}
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, expire time.Duration) error {
+	return t.SetMulti(ctx, map[string][]byte{key: value}, expire)
+}
+
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	return t.DeleteMulti(ctx, []string{key})
+}
+
+func (t *Tiered) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	items, err := t.local.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&t.localHits, uint64(len(items)))
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := items[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return items, nil
+	}
+	atomic.AddUint64(&t.localMisses, uint64(len(missing)))
+
+	var gen = atomic.LoadUint64(&t.gen)
+	remoteItems, err := t.remote.GetMulti(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&t.hits, uint64(len(remoteItems)))
+	atomic.AddUint64(&t.misses, uint64(len(missing)-len(remoteItems)))
+
+	for key, val := range remoteItems {
+		items[key] = val
+	}
+
+	for key, val := range remoteItems {
+		if expire, ok := t.backfillExpire(ctx, key); ok && atomic.LoadUint64(&t.gen) == gen {
+			_ = t.local.Set(ctx, key, val, expire)
+		}
+	}
+	return items, nil
+}
+
+func (t *Tiered) SetMulti(ctx context.Context, items map[string][]byte, expire time.Duration) error {
+	atomic.AddUint64(&t.gen, 1)
+	if err := t.remote.SetMulti(ctx, items, expire); err != nil {
+		return err
+	}
+
+	if err := t.setLocal(ctx, items, expire); err != nil {
+		return err
+	}
+
+	var keys = make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	return t.publish(ctx, invalidation{Keys: keys})
+}
+
+// setLocal
+// expire 为 DEFAULT 时由 remote 决定过期时间, 和回填一样不超过 remote 中剩余的过期时间
+func (t *Tiered) setLocal(ctx context.Context, items map[string][]byte, expire time.Duration) error {
+	if expire != xcache_abc.DEFAULT {
+		return t.local.SetMulti(ctx, items, t.expire(expire))
+	}
+
+	for key, val := range items {
+		if expire, ok := t.backfillExpire(ctx, key); ok {
+			if err := t.local.Set(ctx, key, val, expire); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Tiered) DeleteMulti(ctx context.Context, keys []string) error {
+	atomic.AddUint64(&t.gen, 1)
+	if err := t.remote.DeleteMulti(ctx, keys); err != nil {
+		return err
+	}
+
+	if err := t.local.DeleteMulti(ctx, keys); err != nil {
+		return err
+	}
+	return t.publish(ctx, invalidation{Keys: keys})
+}
+
+func (t *Tiered) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	atomic.AddUint64(&t.gen, 1)
+	n, err := t.remote.Increment(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := t.local.Delete(ctx, key); err != nil {
+		return 0, err
+	}
+	return n, t.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+func (t *Tiered) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return t.Increment(ctx, key, -delta)
+}
+
+func (t *Tiered) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return t.remote.TTL(ctx, key)
+}
+
+func (t *Tiered) Flush(ctx context.Context) error {
+	atomic.AddUint64(&t.gen, 1)
+	if err := t.remote.Flush(ctx); err != nil {
+		return err
+	}
+
+	if err := t.local.Flush(ctx); err != nil {
+		return err
+	}
+	return t.publish(ctx, invalidation{Flush: true})
+}
@@ -0,0 +1,138 @@
+package xcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xcache"
+	"github.com/pubgo/x/xcache/xcache_abc"
+	"github.com/pubgo/x/xcache/xcache_memory"
+)
+
+func TestTiered(t *testing.T) {
+	var ctx = context.Background()
+	var remote = xcache_memory.NewCache(time.Minute)
+	var bus = xcache.NewLocalBus()
+
+	var newTiered = func() *xcache.Tiered {
+		c, err := xcache.NewTiered(xcache_memory.NewCache(time.Minute), remote, xcache.WithBus(bus, "invalidate"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	var a, b = newTiered(), newTiered()
+	defer a.Close()
+	defer b.Close()
+
+	if err := a.Set(ctx, "k", []byte("v1"), xcache_abc.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+
+	// b 从 remote 读取并回填本地缓存
+	for i := 0; i < 2; i++ {
+		if val, err := b.Get(ctx, "k"); err != nil || string(val) != "v1" {
+			t.Fatal(string(val), err)
+		}
+	}
+
+	if s := b.Stats(); s.Hits != 1 || s.LocalHits != 1 || s.LocalMisses != 1 {
+		t.Fatal(s)
+	}
+
+	// a 更新之后 b 的本地缓存失效
+	if err := a.Set(ctx, "k", []byte("v2"), xcache_abc.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+
+	if val, err := b.Get(ctx, "k"); err != nil || string(val) != "v2" {
+		t.Fatal(string(val), err)
+	}
+
+	if val, err := a.Get(ctx, "k"); err != nil || string(val) != "v2" {
+		t.Fatal(string(val), err)
+	}
+
+	if s := a.Stats(); s.LocalHits != 1 || s.Hits != 0 {
+		t.Fatal(s)
+	}
+
+	if _, err := a.Increment(ctx, "n", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := b.GetMulti(ctx, []string{"k", "n", "none"})
+	if err != nil || string(items["k"]) != "v2" || string(items["n"]) != "2" || len(items) != 2 {
+		t.Fatal(items, err)
+	}
+
+	if err := b.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Get(ctx, "k"); !errors.Is(err, xcache_abc.ErrCacheMiss) {
+		t.Fatal(err)
+	}
+
+	if err := a.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Get(ctx, "n"); !errors.Is(err, xcache_abc.ErrCacheMiss) {
+		t.Fatal(err)
+	}
+}
+
+func TestTieredBackfillExpire(t *testing.T) {
+	var ctx = context.Background()
+	var remote = xcache_memory.NewCache(time.Minute)
+	c, err := xcache.NewTiered(xcache_memory.NewCache(time.Minute), remote, xcache.WithLocalExpire(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := remote.Set(ctx, "k", []byte("v"), time.Millisecond*50); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回填本地缓存的过期时间不超过 remote 中剩余的过期时间
+	if val, err := c.Get(ctx, "k"); err != nil || string(val) != "v" {
+		t.Fatal(string(val), err)
+	}
+
+	if items, err := c.GetMulti(ctx, []string{"k"}); err != nil || string(items["k"]) != "v" {
+		t.Fatal(items, err)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, xcache_abc.ErrCacheMiss) {
+		t.Fatal(err)
+	}
+}
+
+func TestTieredSetDefaultExpire(t *testing.T) {
+	var ctx = context.Background()
+	c, err := xcache.NewTiered(xcache_memory.NewCache(time.Hour), xcache_memory.NewCache(time.Millisecond*50), xcache.WithLocalExpire(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// remote 默认的过期时间比 localExpire 短, 本地缓存不能比 remote 活得更久
+	if err := c.Set(ctx, "k", []byte("v"), xcache_abc.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+
+	if val, err := c.Get(ctx, "k"); err != nil || string(val) != "v" {
+		t.Fatal(string(val), err)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, xcache_abc.ErrCacheMiss) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package xcache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pubgo/x/xcache/xcache_abc"
+	"github.com/pubgo/xerror"
+)
+
+// TypedOption Typed 配置
+type TypedOption func(opts *typedOptions)
+
+type typedOptions struct {
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+}
+
+// WithCodec 序列化方式, 默认 json
+func WithCodec(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) TypedOption {
+	return func(opts *typedOptions) {
+		opts.marshal = marshal
+		opts.unmarshal = unmarshal
+	}
+}
+
+// NewTyped 在 xcache_abc.Cache 的基础上处理 T 的序列化
+func NewTyped[T any](c xcache_abc.Cache, opts ...TypedOption) *Typed[T] {
+	var _opts = typedOptions{marshal: json.Marshal, unmarshal: json.Unmarshal}
+	for _, opt := range opts {
+		opt(&_opts)
+	}
+	return &Typed[T]{c: c, opts: _opts}
+}
+
+// Typed 类型安全的缓存
+type Typed[T any] struct {
+	c    xcache_abc.Cache
+	opts typedOptions
+}
+
+// Cache 底层的缓存
+func (t *Typed[T]) Cache() xcache_abc.Cache {
+	return t.c
+}
+
+// Get key 不存在时返回 xcache_abc.ErrCacheMiss
+func (t *Typed[T]) Get(ctx context.Context, key string) (val T, err error) {
+	data, err := t.c.Get(ctx, key)
+	if err != nil {
+		return val, err
+	}
+
+	return val, xerror.WrapF(t.opts.unmarshal(data, &val), "cache: key=%q Unmarshal(%T) failed", key, val)
+}
+
+func (t *Typed[T]) Set(ctx context.Context, key string, val T, expire time.Duration) error {
+	data, err := t.opts.marshal(val)
+	if err != nil {
+		return xerror.WrapF(err, "cache: key=%q Marshal(%T) failed", key, val)
+	}
+	return t.c.Set(ctx, key, data, expire)
+}
+
+func (t *Typed[T]) Delete(ctx context.Context, key string) error {
+	return t.c.Delete(ctx, key)
+}
+
+// GetMulti 返回的 map 中只包含存在的 key
+func (t *Typed[T]) GetMulti(ctx context.Context, keys []string) (map[string]T, error) {
+	items, err := t.c.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var vals = make(map[string]T, len(items))
+	for key, data := range items {
+		var val T
+		if err := t.opts.unmarshal(data, &val); err != nil {
+			return nil, xerror.WrapF(err, "cache: key=%q Unmarshal(%T) failed", key, val)
+		}
+		vals[key] = val
+	}
+	return vals, nil
+}
+
+func (t *Typed[T]) SetMulti(ctx context.Context, vals map[string]T, expire time.Duration) error {
+	var items = make(map[string][]byte, len(vals))
+	for key, val := range vals {
+		data, err := t.opts.marshal(val)
+		if err != nil {
+			return xerror.WrapF(err, "cache: key=%q Marshal(%T) failed", key, val)
+		}
+		items[key] = data
+	}
+	return t.c.SetMulti(ctx, items, expire)
+}
+
+func (t *Typed[T]) DeleteMulti(ctx context.Context, keys []string) error {
+	return t.c.DeleteMulti(ctx, keys)
+}
+
+func (t *Typed[T]) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return t.c.TTL(ctx, key)
+}
+
+// SetWithTags 写入 key 并关联 tags, 底层的缓存没有实现 xcache_abc.TagCache 时使用 NewVersioned
+func (t *Typed[T]) SetWithTags(ctx context.Context, key string, val T, expire time.Duration, tags ...string) error {
+	data, err := t.opts.marshal(val)
+	if err != nil {
+		return xerror.WrapF(err, "cache: key=%q Marshal(%T) failed", key, val)
+	}
+	return Tags(t.c).SetWithTags(ctx, key, data, expire, tags...)
+}
+
+// InvalidateTags 删除关联了任意一个 tag 的 key
+func (t *Typed[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	return Tags(t.c).InvalidateTags(ctx, tags...)
+}
+
+// DeletePrefix 删除以 prefix 开头的 key
+func (t *Typed[T]) DeletePrefix(ctx context.Context, prefix string) error {
+	return Tags(t.c).DeletePrefix(ctx, prefix)
+}
@@ -0,0 +1,90 @@
+package xcache_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xcache"
+	"github.com/pubgo/x/xcache/xcache_abc"
+	"github.com/pubgo/x/xcache/xcache_memory"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func TestTyped(t *testing.T) {
+	var ctx = context.Background()
+	var c = xcache_memory.NewCache(time.Minute)
+	var users = xcache.NewTyped[user](c)
+
+	t.Run("get set", func(t *testing.T) {
+		if _, err := users.Get(ctx, "u1"); !errors.Is(err, xcache_abc.ErrCacheMiss) {
+			t.Fatal(err)
+		}
+
+		if err := users.Set(ctx, "u1", user{Name: "a", Age: 1}, xcache_abc.DEFAULT); err != nil {
+			t.Fatal(err)
+		}
+
+		if u, err := users.Get(ctx, "u1"); err != nil || u.Name != "a" || u.Age != 1 {
+			t.Fatal(u, err)
+		}
+
+		if ttl, err := users.TTL(ctx, "u1"); err != nil || ttl <= 0 || ttl > time.Minute {
+			t.Fatal(ttl, err)
+		}
+	})
+
+	t.Run("multi", func(t *testing.T) {
+		var vals = map[string]user{"u2": {Name: "b"}, "u3": {Name: "c"}}
+		if err := users.SetMulti(ctx, vals, xcache_abc.FOREVER); err != nil {
+			t.Fatal(err)
+		}
+
+		if ttl, err := users.TTL(ctx, "u2"); err != nil || ttl != xcache_abc.FOREVER {
+			t.Fatal(ttl, err)
+		}
+
+		res, err := users.GetMulti(ctx, []string{"u2", "u3", "u4"})
+		if err != nil || !reflect.DeepEqual(res, vals) {
+			t.Fatal(res, err)
+		}
+
+		if err := users.DeleteMulti(ctx, []string{"u2", "u3", "u4"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if res, err := users.GetMulti(ctx, []string{"u2", "u3"}); err != nil || len(res) != 0 {
+			t.Fatal(res, err)
+		}
+	})
+
+	t.Run("counter", func(t *testing.T) {
+		if n, err := c.Increment(ctx, "n", 5); err != nil || n != 5 {
+			t.Fatal(n, err)
+		}
+
+		if n, err := c.Decrement(ctx, "n", 2); err != nil || n != 3 {
+			t.Fatal(n, err)
+		}
+
+		if n, err := xcache.NewTyped[int](c).Get(ctx, "n"); err != nil || n != 3 {
+			t.Fatal(n, err)
+		}
+
+		if _, err := c.Increment(ctx, "u1", 1); err == nil {
+			t.Fatal("expect error")
+		}
+	})
+
+	t.Run("unmarshal error", func(t *testing.T) {
+		if _, err := xcache.NewTyped[int](c).Get(ctx, "u1"); err == nil {
+			t.Fatal("expect error")
+		}
+	})
+}
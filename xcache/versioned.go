@@ -0,0 +1,293 @@
+package xcache
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+// versionedMagic 标记带有版本号的数据
+const versionedMagic = "\x00xcv1"
+
+// VersionedOption Versioned 配置
+type VersionedOption func(v *versioned)
+
+// WithSeparator key 的命名空间分隔符, 默认 ":"
+func WithSeparator(sep string) VersionedOption {
+	return func(v *versioned) {
+		v.sep = sep
+	}
+}
+
+// Tags 返回 c 对应的 xcache_abc.TagCache, c 没有实现时使用 NewVersioned
+func Tags(c xcache_abc.Cache) xcache_abc.TagCache {
+	if tc, ok := c.(xcache_abc.TagCache); ok {
+		return tc
+	}
+	return NewVersioned(c)
+}
+
+// NewVersioned
+// 为不能遍历 key 的缓存(例如 memcached)提供 tag 和前缀失效, 写入时记录 tag 和命名空间的版本号, 读取时版本号变化的数据视为不存在
+// 命名空间为 key 中以分隔符结尾的前缀, 例如 "user:42:profile" 的命名空间为 "user:" 和 "user:42:"
+// DeletePrefix 的 prefix 必须以分隔符结尾, 否则返回 xcache_abc.ErrNotSupport
+// Increment 和 Decrement 写入的计数器不受失效影响
+// 版本号为写入时的纳秒时间戳, 以 FOREVER 保存在 c 中, 失效依赖 c 不丢失版本号:
+// c 淘汰版本号之后, 重新写入时使用新的时间戳, 旧的数据不会重新生效, 但是在时钟回拨时可能重复
+func NewVersioned(c xcache_abc.Cache, opts ...VersionedOption) xcache_abc.TagCache {
+	var v = &versioned{Cache: c, sep: ":"}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+type versioned struct {
+	xcache_abc.Cache
+	sep string
+}
+
+type version struct {
+	name string
+	ver  int64
+}
+
+func versionKey(name string) string {
+	return xcache_abc.KeyPrefix + ":ver:" + name
+}
+
+// names key 关联的 tag 和命名空间
+func (v *versioned) names(key string, tags []string) []string {
+	var names = make([]string, 0, len(tags)+strings.Count(key, v.sep))
+	for _, tag := range tags {
+		names = append(names, "t:"+tag)
+	}
+
+	if v.sep == "" {
+		return names
+	}
+
+	for i := 0; ; {
+		n := strings.Index(key[i:], v.sep)
+		if n < 0 {
+			return names
+		}
+		i += n + len(v.sep)
+		names = append(names, "p:"+key[:i])
+	}
+}
+
+// lastVersion 本进程最近生成的版本号
+var lastVersion int64
+
+// newVersion 新的版本号, 本进程内严格递增
+func newVersion() int64 {
+	for {
+		var last = atomic.LoadInt64(&lastVersion)
+		var ver = time.Now().UnixNano()
+		if ver <= last {
+			ver = last + 1
+		}
+
+		if atomic.CompareAndSwapInt64(&lastVersion, last, ver) {
+			return ver
+		}
+	}
+}
+
+// setVersion 保存新的版本号, 版本号不过期
+func (v *versioned) setVersion(ctx context.Context, name string) (int64, error) {
+	var ver = newVersion()
+	return ver, v.Cache.Set(ctx, versionKey(name), []byte(strconv.FormatInt(ver, 10)), xcache_abc.FOREVER)
+}
+
+// versions
+// 当前的版本号, 不存在时为 0
+// seed 为 true 时为不存在的版本号写入新的版本号, 写入数据时使用
+func (v *versioned) versions(ctx context.Context, names []string, seed bool) (map[string]int64, error) {
+	var vers = make(map[string]int64, len(names))
+	if len(names) == 0 {
+		return vers, nil
+	}
+
+	var keys = make([]string, len(names))
+	for i, name := range names {
+		keys[i] = versionKey(name)
+	}
+
+	items, err := v.Cache.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		if b, ok := items[keys[i]]; ok {
+			vers[name], _ = strconv.ParseInt(string(b), 10, 64)
+			continue
+		}
+
+		if _, ok := vers[name]; !ok && seed {
+			if vers[name], err = v.setVersion(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return vers, nil
+}
+
+func (v *versioned) encode(vers []version, value []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	var b = []byte(versionedMagic)
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(vers)))]...)
+	for _, ver := range vers {
+		b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(ver.name)))]...)
+		b = append(b, ver.name...)
+		b = append(b, buf[:binary.PutVarint(buf[:], ver.ver)]...)
+	}
+	return append(b, value...)
+}
+
+func (v *versioned) decode(b []byte) ([]version, []byte, bool) {
+	if !strings.HasPrefix(string(b), versionedMagic) {
+		return nil, b, false
+	}
+	b = b[len(versionedMagic):]
+
+	n, i := binary.Uvarint(b)
+	if i <= 0 || n > uint64(len(b)) {
+		return nil, nil, false
+	}
+	b = b[i:]
+
+	var vers = make([]version, 0, n)
+	for j := uint64(0); j < n; j++ {
+		l, i := binary.Uvarint(b)
+		if i <= 0 || uint64(len(b)-i) < l {
+			return nil, nil, false
+		}
+		var name = string(b[i : i+int(l)])
+		b = b[i+int(l):]
+
+		ver, i := binary.Varint(b)
+		if i <= 0 {
+			return nil, nil, false
+		}
+		b = b[i:]
+
+		vers = append(vers, version{name: name, ver: ver})
+	}
+	return vers, b, true
+}
+
+func (v *versioned) Get(ctx context.Context, key string) ([]byte, error) {
+	items, err := v.GetMulti(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+
+	val, ok := items[key]
+	if !ok {
+		return nil, xcache_abc.ErrCacheMiss
+	}
+	return val, nil
+}
+
+func (v *versioned) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	items, err := v.Cache.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries = make(map[string][]version, len(items))
+	var names []string
+	for key, b := range items {
+		vers, val, ok := v.decode(b)
+		if !ok {
+			// 计数器等没有版本号的数据
+			continue
+		}
+
+		entries[key] = vers
+		items[key] = val
+		for _, ver := range vers {
+			names = append(names, ver.name)
+		}
+	}
+
+	cur, err := v.versions(ctx, names, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, vers := range entries {
+		for _, ver := range vers {
+			if cur[ver.name] != ver.ver {
+				delete(items, key)
+				break
+			}
+		}
+	}
+	return items, nil
+}
+
+func (v *versioned) Set(ctx context.Context, key string, value []byte, expire time.Duration) error {
+	return v.SetWithTags(ctx, key, value, expire)
+}
+
+func (v *versioned) SetMulti(ctx context.Context, items map[string][]byte, expire time.Duration) error {
+	var names []string
+	for key := range items {
+		names = append(names, v.names(key, nil)...)
+	}
+
+	cur, err := v.versions(ctx, names, true)
+	if err != nil {
+		return err
+	}
+
+	var values = make(map[string][]byte, len(items))
+	for key, val := range items {
+		values[key] = v.encode(v.current(cur, v.names(key, nil)), val)
+	}
+	return v.Cache.SetMulti(ctx, values, expire)
+}
+
+func (v *versioned) SetWithTags(ctx context.Context, key string, value []byte, expire time.Duration, tags ...string) error {
+	var names = v.names(key, tags)
+	cur, err := v.versions(ctx, names, true)
+	if err != nil {
+		return err
+	}
+	return v.Cache.Set(ctx, key, v.encode(v.current(cur, names), value), expire)
+}
+
+func (v *versioned) current(cur map[string]int64, names []string) []version {
+	var vers = make([]version, len(names))
+	for i, name := range names {
+		vers[i] = version{name: name, ver: cur[name]}
+	}
+	return vers
+}
+
+func (v *versioned) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		if _, err := v.setVersion(ctx, "t:"+tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *versioned) DeletePrefix(ctx context.Context, prefix string) error {
+	if v.sep == "" || !strings.HasSuffix(prefix, v.sep) {
+		return xcache_abc.ErrNotSupport
+	}
+
+	_, err := v.setVersion(ctx, "p:"+prefix)
+	return err
+}
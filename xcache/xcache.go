@@ -1,18 +1,23 @@
 package xcache
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha1"
 	"encoding/gob"
-	"github.com/pubgo/x/xmiddleware/cache/persistence"
-	"io"
+	"encoding/hex"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pubgo/x/xmiddleware/cache/persistence"
 )
 
 const (
@@ -23,10 +28,14 @@ var (
 	PageCachePrefix = "gincontrib.page.cache"
 )
 
+// DefaultMaxBodySize responses larger than this are passed through without being cached
+const DefaultMaxBodySize = 1 << 20
+
 type responseCache struct {
 	Status int
 	Header http.Header
 	Data   []byte
+	Time   time.Time
 }
 
 // RegisterResponseCacheGob registers the responseCache type with the encoding/gob package
@@ -34,17 +43,6 @@ func RegisterResponseCacheGob() {
 	gob.Register(responseCache{})
 }
 
-type cachedWriter struct {
-	gin.ResponseWriter
-	status  int
-	written bool
-	store   persistence.CacheStore
-	expire  time.Duration
-	key     string
-}
-
-var _ gin.ResponseWriter = &cachedWriter{}
-
 // CreateKey creates a package specific key for a given string
 func CreateKey(u string) string {
 	return urlEscape(PageCachePrefix, u)
@@ -53,9 +51,8 @@ func CreateKey(u string) string {
 func urlEscape(prefix string, u string) string {
 	key := url.QueryEscape(u)
 	if len(key) > 200 {
-		h := sha1.New()
-		io.WriteString(h, u)
-		key = string(h.Sum(nil))
+		h := sha1.Sum([]byte(u))
+		key = hex.EncodeToString(h[:])
 	}
 	var buffer bytes.Buffer
 	buffer.WriteString(prefix)
@@ -64,62 +61,476 @@ func urlEscape(prefix string, u string) string {
 	return buffer.String()
 }
 
-func newCachedWriter(store persistence.CacheStore, expire time.Duration, writer gin.ResponseWriter, key string) *cachedWriter {
-	return &cachedWriter{writer, 0, false, store, expire, key}
+func pageVersionKey(path string) string {
+	return urlEscape(PageCachePrefix+":version", path)
+}
+
+// PurgePage drops every cached variant of the given paths, whatever their query or Vary headers.
+// Each path carries a version that is part of the page keys, purging replaces the version.
+func PurgePage(store persistence.CacheStore, paths ...string) error {
+	for _, path := range paths {
+		if err := store.Set(pageVersionKey(path), time.Now().UnixNano(), persistence.FOREVER); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PageOption configures a PageCache
+type PageOption func(opts *pageOptions)
+
+type pageOptions struct {
+	vary          []string
+	withoutQuery  bool
+	withoutHeader bool
+	coalesce      bool
+	maxBodySize   int
 }
 
-func (w *cachedWriter) WriteHeader(code int) {
-	w.status = code
-	w.written = true
-	w.ResponseWriter.WriteHeader(code)
+// WithVary request headers that are part of the cache key.
+// Responses whose Vary header names other headers are not cached.
+func WithVary(headers ...string) PageOption {
+	return func(opts *pageOptions) {
+		for _, h := range headers {
+			opts.vary = append(opts.vary, http.CanonicalHeaderKey(strings.TrimSpace(h)))
+		}
+	}
 }
 
-func (w *cachedWriter) Status() int {
-	return w.ResponseWriter.Status()
+// WithoutQuery ignores the query string when building the cache key
+func WithoutQuery() PageOption {
+	return func(opts *pageOptions) {
+		opts.withoutQuery = true
+	}
 }
 
-func (w *cachedWriter) Written() bool {
-	return w.ResponseWriter.Written()
+// WithoutHeader caches only the status and body, no ETag is served
+func WithoutHeader() PageOption {
+	return func(opts *pageOptions) {
+		opts.withoutHeader = true
+	}
 }
 
-func (w *cachedWriter) Write(data []byte) (int, error) {
-	ret, err := w.ResponseWriter.Write(data)
-	if err == nil {
-		store := w.store
+// WithoutCoalescing runs the handler for every concurrent miss of the same key
+func WithoutCoalescing() PageOption {
+	return func(opts *pageOptions) {
+		opts.coalesce = false
+	}
+}
+
+// WithMaxBodySize responses larger than n bytes are not cached, n <= 0 means no limit
+func WithMaxBodySize(n int) PageOption {
+	return func(opts *pageOptions) {
+		opts.maxBodySize = n
+	}
+}
+
+// PageCache caches GET responses in a persistence.CacheStore.
+//
+// The response is streamed to the client and buffered once, it is stored when the handler returns.
+// Cache-Control no-store, no-cache and private responses are not stored, s-maxage and max-age
+// override the default expiration. Cached responses get an ETag and If-None-Match is answered
+// with 304 Not Modified. Concurrent misses of the same key run the handler once, the waiting
+// requests are released as soon as the response turns out to be uncacheable or streamed
+// (event-stream, Flush, Hijack or larger than the max body size) and run the handler themselves.
+type PageCache struct {
+	store   persistence.CacheStore
+	expire  time.Duration
+	opts    pageOptions
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight a running handler whose response the concurrent misses of the same key wait for
+type flight struct {
+	done  chan struct{}
+	once  sync.Once
+	cache *responseCache
+}
+
+// NewPageCache creates a PageCache, expire is used when the response has no max-age
+func NewPageCache(store persistence.CacheStore, expire time.Duration, opts ...PageOption) *PageCache {
+	var p = &PageCache{store: store, expire: expire, opts: pageOptions{coalesce: true, maxBodySize: DefaultMaxBodySize}}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	sort.Strings(p.opts.vary)
+	return p
+}
+
+// Purge drops every cached variant of the given paths
+func (p *PageCache) Purge(paths ...string) error {
+	return PurgePage(p.store, paths...)
+}
+
+// Handle caches the response of handle
+func (p *PageCache) Handle(handle gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p.serve(c, func() { handle(c) })
+	}
+}
+
+// Middleware caches the response of the rest of the handler chain
+func (p *PageCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p.serve(c, c.Next)
+	}
+}
+
+func (p *PageCache) key(r *http.Request) string {
+	var version int64
+	if err := p.store.Get(pageVersionKey(r.URL.Path), &version); err != nil && err != persistence.ErrCacheMiss {
+		log.Println(err.Error())
+	}
+
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+	if !p.opts.withoutQuery && r.URL.RawQuery != "" {
+		b.WriteString("?")
+		b.WriteString(r.URL.RawQuery)
+	}
+	for _, h := range p.opts.vary {
+		b.WriteString("\n")
+		b.WriteString(h)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(r.Header.Values(h), ", "))
+	}
+	b.WriteString("\n#")
+	b.WriteString(strconv.FormatInt(version, 10))
+	return CreateKey(b.String())
+}
+
+func (p *PageCache) serve(c *gin.Context, next func()) {
+	var r = c.Request
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		next()
+		return
+	}
+
+	var reqCC = parseCacheControl(r.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		next()
+		return
+	}
+
+	var key = p.key(r)
+	var _, noCache = reqCC["no-cache"]
+	if !noCache && r.Header.Get("Cache-Control") == "" && r.Header.Get("Pragma") == "no-cache" {
+		noCache = true
+	}
+
+	if !noCache {
 		var cache responseCache
-		if err := store.Get(w.key, &cache); err == nil {
-			data = append(cache.Data, data...)
+		if err := p.store.Get(key, &cache); err == nil {
+			p.write(c, &cache)
+			return
+		} else if err != persistence.ErrCacheMiss {
+			log.Println(err.Error())
+		}
+	}
+
+	// HEAD requests are served from the cache but never fill it
+	if r.Method == http.MethodHead {
+		next()
+		return
+	}
+
+	if !p.opts.coalesce {
+		p.fill(c, key, next, nil)
+		return
+	}
+
+	p.mu.Lock()
+	if f, ok := p.flights[key]; ok {
+		p.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-r.Context().Done():
+			c.Abort()
+			return
+		}
+
+		if f.cache != nil {
+			p.write(c, f.cache)
+			return
+		}
+
+		// the leader's response is not cacheable or is streamed
+		next()
+		return
+	}
+
+	if p.flights == nil {
+		p.flights = make(map[string]*flight)
+	}
+	var f = &flight{done: make(chan struct{})}
+	p.flights[key] = f
+	p.mu.Unlock()
+
+	var release = func(cache *responseCache) {
+		f.once.Do(func() {
+			p.mu.Lock()
+			delete(p.flights, key)
+			p.mu.Unlock()
+
+			f.cache = cache
+			close(f.done)
+		})
+	}
+	defer release(nil)
+
+	release(p.fill(c, key, next, func() { release(nil) }))
+}
+
+// fill runs next and stores its response, it returns nil when the response is not cacheable.
+// release is called once the response is known to be not cacheable or streamed
+func (p *PageCache) fill(c *gin.Context, key string, next func(), release func()) *responseCache {
+	var w = &cachedWriter{ResponseWriter: c.Writer, maxBodySize: p.opts.maxBodySize, release: release}
+	w.cacheable = func(status int, header http.Header) bool {
+		_, ok := p.cacheable(c.Request, status, header)
+		return ok
+	}
+	c.Writer = w
+	defer func() { c.Writer = w.ResponseWriter }()
+
+	next()
+
+	if c.IsAborted() || w.skip {
+		return nil
+	}
+
+	var header = w.header
+	if header == nil {
+		header = w.Header().Clone()
+	}
+
+	expire, ok := p.cacheable(c.Request, w.Status(), header)
+	if !ok {
+		return nil
+	}
+
+	var cache = &responseCache{Status: w.Status(), Data: w.body.Bytes(), Time: time.Now()}
+	if !p.opts.withoutHeader {
+		if header.Get("ETag") == "" {
+			h := sha1.Sum(cache.Data)
+			header.Set("ETag", `"`+hex.EncodeToString(h[:])+`"`)
+		}
+		cache.Header = header
+	}
+
+	if err := p.store.Set(key, *cache, expire); err != nil {
+		log.Println(err.Error())
+	}
+	return cache
+}
+
+// cacheable reports whether a response may be stored and for how long
+func (p *PageCache) cacheable(r *http.Request, status int, header http.Header) (time.Duration, bool) {
+	if status < 200 || status >= 300 || status == http.StatusPartialContent {
+		return 0, false
+	}
+
+	// cookies are never shared between clients
+	if len(header.Values("Set-Cookie")) > 0 {
+		return 0, false
+	}
+
+	var cc = parseCacheControl(header)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[d]; ok {
+			return 0, false
+		}
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		var _, public = cc["public"]
+		var _, sMaxAge = cc["s-maxage"]
+		if !public && !sMaxAge {
+			return 0, false
+		}
+	}
+
+	if !p.varyCovered(header) {
+		return 0, false
+	}
+
+	for _, d := range []string{"s-maxage", "max-age"} {
+		v, ok := cc[d]
+		if !ok {
+			continue
+		}
+
+		age, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || age <= 0 {
+			return 0, false
 		}
+		return time.Duration(age) * time.Second, true
+	}
+	return p.expire, true
+}
+
+// varyCovered reports whether every header in the response Vary is part of the cache key
+func (p *PageCache) varyCovered(header http.Header) bool {
+	for _, v := range header.Values("Vary") {
+		for _, h := range strings.Split(v, ",") {
+			h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+			if h == "" {
+				continue
+			}
+
+			if h == "*" {
+				return false
+			}
 
-		//cache responses with a status code < 300
-		if w.Status() < 300 {
-			val := responseCache{
-				w.Status(),
-				w.Header(),
-				data,
+			i := sort.SearchStrings(p.opts.vary, h)
+			if i == len(p.opts.vary) || p.opts.vary[i] != h {
+				return false
 			}
-			err = store.Set(w.key, val, w.expire)
-			if err != nil {
-				// need logger
+		}
+	}
+	return true
+}
+
+// notModifiedHeaders headers sent with 304 Not Modified, see RFC 7232 4.1
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Vary"}
+
+// write serves cache and stops the rest of the handler chain
+func (p *PageCache) write(c *gin.Context, cache *responseCache) {
+	c.Abort()
+
+	var header = c.Writer.Header()
+	if !cache.Time.IsZero() {
+		header.Set("Age", strconv.FormatInt(int64(time.Since(cache.Time)/time.Second), 10))
+	}
+
+	if etag := cache.Header.Get("ETag"); etag != "" && etagMatch(c.GetHeader("If-None-Match"), etag) {
+		for _, k := range notModifiedHeaders {
+			if vals := cache.Header.Values(k); len(vals) > 0 {
+				header[http.CanonicalHeaderKey(k)] = append([]string(nil), vals...)
 			}
 		}
+		c.Writer.WriteHeader(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	for k, vals := range cache.Header {
+		header[k] = append([]string(nil), vals...)
+	}
+	c.Writer.WriteHeader(cache.Status)
+	c.Writer.Write(cache.Data)
+}
+
+// etagMatch weak comparison of If-None-Match, see RFC 7232 3.2
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
 	}
-	return ret, err
+	return false
 }
 
-func (w *cachedWriter) WriteString(data string) (n int, err error) {
-	ret, err := w.ResponseWriter.WriteString(data)
-	//cache responses with a status code < 300
-	if err == nil && w.Status() < 300 {
-		store := w.store
-		val := responseCache{
-			w.Status(),
-			w.Header(),
-			[]byte(data),
+// parseCacheControl directives of the Cache-Control header, names are lower case
+func parseCacheControl(header http.Header) map[string]string {
+	var cc = make(map[string]string)
+	for _, v := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+
+			var name, val = d, ""
+			if i := strings.IndexByte(d, '='); i >= 0 {
+				name, val = d[:i], strings.Trim(strings.TrimSpace(d[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = val
 		}
-		store.Set(w.key, val, w.expire)
 	}
-	return ret, err
+	return cc
+}
+
+// cachedWriter passes the response through and keeps a copy of the body
+type cachedWriter struct {
+	gin.ResponseWriter
+	header      http.Header
+	body        bytes.Buffer
+	maxBodySize int
+	skip        bool
+
+	// cacheable reports whether the response may be stored once the headers are final
+	cacheable func(status int, header http.Header) bool
+	release   func()
+}
+
+var _ gin.ResponseWriter = &cachedWriter{}
+
+// capture the headers are final once the first byte is sent
+func (w *cachedWriter) capture(data []byte) {
+	if w.header == nil {
+		w.header = w.Header().Clone()
+		if strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream") {
+			w.skip = true
+		}
+
+		if w.skip || !w.cacheable(w.Status(), w.header) {
+			w.stream()
+		}
+	}
+
+	if w.skip {
+		return
+	}
+
+	if w.maxBodySize > 0 && w.body.Len()+len(data) > w.maxBodySize {
+		w.skip = true
+		w.body = bytes.Buffer{}
+		w.stream()
+		return
+	}
+	w.body.Write(data)
+}
+
+// stream releases the requests waiting for this response, they do not wait for a streamed response
+func (w *cachedWriter) stream() {
+	if w.release != nil {
+		w.release()
+	}
+}
+
+func (w *cachedWriter) WriteHeaderNow() {
+	w.capture(nil)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *cachedWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cachedWriter) WriteString(data string) (int, error) {
+	w.capture([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *cachedWriter) Flush() {
+	w.capture(nil)
+	w.stream()
+	w.ResponseWriter.Flush()
+}
+
+func (w *cachedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.skip = true
+	w.stream()
+	return w.ResponseWriter.Hijack()
 }
 
 // Cache Middleware
@@ -130,79 +541,19 @@ func Cache(store *persistence.CacheStore) gin.HandlerFunc {
 	}
 }
 
+// SiteCache caches the responses of every route after it
 func SiteCache(store persistence.CacheStore, expire time.Duration) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var cache responseCache
-		url := c.Request.URL
-		key := CreateKey(url.RequestURI())
-		if err := store.Get(key, &cache); err != nil {
-			c.Next()
-		} else {
-			c.Writer.WriteHeader(cache.Status)
-			for k, vals := range cache.Header {
-				for _, v := range vals {
-					c.Writer.Header().Set(k, v)
-				}
-			}
-			c.Writer.Write(cache.Data)
-		}
-	}
+	return NewPageCache(store, expire).Middleware()
 }
 
 // CachePage Decorator
 func CachePage(store persistence.CacheStore, expire time.Duration, handle gin.HandlerFunc) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var cache responseCache
-		url := c.Request.URL
-		key := CreateKey(url.RequestURI())
-		if err := store.Get(key, &cache); err != nil {
-			if err != persistence.ErrCacheMiss {
-				log.Println(err.Error())
-			}
-			// replace writer
-			writer := newCachedWriter(store, expire, c.Writer, key)
-			c.Writer = writer
-			handle(c)
-
-			// Drop caches of aborted contexts
-			if c.IsAborted() {
-				store.Delete(key)
-			}
-		} else {
-			c.Writer.WriteHeader(cache.Status)
-			for k, vals := range cache.Header {
-				for _, v := range vals {
-					c.Writer.Header().Set(k, v)
-				}
-			}
-			c.Writer.Write(cache.Data)
-		}
-	}
+	return NewPageCache(store, expire).Handle(handle)
 }
 
 // CachePageWithoutQuery add ability to ignore GET query parameters.
 func CachePageWithoutQuery(store persistence.CacheStore, expire time.Duration, handle gin.HandlerFunc) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var cache responseCache
-		key := CreateKey(c.Request.URL.Path)
-		if err := store.Get(key, &cache); err != nil {
-			if err != persistence.ErrCacheMiss {
-				log.Println(err.Error())
-			}
-			// replace writer
-			writer := newCachedWriter(store, expire, c.Writer, key)
-			c.Writer = writer
-			handle(c)
-		} else {
-			c.Writer.WriteHeader(cache.Status)
-			for k, vals := range cache.Header {
-				for _, v := range vals {
-					c.Writer.Header().Set(k, v)
-				}
-			}
-			c.Writer.Write(cache.Data)
-		}
-	}
+	return NewPageCache(store, expire, WithoutQuery()).Handle(handle)
 }
 
 // CachePageAtomic Decorator
@@ -217,26 +568,5 @@ func CachePageAtomic(store persistence.CacheStore, expire time.Duration, handle
 }
 
 func CachePageWithoutHeader(store persistence.CacheStore, expire time.Duration, handle gin.HandlerFunc) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var cache responseCache
-		url := c.Request.URL
-		key := CreateKey(url.RequestURI())
-		if err := store.Get(key, &cache); err != nil {
-			if err != persistence.ErrCacheMiss {
-				log.Println(err.Error())
-			}
-			// replace writer
-			writer := newCachedWriter(store, expire, c.Writer, key)
-			c.Writer = writer
-			handle(c)
-
-			// Drop caches of aborted contexts
-			if c.IsAborted() {
-				store.Delete(key)
-			}
-		} else {
-			c.Writer.WriteHeader(cache.Status)
-			c.Writer.Write(cache.Data)
-		}
-	}
+	return NewPageCache(store, expire, WithoutHeader()).Handle(handle)
 }
@@ -1,6 +1,7 @@
 package xcache_abc
 
 import (
+	"context"
 	"time"
 )
 
@@ -27,3 +28,55 @@ type ICache interface {
 	// whether the key was found.
 	Get(key string) (val interface{}, err error)
 }
+
+// Cache
+// 统一的缓存接口, value 为序列化之后的数据, 通过 xcache.Typed 处理序列化
+// expire 为 DEFAULT 时使用默认的过期时间, 为 FOREVER 时不过期
+// key 不存在时返回 ErrCacheMiss, 不支持的操作返回 ErrNotSupport
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, expire time.Duration) error
+
+	// Delete key 不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+
+	// GetMulti 批量获取, 返回的 map 中只包含存在的 key
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+	SetMulti(ctx context.Context, items map[string][]byte, expire time.Duration) error
+	DeleteMulti(ctx context.Context, keys []string) error
+
+	// Increment 以十进制整数的形式增加 key 的值, key 不存在时从 0 开始, 返回增加之后的值
+	Increment(ctx context.Context, key string, delta int64) (int64, error)
+	Decrement(ctx context.Context, key string, delta int64) (int64, error)
+
+	// TTL 剩余的过期时间, 不过期时返回 FOREVER
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	// Flush 删除所有数据
+	Flush(ctx context.Context) error
+}
+
+// Bus
+// 消息总线, 用于在多个实例之间广播缓存失效消息
+type Bus interface {
+	Publish(ctx context.Context, channel string, msg []byte) error
+
+	// Subscribe 订阅 channel, 返回的 cancel 用于取消订阅
+	Subscribe(ctx context.Context, channel string, handler func(msg []byte)) (cancel func(), err error)
+}
+
+// TagCache
+// 支持按照标签和前缀批量失效的缓存
+// 不能遍历 key 的缓存通过 xcache.NewVersioned 使用版本号实现
+type TagCache interface {
+	Cache
+
+	// SetWithTags 写入 key 并关联 tags
+	SetWithTags(ctx context.Context, key string, value []byte, expire time.Duration, tags ...string) error
+
+	// InvalidateTags 删除关联了任意一个 tag 的 key
+	InvalidateTags(ctx context.Context, tags ...string) error
+
+	// DeletePrefix 删除以 prefix 开头的 key
+	DeletePrefix(ctx context.Context, prefix string) error
+}
@@ -0,0 +1,146 @@
+package xcache_freecache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+var _ xcache_abc.Cache = (*freeCache)(nil)
+
+// NewCache
+// 基于 freecache 的 xcache_abc.Cache, size 为缓存的字节数
+// freecache 的过期时间精确到秒, 不足一秒时按照一秒处理
+func NewCache(size int, defaultExpiration time.Duration) xcache_abc.Cache {
+	return &freeCache{c: freecache.NewCache(size), defaultExpiration: defaultExpiration}
+}
+
+type freeCache struct {
+	// mu 保证 Increment 和 Decrement 的原子性
+	mu                sync.Mutex
+	c                 *freecache.Cache
+	defaultExpiration time.Duration
+}
+
+func (c *freeCache) expireSeconds(expire time.Duration) int {
+	switch expire {
+	case xcache_abc.DEFAULT:
+		expire = c.defaultExpiration
+	case xcache_abc.FOREVER:
+		return 0
+	}
+
+	if expire <= 0 {
+		return 0
+	}
+
+	if expire < time.Second {
+		return 1
+	}
+	return int(expire / time.Second)
+}
+
+func (c *freeCache) Get(_ context.Context, key string) ([]byte, error) {
+	val, err := c.c.Get([]byte(key))
+	if err == freecache.ErrNotFound {
+		return nil, xcache_abc.ErrCacheMiss
+	}
+	return val, err
+}
+
+func (c *freeCache) Set(_ context.Context, key string, value []byte, expire time.Duration) error {
+	return c.c.Set([]byte(key), value, c.expireSeconds(expire))
+}
+
+func (c *freeCache) Delete(_ context.Context, key string) error {
+	c.c.Del([]byte(key))
+	return nil
+}
+
+func (c *freeCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	var items = make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		val, err := c.Get(ctx, key)
+		if err == xcache_abc.ErrCacheMiss {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		items[key] = val
+	}
+	return items, nil
+}
+
+func (c *freeCache) SetMulti(ctx context.Context, items map[string][]byte, expire time.Duration) error {
+	for key, val := range items {
+		if err := c.Set(ctx, key, val, expire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *freeCache) DeleteMulti(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		c.c.Del([]byte(key))
+	}
+	return nil
+}
+
+func (c *freeCache) Increment(_ context.Context, key string, delta int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	var expireSeconds int
+	val, err := c.c.Get([]byte(key))
+	switch err {
+	case nil:
+		if n, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+			return 0, err
+		}
+
+		ttl, err := c.c.TTL([]byte(key))
+		if err != nil && err != freecache.ErrNotFound {
+			return 0, err
+		}
+		expireSeconds = int(ttl)
+	case freecache.ErrNotFound:
+	default:
+		return 0, err
+	}
+
+	n += delta
+	return n, c.c.Set([]byte(key), []byte(strconv.FormatInt(n, 10)), expireSeconds)
+}
+
+func (c *freeCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.Increment(ctx, key, -delta)
+}
+
+func (c *freeCache) TTL(_ context.Context, key string) (time.Duration, error) {
+	ttl, err := c.c.TTL([]byte(key))
+	if err == freecache.ErrNotFound {
+		return 0, xcache_abc.ErrCacheMiss
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if ttl == 0 {
+		return xcache_abc.FOREVER, nil
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
+
+func (c *freeCache) Flush(_ context.Context) error {
+	c.c.Clear()
+	return nil
+}
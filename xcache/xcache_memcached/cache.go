@@ -0,0 +1,159 @@
+package xcache_memcached
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/pubgo/x/xcache"
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+var _ xcache_abc.Cache = (*memcachedCache)(nil)
+
+// maxRelativeExpiration memcached 相对过期时间的最大值
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// NewCache
+// 基于 gomemcache 的 xcache_abc.Cache
+// memcached 不支持负数, Decrement 的结果最小为 0, 不支持 TTL
+// memcached 不能遍历 key, 通过 xcache.NewVersioned 实现 xcache_abc.TagCache
+func NewCache(client *memcache.Client, defaultExpiration time.Duration) xcache_abc.Cache {
+	return xcache.NewVersioned(&memcachedCache{client: client, defaultExpiration: defaultExpiration})
+}
+
+type memcachedCache struct {
+	client            *memcache.Client
+	defaultExpiration time.Duration
+}
+
+func (c *memcachedCache) expiration(expire time.Duration) int32 {
+	switch expire {
+	case xcache_abc.DEFAULT:
+		expire = c.defaultExpiration
+	case xcache_abc.FOREVER:
+		return 0
+	}
+
+	if expire <= 0 {
+		return 0
+	}
+
+	if expire < time.Second {
+		return 1
+	}
+
+	// memcached 把超过 30 天的过期时间当作 unix 时间戳
+	if expire > maxRelativeExpiration {
+		return int32(time.Now().Add(expire).Unix())
+	}
+	return int32(expire / time.Second)
+}
+
+func (c *memcachedCache) Get(_ context.Context, key string) ([]byte, error) {
+	item, err := c.client.Get(key)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return item.Value, nil
+}
+
+func (c *memcachedCache) Set(_ context.Context, key string, value []byte, expire time.Duration) error {
+	return c.client.Set(&memcache.Item{Key: key, Value: value, Expiration: c.expiration(expire)})
+}
+
+func (c *memcachedCache) Delete(_ context.Context, key string) error {
+	if err := c.client.Delete(key); err != nil && err != memcache.ErrCacheMiss {
+		return err
+	}
+	return nil
+}
+
+func (c *memcachedCache) GetMulti(_ context.Context, keys []string) (map[string][]byte, error) {
+	items, err := c.client.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var values = make(map[string][]byte, len(items))
+	for key, item := range items {
+		values[key] = item.Value
+	}
+	return values, nil
+}
+
+func (c *memcachedCache) SetMulti(ctx context.Context, items map[string][]byte, expire time.Duration) error {
+	for key, val := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := c.Set(ctx, key, val, expire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *memcachedCache) DeleteMulti(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := c.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *memcachedCache) Increment(_ context.Context, key string, delta int64) (int64, error) {
+	for {
+		var n uint64
+		var err error
+		if delta >= 0 {
+			n, err = c.client.Increment(key, uint64(delta))
+		} else {
+			n, err = c.client.Decrement(key, uint64(-delta))
+		}
+
+		if err != memcache.ErrCacheMiss {
+			return int64(n), err
+		}
+
+		// key 不存在时从 0 开始, 并发创建时重新增加
+		if delta < 0 {
+			delta = 0
+		}
+
+		err = c.client.Add(&memcache.Item{Key: key, Value: []byte(strconv.FormatInt(delta, 10))})
+		if err != memcache.ErrNotStored {
+			return delta, err
+		}
+	}
+}
+
+func (c *memcachedCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.Increment(ctx, key, -delta)
+}
+
+func (c *memcachedCache) TTL(_ context.Context, _ string) (time.Duration, error) {
+	return 0, xcache_abc.ErrNotSupport
+}
+
+func (c *memcachedCache) Flush(_ context.Context) error {
+	return c.client.DeleteAll()
+}
+
+func convertErr(err error) error {
+	switch err {
+	case memcache.ErrCacheMiss:
+		return xcache_abc.ErrCacheMiss
+	case memcache.ErrNotStored:
+		return xcache_abc.ErrNotStored
+	default:
+		return err
+	}
+}
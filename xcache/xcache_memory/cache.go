@@ -0,0 +1,195 @@
+package xcache_memory
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+var _ xcache_abc.TagCache = (*memoryCache)(nil)
+
+// NewCache 基于 go-cache 的 xcache_abc.Cache, 同时实现了 xcache_abc.TagCache
+func NewCache(defaultExpiration time.Duration) xcache_abc.Cache {
+	var c = &memoryCache{
+		c:       cache.New(defaultExpiration, time.Minute),
+		tags:    make(map[string]map[string]struct{}),
+		keyTags: make(map[string][]string),
+	}
+	c.c.OnEvicted(func(key string, _ interface{}) { c.untag(key) })
+	return c
+}
+
+type memoryCache struct {
+	// mu 保证 Increment 和 Decrement 的原子性
+	mu sync.Mutex
+	c  *cache.Cache
+
+	// tagMu 保护 tags 和 keyTags, 不能在持有 tagMu 时调用 c 的删除操作, OnEvicted 会重新获取 tagMu
+	tagMu   sync.Mutex
+	tags    map[string]map[string]struct{}
+	keyTags map[string][]string
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	val, ok := c.c.Get(key)
+	if !ok {
+		return nil, xcache_abc.ErrCacheMiss
+	}
+	return clone(val.([]byte)), nil
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte, expire time.Duration) error {
+	return c.SetWithTags(ctx, key, value, expire)
+}
+
+func (c *memoryCache) SetWithTags(_ context.Context, key string, value []byte, expire time.Duration, tags ...string) error {
+	c.untag(key)
+	c.tag(key, tags)
+	c.c.Set(key, clone(value), expire)
+	return nil
+}
+
+func (c *memoryCache) InvalidateTags(_ context.Context, tags ...string) error {
+	var keys []string
+	c.tagMu.Lock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			keys = append(keys, key)
+		}
+	}
+	c.tagMu.Unlock()
+
+	for _, key := range keys {
+		c.c.Delete(key)
+	}
+	return nil
+}
+
+func (c *memoryCache) DeletePrefix(_ context.Context, prefix string) error {
+	for key := range c.c.Items() {
+		if strings.HasPrefix(key, prefix) {
+			c.c.Delete(key)
+		}
+	}
+	return nil
+}
+
+func (c *memoryCache) tag(key string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+
+	c.keyTags[key] = tags
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+}
+
+func (c *memoryCache) untag(key string) {
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+
+	for _, tag := range c.keyTags[key] {
+		delete(c.tags[tag], key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	delete(c.keyTags, key)
+}
+
+func (c *memoryCache) Delete(_ context.Context, key string) error {
+	c.c.Delete(key)
+	return nil
+}
+
+func (c *memoryCache) GetMulti(_ context.Context, keys []string) (map[string][]byte, error) {
+	var items = make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if val, ok := c.c.Get(key); ok {
+			items[key] = clone(val.([]byte))
+		}
+	}
+	return items, nil
+}
+
+func (c *memoryCache) SetMulti(ctx context.Context, items map[string][]byte, expire time.Duration) error {
+	for key, val := range items {
+		_ = c.SetWithTags(ctx, key, val, expire)
+	}
+	return nil
+}
+
+func (c *memoryCache) DeleteMulti(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		c.c.Delete(key)
+	}
+	return nil
+}
+
+func (c *memoryCache) Increment(_ context.Context, key string, delta int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	var expire = NoExpiration
+	if val, exp, ok := c.c.GetWithExpiration(key); ok {
+		var err error
+		if n, err = strconv.ParseInt(string(val.([]byte)), 10, 64); err != nil {
+			return 0, err
+		}
+
+		if !exp.IsZero() {
+			expire = time.Until(exp)
+		}
+	}
+
+	n += delta
+	c.untag(key)
+	c.c.Set(key, []byte(strconv.FormatInt(n, 10)), expire)
+	return n, nil
+}
+
+func (c *memoryCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.Increment(ctx, key, -delta)
+}
+
+func (c *memoryCache) TTL(_ context.Context, key string) (time.Duration, error) {
+	_, exp, ok := c.c.GetWithExpiration(key)
+	if !ok {
+		return 0, xcache_abc.ErrCacheMiss
+	}
+
+	if exp.IsZero() {
+		return xcache_abc.FOREVER, nil
+	}
+	return time.Until(exp), nil
+}
+
+func (c *memoryCache) Flush(_ context.Context) error {
+	c.c.Flush()
+
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+	c.tags = make(map[string]map[string]struct{})
+	c.keyTags = make(map[string][]string)
+	return nil
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(b)), b...)
+}
@@ -0,0 +1,46 @@
+package xcache_redis
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+var _ xcache_abc.Bus = (*redisBus)(nil)
+
+// NewBus 基于 redis pub/sub 的 xcache_abc.Bus
+func NewBus(client redis.UniversalClient) xcache_abc.Bus {
+	return &redisBus{client: client}
+}
+
+type redisBus struct {
+	client redis.UniversalClient
+}
+
+func (b *redisBus) Publish(ctx context.Context, channel string, msg []byte) error {
+	return b.client.ProcessContext(ctx, redis.NewIntCmd("publish", channel, msg))
+}
+
+func (b *redisBus) Subscribe(_ context.Context, channel string, handler func(msg []byte)) (func(), error) {
+	var ps = b.client.Subscribe(channel)
+
+	// 等待订阅成功
+	if _, err := ps.Receive(); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range ps.Channel() {
+			handler([]byte(msg.Payload))
+		}
+	}()
+
+	return func() {
+		_ = ps.Close()
+		<-done
+	}, nil
+}
@@ -4,15 +4,24 @@ import (
 	"context"
 	"errors"
 	"github.com/pubgo/xerror"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v7"
+	"github.com/pubgo/x/xcache/internal/lrucache"
+	"github.com/pubgo/x/xcache/xcache_abc"
+	"github.com/pubgo/x/xcache/internal/singleflight"
 )
 
-var ErrCacheMiss = errors.New("cache: key is missing")
+var ErrCacheMiss = xcache_abc.ErrCacheMiss
 var errRedisLocalCacheNil = errors.New("cache: both Redis and LocalCache are nil")
 
+// ErrNotFound is returned by Item.Func to report that the object does not exist.
+// When Item.NegativeExpiration is set, Once caches the result and returns
+// ErrNotFound without calling Item.Func until it expires.
+var ErrNotFound = errors.New("cache: object not found")
+
 type Item struct {
 	Ctx context.Context
 
@@ -25,6 +34,20 @@ type Item struct {
 	// Expiration is the cache expiration time.
 	// Default expiration is 1 hour.
 	Expiration time.Duration
+
+	// SoftExpiration is the time after which Once considers the object stale.
+	// Stale objects are returned immediately while a single background
+	// refresh runs, until Expiration removes them from the cache.
+	SoftExpiration time.Duration
+
+	// Beta enables probabilistic early refresh (XFetch). The larger the
+	// value, the earlier Once refreshes the object before it expires,
+	// weighted by how long Item.Func takes. 1 is a reasonable default.
+	Beta float64
+
+	// NegativeExpiration is the cache expiration time of ErrNotFound
+	// returned by Item.Func. Zero disables negative caching.
+	NegativeExpiration time.Duration
 }
 
 func (item *Item) Context() context.Context {
@@ -44,6 +67,11 @@ func (item *Item) object() (interface{}, error) {
 	return nil, nil
 }
 
+// swr reports whether the item is stored with refresh metadata.
+func (item *Item) swr() bool {
+	return item.SoftExpiration > 0 || item.Beta > 0 || item.NegativeExpiration > 0
+}
+
 func (item *Item) exp() time.Duration {
 	if item.Expiration < 0 {
 		return 0
@@ -55,14 +83,15 @@ func (item *Item) exp() time.Duration {
 }
 
 type Codec struct {
-	Redis xservice.IRedis
+	Redis IRedis
 
-	localCache *xservice.Cache
+	localCache *lrucache.Cache
 
 	Marshal   func(interface{}) ([]byte, error)
 	Unmarshal func([]byte, interface{}) error
 
-	group xservice.Group
+	group      singleflight.Group
+	refreshing sync.Map
 
 	hits        uint64
 	misses      uint64
@@ -71,8 +100,9 @@ type Codec struct {
 }
 
 // UseLocalCache causes Codec to cache items in local LRU cache.
+// 其他实例更新 key 时本地缓存不会失效, 多实例部署时使用 xcache.NewTiered 和 NewBus
 func (cd *Codec) UseLocalCache(maxLen int, expiration time.Duration) {
-	cd.localCache = xservice.New(maxLen, expiration)
+	cd.localCache = lrucache.New(maxLen, expiration)
 }
 
 // Set caches the item.
@@ -89,7 +119,13 @@ func (cd *Codec) set(ctx context.Context, key string, obj interface{}, exp time.
 	defer xerror.RespErr(&err)
 
 	b, err = cd.Marshal(obj)
-	xerror.PanicM(err, "cache: Marshal key=%q failed: %s", key, err)
+	xerror.PanicF(err, "cache: Marshal key=%q failed: %s", key, err)
+
+	return cd.setBytes(ctx, key, b, exp)
+}
+
+func (cd *Codec) setBytes(ctx context.Context, key string, b []byte, exp time.Duration) (_ []byte, err error) {
+	defer xerror.RespErr(&err)
 
 	if cd.localCache != nil {
 		cd.localCache.Set(key, b)
@@ -103,7 +139,7 @@ func (cd *Codec) set(ctx context.Context, key string, obj interface{}, exp time.
 	}
 
 	err = cd.Redis.Set(key, b, exp).Err()
-	xerror.PanicM(err, "cache: Set key=%q failed: %s", key, err)
+	xerror.PanicF(err, "cache: Set key=%q failed: %s", key, err)
 
 	return b, err
 }
@@ -132,12 +168,19 @@ func (cd *Codec) get(ctx context.Context, key string, object interface{}, onlyLo
 		return err
 	}
 
+	if env, ok := decodeEnvelope(b); ok {
+		if env.notFound {
+			return ErrCacheMiss
+		}
+		b = env.data
+	}
+
 	if object == nil || len(b) == 0 {
 		return nil
 	}
 
 	err = cd.Unmarshal(b, object)
-	xerror.PanicM(err, "cache: key=%q Unmarshal(%T) failed: %s", key, object, err)
+	xerror.PanicF(err, "cache: key=%q Unmarshal(%T) failed: %s", key, object, err)
 
 	return nil
 }
@@ -169,7 +212,7 @@ func (cd *Codec) getBytes(key string, onlyLocalCache bool) ([]byte, error) {
 			return nil, ErrCacheMiss
 		}
 
-		xerror.PanicM(err, "cache: Get key=%q failed: %s", key, err)
+		xerror.PanicF(err, "cache: Get key=%q failed: %s", key, err)
 		return nil, err
 	}
 	atomic.AddUint64(&cd.hits, 1)
@@ -185,12 +228,26 @@ func (cd *Codec) getBytes(key string, onlyLocalCache bool) ([]byte, error) {
 // making sure that only one execution is in-flight for a given item.Key
 // at a time. If a duplicate comes in, the duplicate caller waits for the
 // original to complete and receives the same results.
+//
+// Items with SoftExpiration, Beta or NegativeExpiration are refreshed in the
+// background, see onceSWR.
 func (cd *Codec) Once(item *Item) error {
+	if item.swr() {
+		return cd.onceSWR(item)
+	}
+
 	b, cached, err := cd.getSetItemBytesOnce(item)
 	if err != nil {
 		return err
 	}
 
+	if env, ok := decodeEnvelope(b); ok {
+		if env.notFound {
+			return ErrNotFound
+		}
+		b = env.data
+	}
+
 	if item.Object == nil || len(b) == 0 {
 		return nil
 	}
@@ -201,7 +258,7 @@ func (cd *Codec) Once(item *Item) error {
 			_ = cd.Delete(item.Key)
 			return cd.Once(item)
 		}
-		xerror.PanicM(err, "cache: key=%q Unmarshal(%T) failed: %s", item.Key, item.Object, err)
+		xerror.PanicF(err, "cache: key=%q Unmarshal(%T) failed: %s", item.Key, item.Object, err)
 		return err
 	}
 
@@ -266,7 +323,7 @@ func (cd *Codec) DeleteContext(ctx context.Context, key string) error {
 	}
 
 	deleted, err := cd.Redis.Del(key).Result()
-	xerror.PanicM(err, "cache: Del key=%q failed: %s", key, err)
+	xerror.PanicF(err, "cache: Del key=%q failed: %s", key, err)
 
 	if deleted == 0 {
 		return ErrCacheMiss
@@ -1,12 +1,20 @@
 package xcache_redis
 
 import (
+	"github.com/pubgo/x/xcache/xcache_abc"
 	"github.com/pubgo/x/xmiddleware/cache/utils"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
+const (
+	DEFAULT = xcache_abc.DEFAULT
+	FOREVER = xcache_abc.FOREVER
+)
+
+var ErrNotStored = xcache_abc.ErrNotStored
+
 // RedisStore represents the cache with redis persistence
 type RedisStore struct {
 	pool              *redis.Pool
@@ -0,0 +1,173 @@
+package xcache_redis
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/pubgo/xerror"
+)
+
+// envelopeMagic marks values stored with refresh metadata.
+const envelopeMagic = "\x00xce1"
+
+const envelopeHeader = len(envelopeMagic) + 8 + 8 + 1
+
+// envelope is the value stored by Once for items with refresh options.
+type envelope struct {
+	// refreshAt is the unix nano time after which the object should be refreshed.
+	refreshAt int64
+	// delta is how long Item.Func took, used by XFetch.
+	delta    time.Duration
+	notFound bool
+	data     []byte
+}
+
+func (env *envelope) encode() []byte {
+	var b = make([]byte, envelopeHeader, envelopeHeader+len(env.data))
+	copy(b, envelopeMagic)
+	binary.BigEndian.PutUint64(b[len(envelopeMagic):], uint64(env.refreshAt))
+	binary.BigEndian.PutUint64(b[len(envelopeMagic)+8:], uint64(env.delta))
+	if env.notFound {
+		b[envelopeHeader-1] = 1
+	}
+	return append(b, env.data...)
+}
+
+func decodeEnvelope(b []byte) (*envelope, bool) {
+	if len(b) < envelopeHeader || string(b[:len(envelopeMagic)]) != envelopeMagic {
+		return nil, false
+	}
+
+	return &envelope{
+		refreshAt: int64(binary.BigEndian.Uint64(b[len(envelopeMagic):])),
+		delta:     time.Duration(binary.BigEndian.Uint64(b[len(envelopeMagic)+8:])),
+		notFound:  b[envelopeHeader-1] == 1,
+		data:      b[envelopeHeader:],
+	}, true
+}
+
+// shouldRefresh reports whether the object is stale, or should be refreshed
+// early according to XFetch.
+//
+// Inspired by http://www.vldb.org/pvldb/vol8/p886-vattani.pdf
+func (env *envelope) shouldRefresh(beta float64) bool {
+	if env.refreshAt == 0 {
+		return false
+	}
+
+	var now = time.Now().UnixNano()
+	if now >= env.refreshAt {
+		return true
+	}
+
+	if beta <= 0 || env.delta <= 0 {
+		return false
+	}
+
+	// -ln(rand) is exponentially distributed with mean 1, so the refresh
+	// probability grows as refreshAt approaches.
+	var early = float64(env.delta) * beta * -math.Log(1-rand.Float64())
+	return float64(now)+early >= float64(env.refreshAt)
+}
+
+// onceSWR
+// Once for items with refresh options.
+// Fresh objects are returned from the cache. Stale objects (or objects picked
+// by XFetch) are returned as well, while a single background refresh runs.
+// Only missing objects block the caller on Item.Func.
+func (cd *Codec) onceSWR(item *Item) error {
+	b, err := cd.getBytes(item.Key, false)
+	if err != nil && err != ErrCacheMiss {
+		return err
+	}
+
+	if err == nil {
+		env, ok := decodeEnvelope(b)
+		if !ok {
+			// Stored without refresh metadata, e.g. by Set.
+			return cd.unmarshalItem(item, b)
+		}
+
+		if env.shouldRefresh(item.Beta) {
+			cd.refresh(item)
+		}
+
+		if env.notFound {
+			return ErrNotFound
+		}
+		return cd.unmarshalItem(item, env.data)
+	}
+
+	obj, err := cd.group.Do(item.Key, func() (interface{}, error) { return cd.load(item) })
+	if err != nil {
+		return err
+	}
+
+	var env = obj.(*envelope)
+	if env.notFound {
+		return ErrNotFound
+	}
+	return cd.unmarshalItem(item, env.data)
+}
+
+func (cd *Codec) unmarshalItem(item *Item, b []byte) error {
+	if item.Object == nil || len(b) == 0 {
+		return nil
+	}
+	return xerror.WrapF(cd.Unmarshal(b, item.Object), "cache: key=%q Unmarshal(%T) failed", item.Key, item.Object)
+}
+
+// load calls Item.Func and caches the result with refresh metadata.
+func (cd *Codec) load(item *Item) (_ *envelope, err error) {
+	defer xerror.RespErr(&err)
+
+	var start = time.Now()
+	obj, err := item.Func()
+
+	var env = &envelope{delta: time.Since(start)}
+	var exp = item.exp()
+	switch {
+	case errors.Is(err, ErrNotFound) && item.NegativeExpiration > 0:
+		env.notFound = true
+		exp = item.NegativeExpiration
+	case err != nil:
+		return nil, err
+	default:
+		env.data, err = cd.Marshal(obj)
+		xerror.PanicF(err, "cache: Marshal key=%q failed", item.Key)
+	}
+
+	switch {
+	case env.notFound:
+	case item.SoftExpiration > 0:
+		env.refreshAt = start.Add(item.SoftExpiration).UnixNano()
+	case item.Beta > 0 && exp > 0:
+		env.refreshAt = start.Add(exp).UnixNano()
+	}
+
+	// Ignore error if we have the result.
+	_, _ = cd.setBytes(item.Context(), item.Key, env.encode(), exp)
+	return env, nil
+}
+
+// refresh reloads the item in the background, at most once at a time per key.
+func (cd *Codec) refresh(item *Item) {
+	if _, loaded := cd.refreshing.LoadOrStore(item.Key, struct{}{}); loaded {
+		return
+	}
+
+	// The caller owns item and its context, copy what the refresh needs.
+	var _item = *item
+	_item.Ctx = nil
+	_item.Object = nil
+
+	go func() {
+		defer cd.refreshing.Delete(_item.Key)
+		_ = xerror.Try(func() {
+			_, _ = cd.group.Do(_item.Key, func() (interface{}, error) { return cd.load(&_item) })
+		})
+	}()
+}
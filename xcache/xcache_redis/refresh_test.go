@@ -0,0 +1,150 @@
+package xcache_redis_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xcache/xcache_redis"
+)
+
+func newCodec() *xcache_redis.Codec {
+	var cd = &xcache_redis.Codec{Marshal: json.Marshal, Unmarshal: json.Unmarshal}
+	cd.UseLocalCache(100, time.Minute)
+	return cd
+}
+
+func TestOnceStaleWhileRevalidate(t *testing.T) {
+	var cd = newCodec()
+	var calls int32
+	var once = func() string {
+		var val string
+		err := cd.Once(&xcache_redis.Item{
+			Key:            "k",
+			Object:         &val,
+			Expiration:     time.Hour,
+			SoftExpiration: time.Millisecond * 50,
+			Func: func() (interface{}, error) {
+				n := atomic.AddInt32(&calls, 1)
+				time.Sleep(time.Millisecond * 20)
+				return string(rune('0' + n)), nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return val
+	}
+
+	if val := once(); val != "1" {
+		t.Fatal(val)
+	}
+
+	if val := once(); val != "1" || atomic.LoadInt32(&calls) != 1 {
+		t.Fatal(val, calls)
+	}
+
+	// stale values are served while a single background refresh runs
+	time.Sleep(time.Millisecond * 60)
+	var start = time.Now()
+	for i := 0; i < 10; i++ {
+		if val := once(); val != "1" {
+			t.Fatal(val)
+		}
+	}
+
+	if dur := time.Since(start); dur >= time.Millisecond*20 {
+		t.Fatal("stale read blocked on Func", dur)
+	}
+
+	time.Sleep(time.Millisecond * 40)
+	if val := once(); val != "2" || atomic.LoadInt32(&calls) != 2 {
+		t.Fatal(val, calls)
+	}
+
+	// Get reads values written by Once
+	var val string
+	if err := cd.Get("k", &val); err != nil || val != "2" {
+		t.Fatal(val, err)
+	}
+}
+
+func TestOnceEarlyRefresh(t *testing.T) {
+	var cd = newCodec()
+	var calls int32
+	var item = &xcache_redis.Item{
+		Key:        "k",
+		Expiration: time.Second,
+		Beta:       1e6,
+		Func: func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(time.Millisecond)
+			return "v", nil
+		},
+	}
+
+	if err := cd.Once(item); err != nil {
+		t.Fatal(err)
+	}
+
+	// a large beta always refreshes early
+	if err := cd.Once(item); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestOnceNegativeCache(t *testing.T) {
+	var cd = newCodec()
+	var calls int32
+	var item = &xcache_redis.Item{
+		Key:                "missing",
+		NegativeExpiration: time.Minute,
+		Func: func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, xcache_redis.ErrNotFound
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := cd.Once(item); err != xcache_redis.ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatal(n)
+	}
+
+	if err := cd.Get("missing", nil); err != xcache_redis.ErrCacheMiss {
+		t.Fatal(err)
+	}
+
+	// 包装之后的 ErrNotFound 同样缓存
+	calls = 0
+	item = &xcache_redis.Item{
+		Key:                "wrapped",
+		NegativeExpiration: time.Minute,
+		Func: func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, fmt.Errorf("db: %w", xcache_redis.ErrNotFound)
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := cd.Once(item); !errors.Is(err, xcache_redis.ErrNotFound) {
+			t.Fatal(err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,152 @@
+package xcache_redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+var _ xcache_abc.TagCache = (*redisCache)(nil)
+
+// NewCache
+// 基于 go-redis 的 xcache_abc.Cache, 支持单机, 集群和哨兵模式, 同时实现了 xcache_abc.TagCache
+// 多个 key 的操作通过 pipeline 逐个执行, 不使用 MGET 和多 key DEL, 集群模式下 key 可以在不同的 slot
+// tag 关联的 key 保存在 set 中, set 的过期时间不小于其中 key 的过期时间
+func NewCache(client redis.UniversalClient, defaultExpiration time.Duration) xcache_abc.Cache {
+	return &redisCache{client: client, defaultExpiration: defaultExpiration}
+}
+
+type redisCache struct {
+	client            redis.UniversalClient
+	defaultExpiration time.Duration
+}
+
+func (c *redisCache) expire(expire time.Duration) time.Duration {
+	if expire == xcache_abc.DEFAULT {
+		expire = c.defaultExpiration
+	}
+
+	if expire > 0 && expire < time.Millisecond {
+		expire = time.Millisecond
+	}
+	return expire
+}
+
+func (c *redisCache) setArgs(key string, value []byte, expire time.Duration) []interface{} {
+	if expire = c.expire(expire); expire > 0 {
+		return []interface{}{"set", key, value, "px", int64(expire / time.Millisecond)}
+	}
+	return []interface{}{"set", key, value}
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var cmd = redis.NewStringCmd("get", key)
+	if err := c.client.ProcessContext(ctx, cmd); err != nil {
+		if err == redis.Nil {
+			return nil, xcache_abc.ErrCacheMiss
+		}
+		return nil, err
+	}
+	return cmd.Bytes()
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte, expire time.Duration) error {
+	return c.client.ProcessContext(ctx, redis.NewStatusCmd(c.setArgs(key, value, expire)...))
+}
+
+func (c *redisCache) Delete(ctx context.Context, key string) error {
+	return c.client.ProcessContext(ctx, redis.NewIntCmd("del", key))
+}
+
+func (c *redisCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	var items = make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return items, nil
+	}
+
+	var pipe = c.client.Pipeline()
+	defer pipe.Close()
+
+	var cmds = make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = redis.NewStringCmd("get", key)
+		_ = pipe.Process(cmds[i])
+	}
+
+	if _, err := pipe.ExecContext(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		switch err := cmd.Err(); err {
+		case nil:
+			items[keys[i]], _ = cmd.Bytes()
+		case redis.Nil:
+		default:
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (c *redisCache) SetMulti(ctx context.Context, items map[string][]byte, expire time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	var pipe = c.client.Pipeline()
+	defer pipe.Close()
+
+	for key, val := range items {
+		if err := pipe.Process(redis.NewStatusCmd(c.setArgs(key, val, expire)...)); err != nil {
+			return err
+		}
+	}
+
+	_, err := pipe.ExecContext(ctx)
+	return err
+}
+
+func (c *redisCache) DeleteMulti(ctx context.Context, keys []string) error {
+	return del(ctx, c.client, keys)
+}
+
+func (c *redisCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	var cmd = redis.NewIntCmd("incrby", key, delta)
+	if err := c.client.ProcessContext(ctx, cmd); err != nil {
+		return 0, err
+	}
+	return cmd.Val(), nil
+}
+
+func (c *redisCache) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.Increment(ctx, key, -delta)
+}
+
+func (c *redisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	var cmd = redis.NewDurationCmd(time.Millisecond, "pttl", key)
+	if err := c.client.ProcessContext(ctx, cmd); err != nil {
+		return 0, err
+	}
+
+	switch ttl := cmd.Val(); ttl {
+	case -2:
+		return 0, xcache_abc.ErrCacheMiss
+	case -1:
+		return xcache_abc.FOREVER, nil
+	default:
+		return ttl, nil
+	}
+}
+
+// Flush 集群模式下清空所有的 master 节点
+func (c *redisCache) Flush(ctx context.Context) error {
+	if cc, ok := c.client.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(func(client *redis.Client) error {
+			return client.ProcessContext(ctx, redis.NewStatusCmd("flushdb"))
+		})
+	}
+	return c.client.ProcessContext(ctx, redis.NewStatusCmd("flushdb"))
+}
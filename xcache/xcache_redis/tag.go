@@ -0,0 +1,129 @@
+package xcache_redis
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/pubgo/x/xcache/xcache_abc"
+)
+
+// tagScript
+// 把 key 加入 tag 的 set, set 的过期时间取最大值, ARGV[2] <= 0 表示不过期
+// 每次只操作一个 key, 可以在集群模式下使用
+const tagScript = `
+local existed = redis.call('exists', KEYS[1])
+redis.call('sadd', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call('persist', KEYS[1])
+elseif existed == 0 then
+	redis.call('pexpire', KEYS[1], ttl)
+else
+	local cur = redis.call('pttl', KEYS[1])
+	if cur >= 0 and cur < ttl then
+		redis.call('pexpire', KEYS[1], ttl)
+	end
+end
+return 1
+`
+
+const scanCount = 1000
+
+type redisClient interface {
+	ProcessContext(ctx context.Context, cmd redis.Cmder) error
+	Pipeline() redis.Pipeliner
+}
+
+func tagKey(tag string) string {
+	return xcache_abc.KeyPrefix + ":tag:" + tag
+}
+
+func (c *redisCache) SetWithTags(ctx context.Context, key string, value []byte, expire time.Duration, tags ...string) error {
+	var pipe = c.client.Pipeline()
+	defer pipe.Close()
+
+	_ = pipe.Process(redis.NewStatusCmd(c.setArgs(key, value, expire)...))
+
+	var ttl = int64(c.expire(expire) / time.Millisecond)
+	for _, tag := range tags {
+		_ = pipe.Process(redis.NewCmd("eval", tagScript, 1, tagKey(tag), key, ttl))
+	}
+
+	_, err := pipe.ExecContext(ctx)
+	return err
+}
+
+func (c *redisCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		// spop 删除 set 中的 key, 并发写入的 key 留给下一次失效
+		for {
+			var cmd = redis.NewStringSliceCmd("spop", tagKey(tag), scanCount)
+			if err := c.client.ProcessContext(ctx, cmd); err != nil && err != redis.Nil {
+				return err
+			}
+
+			if len(cmd.Val()) == 0 {
+				break
+			}
+
+			if err := del(ctx, c.client, cmd.Val()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *redisCache) DeletePrefix(ctx context.Context, prefix string) error {
+	var match = escapeGlob(prefix) + "*"
+	if cc, ok := c.client.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(func(client *redis.Client) error {
+			return scanDel(ctx, client, match)
+		})
+	}
+	return scanDel(ctx, c.client, match)
+}
+
+func scanDel(ctx context.Context, client redisClient, match string) error {
+	var cursor uint64
+	for {
+		var cmd = redis.NewScanCmd(nil, "scan", cursor, "match", match, "count", scanCount)
+		if err := client.ProcessContext(ctx, cmd); err != nil {
+			return err
+		}
+
+		keys, next := cmd.Val()
+		if err := del(ctx, client, keys); err != nil {
+			return err
+		}
+
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// del 每个 key 单独删除, 可以在集群模式下使用
+func del(ctx context.Context, client redisClient, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var pipe = client.Pipeline()
+	defer pipe.Close()
+
+	for _, key := range keys {
+		_ = pipe.Process(redis.NewIntCmd("del", key))
+	}
+
+	_, err := pipe.ExecContext(ctx)
+	return err
+}
+
+var globReplacer = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func escapeGlob(s string) string {
+	return globReplacer.Replace(s)
+}
@@ -0,0 +1,244 @@
+package xcache_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pubgo/x/xcache"
+	"github.com/pubgo/x/xmiddleware/cache/persistence"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func doRequest(r http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPageCache(t *testing.T) {
+	var calls int32
+	var store = persistence.NewInMemoryStore(time.Minute)
+	var page = xcache.NewPageCache(store, time.Minute, xcache.WithVary("Accept-Language"))
+
+	r := gin.New()
+	r.GET("/page", page.Handle(func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.Header("Vary", "Accept-Language")
+		c.Header("Content-Type", "text/plain")
+		c.Writer.WriteString("hello ")
+		c.Writer.WriteString(c.GetHeader("Accept-Language"))
+	}))
+	r.GET("/private", page.Handle(func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.Header("Cache-Control", "private")
+		c.String(http.StatusOK, "private")
+	}))
+
+	w := doRequest(r, "/page", "Accept-Language", "en")
+	if w.Body.String() != "hello en" {
+		t.Fatal(w.Body.String())
+	}
+
+	// 多次 Write 只缓存一次完整的响应
+	w = doRequest(r, "/page", "Accept-Language", "en")
+	if w.Body.String() != "hello en" || w.Header().Get("Content-Type") != "text/plain" || calls != 1 {
+		t.Fatal(w.Body.String(), calls)
+	}
+
+	var etag = w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	w = doRequest(r, "/page", "Accept-Language", "en", "If-None-Match", "W/"+etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	// Vary 的请求头不同
+	w = doRequest(r, "/page", "Accept-Language", "zh")
+	if w.Body.String() != "hello zh" || calls != 2 {
+		t.Fatal(w.Body.String(), calls)
+	}
+
+	// 请求 no-cache 跳过缓存并更新
+	doRequest(r, "/page", "Accept-Language", "en", "Cache-Control", "no-cache")
+	if calls != 3 {
+		t.Fatal(calls)
+	}
+
+	// purge 删除所有变体
+	if err := page.Purge("/page"); err != nil {
+		t.Fatal(err)
+	}
+	doRequest(r, "/page", "Accept-Language", "en")
+	doRequest(r, "/page", "Accept-Language", "zh")
+	if calls != 5 {
+		t.Fatal(calls)
+	}
+
+	// private 不缓存
+	doRequest(r, "/private")
+	doRequest(r, "/private")
+	if calls != 7 {
+		t.Fatal(calls)
+	}
+}
+
+func TestPageCacheControl(t *testing.T) {
+	var store = persistence.NewInMemoryStore(time.Minute)
+	var page = xcache.NewPageCache(store, time.Minute)
+
+	var calls int32
+	var handle = func(cc string) gin.HandlerFunc {
+		return page.Handle(func(c *gin.Context) {
+			atomic.AddInt32(&calls, 1)
+			if cc != "" {
+				c.Header("Cache-Control", cc)
+			}
+			c.String(http.StatusOK, "ok")
+		})
+	}
+
+	r := gin.New()
+	r.GET("/no-store", handle("no-store"))
+	r.GET("/max-age-0", handle("max-age=0"))
+	r.GET("/vary", func(c *gin.Context) {
+		c.Header("Vary", "Cookie")
+		handle("")(c)
+	})
+	r.GET("/max-age", handle("public, max-age=60"))
+
+	for _, path := range []string{"/no-store", "/max-age-0", "/vary"} {
+		calls = 0
+		doRequest(r, path)
+		doRequest(r, path)
+		if calls != 2 {
+			t.Fatal(path, calls)
+		}
+	}
+
+	calls = 0
+	doRequest(r, "/max-age")
+	w := doRequest(r, "/max-age")
+	if calls != 1 || w.Header().Get("Age") == "" {
+		t.Fatal(calls, w.Header())
+	}
+}
+
+func TestPageCacheCoalescing(t *testing.T) {
+	var store = persistence.NewInMemoryStore(time.Minute)
+	var page = xcache.NewPageCache(store, time.Minute)
+
+	var calls int32
+	var start = make(chan struct{})
+	r := gin.New()
+	r.Use(page.Middleware())
+	r.GET("/slow", func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		<-start
+		c.String(http.StatusOK, "slow")
+	})
+
+	var wg sync.WaitGroup
+	var bodies = make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = doRequest(r, "/slow").Body.String()
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatal(calls)
+	}
+	for _, body := range bodies {
+		if body != "slow" {
+			t.Fatal(bodies)
+		}
+	}
+}
+
+func TestPageCacheCoalescingStream(t *testing.T) {
+	var store = persistence.NewInMemoryStore(time.Minute)
+	var page = xcache.NewPageCache(store, time.Minute)
+
+	// 第一个请求写出响应头之后阻塞, 直到 done 关闭
+	var handler = func(start, done chan struct{}, write func(c *gin.Context)) gin.HandlerFunc {
+		var calls int32
+		return func(c *gin.Context) {
+			if atomic.AddInt32(&calls, 1) > 1 {
+				c.String(http.StatusOK, "b")
+				return
+			}
+
+			write(c)
+			close(start)
+			<-done
+		}
+	}
+
+	var writes = map[string]func(c *gin.Context){
+		"/stream": func(c *gin.Context) {
+			c.Writer.WriteString("a")
+			c.Writer.Flush()
+		},
+		"/private": func(c *gin.Context) {
+			c.Header("Cache-Control", "private")
+			c.Writer.WriteHeaderNow()
+		},
+	}
+
+	// 流式或者不能缓存的响应, 等待中的请求不等待响应结束
+	for path, write := range writes {
+		var start, done = make(chan struct{}), make(chan struct{})
+		r := gin.New()
+		r.Use(page.Middleware())
+		r.GET(path, handler(start, done, write))
+
+		var w = make(chan *httptest.ResponseRecorder)
+		go func() { w <- doRequest(r, path) }()
+
+		<-start
+		if body := doRequest(r, path).Body.String(); body != "b" {
+			t.Fatal(path, body)
+		}
+
+		close(done)
+		<-w
+	}
+}
+
+func TestPageCacheMaxBodySize(t *testing.T) {
+	var store = persistence.NewInMemoryStore(time.Minute)
+	var page = xcache.NewPageCache(store, time.Minute, xcache.WithMaxBodySize(4))
+
+	var calls int32
+	r := gin.New()
+	r.GET("/big", page.Handle(func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.String(http.StatusOK, "too big")
+	}))
+
+	doRequest(r, "/big")
+	if w := doRequest(r, "/big"); w.Body.String() != "too big" || calls != 2 {
+		t.Fatal(w.Body.String(), calls)
+	}
+}
@@ -0,0 +1,707 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pubgo/x/xmiddleware/cache/utils"
+)
+
+const (
+	diskSegmentExt = ".seg"
+
+	// crc(4) expireAt(8) kind(1) keyLen(4) valueLen(4)
+	diskHeaderSize = 21
+
+	diskKindPut    = 0
+	diskKindDelete = 1
+)
+
+var errDiskCorrupt = errors.New("cache: corrupt disk record")
+
+var _ CacheStore = (*DiskStore)(nil)
+
+// DiskOption configures a DiskStore
+type DiskOption func(s *DiskStore)
+
+// WithSegmentSize the size at which the active segment file is sealed and a new one started, default 64MB
+func WithSegmentSize(n int64) DiskOption {
+	return func(s *DiskStore) {
+		s.segmentSize = n
+	}
+}
+
+// WithMaxDiskSize the maximum total size of the segment files, default 0 means no limit.
+// When a write would exceed it the store compacts, then drops the oldest segments.
+func WithMaxDiskSize(n int64) DiskOption {
+	return func(s *DiskStore) {
+		s.maxDiskSize = n
+	}
+}
+
+// WithCompactInterval how often expired and overwritten entries are compacted away, default 1 minute
+func WithCompactInterval(d time.Duration) DiskOption {
+	return func(s *DiskStore) {
+		s.compactInterval = d
+	}
+}
+
+// WithSyncWrites fsync the active segment after every write
+func WithSyncWrites() DiskOption {
+	return func(s *DiskStore) {
+		s.syncWrites = true
+	}
+}
+
+type diskSegment struct {
+	id   int
+	file *os.File
+	size int64
+	live int64
+}
+
+type diskEntry struct {
+	seg      int
+	off      int64
+	size     int64
+	valueOff int64
+	valueLen int
+	expireAt int64
+}
+
+func (e *diskEntry) expired(now int64) bool {
+	return e.expireAt > 0 && now > e.expireAt
+}
+
+type diskRecord struct {
+	kind     byte
+	expireAt int64
+	key      string
+	value    []byte
+}
+
+func (r *diskRecord) size() int64 {
+	return int64(diskHeaderSize + len(r.key) + len(r.value))
+}
+
+func (r *diskRecord) encode() []byte {
+	var b = make([]byte, r.size())
+	binary.BigEndian.PutUint64(b[4:], uint64(r.expireAt))
+	b[12] = r.kind
+	binary.BigEndian.PutUint32(b[13:], uint32(len(r.key)))
+	binary.BigEndian.PutUint32(b[17:], uint32(len(r.value)))
+	copy(b[diskHeaderSize:], r.key)
+	copy(b[diskHeaderSize+len(r.key):], r.value)
+	binary.BigEndian.PutUint32(b, crc32.ChecksumIEEE(b[4:]))
+	return b
+}
+
+func readDiskRecord(r io.Reader) (*diskRecord, error) {
+	var header [diskHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errDiskCorrupt
+		}
+		return nil, err
+	}
+
+	var keyLen = binary.BigEndian.Uint32(header[13:])
+	var valueLen = binary.BigEndian.Uint32(header[17:])
+	if header[12] > diskKindDelete || keyLen > 1<<16 || valueLen > 1<<30 {
+		return nil, errDiskCorrupt
+	}
+
+	var body = make([]byte, keyLen+valueLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, errDiskCorrupt
+	}
+
+	var crc = crc32.ChecksumIEEE(header[4:])
+	if crc32.Update(crc, crc32.IEEETable, body) != binary.BigEndian.Uint32(header[:]) {
+		return nil, errDiskCorrupt
+	}
+
+	return &diskRecord{
+		kind:     header[12],
+		expireAt: int64(binary.BigEndian.Uint64(header[4:])),
+		key:      string(body[:keyLen]),
+		value:    body[keyLen:],
+	}, nil
+}
+
+// DiskStore represents the cache with local disk persistence.
+//
+// Entries are appended to segment files in dir and indexed in memory, so the store survives
+// restarts but the key set must fit in memory. Overwritten, deleted and expired entries are
+// removed by a background compaction that rewrites mostly dead segments.
+type DiskStore struct {
+	mu sync.RWMutex
+
+	dir               string
+	defaultExpiration time.Duration
+	segmentSize       int64
+	maxDiskSize       int64
+	compactInterval   time.Duration
+	syncWrites        bool
+
+	index    map[string]*diskEntry
+	segments map[int]*diskSegment
+	active   *diskSegment
+	diskSize int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDiskStore opens or creates a DiskStore in dir
+func NewDiskStore(dir string, defaultExpiration time.Duration, opts ...DiskOption) (*DiskStore, error) {
+	var s = &DiskStore{
+		dir:               dir,
+		defaultExpiration: defaultExpiration,
+		segmentSize:       64 << 20,
+		compactInterval:   time.Minute,
+		index:             make(map[string]*diskEntry),
+		segments:          make(map[int]*diskSegment),
+		done:              make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := s.load(); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	if s.compactInterval > 0 {
+		s.wg.Add(1)
+		go s.loop()
+	}
+	return s, nil
+}
+
+func (s *DiskStore) segmentPath(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%09d%s", id, diskSegmentExt))
+}
+
+func (s *DiskStore) load() error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), diskSegmentExt) {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSuffix(fi.Name(), diskSegmentExt))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for i, id := range ids {
+		if err := s.loadSegment(id, i == len(ids)-1); err != nil {
+			return err
+		}
+	}
+
+	if len(ids) > 0 && s.segments[ids[len(ids)-1]].size < s.segmentSize {
+		s.active = s.segments[ids[len(ids)-1]]
+		return nil
+	}
+
+	var next int
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+	return s.openSegment(next)
+}
+
+// loadSegment replays a segment into the index, a torn write at the end of the last segment is truncated
+func (s *DiskStore) loadSegment(id int, last bool) error {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	var seg = &diskSegment{id: id, file: f}
+	s.segments[id] = seg
+
+	var now = time.Now().UnixNano()
+	var r = bufio.NewReader(f)
+	for {
+		rec, err := readDiskRecord(r)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil && err != errDiskCorrupt {
+			return err
+		}
+
+		if err != nil {
+			if !last {
+				// the rest of a sealed segment is unreadable, its size still counts until compaction
+				fi, err := f.Stat()
+				if err != nil {
+					return err
+				}
+				seg.size = fi.Size()
+				break
+			}
+
+			if err := f.Truncate(seg.size); err != nil {
+				return err
+			}
+			break
+		}
+
+		s.apply(seg, rec, seg.size, now)
+		seg.size += rec.size()
+	}
+
+	s.diskSize += seg.size
+	return nil
+}
+
+// apply updates the index for a record written at off in seg
+func (s *DiskStore) apply(seg *diskSegment, rec *diskRecord, off int64, now int64) {
+	if old := s.index[rec.key]; old != nil {
+		s.segments[old.seg].live -= old.size
+		delete(s.index, rec.key)
+	}
+
+	if rec.kind == diskKindDelete {
+		return
+	}
+
+	var entry = &diskEntry{
+		seg:      seg.id,
+		off:      off,
+		size:     rec.size(),
+		valueOff: off + diskHeaderSize + int64(len(rec.key)),
+		valueLen: len(rec.value),
+		expireAt: rec.expireAt,
+	}
+	if entry.expired(now) {
+		return
+	}
+
+	s.index[rec.key] = entry
+	seg.live += entry.size
+}
+
+func (s *DiskStore) openSegment(id int) error {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	s.active = &diskSegment{id: id, file: f}
+	s.segments[id] = s.active
+	return nil
+}
+
+func (s *DiskStore) removeSegment(seg *diskSegment) error {
+	delete(s.segments, seg.id)
+	s.diskSize -= seg.size
+	if err := seg.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(seg.file.Name())
+}
+
+// write appends rec to the active segment and updates the index
+func (s *DiskStore) write(rec *diskRecord) error {
+	if s.active.size >= s.segmentSize {
+		if err := s.openSegment(s.active.id + 1); err != nil {
+			return err
+		}
+	}
+
+	var b = rec.encode()
+	var seg = s.active
+	if _, err := seg.file.WriteAt(b, seg.size); err != nil {
+		return err
+	}
+
+	if s.syncWrites {
+		if err := seg.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	s.apply(seg, rec, seg.size, time.Now().UnixNano())
+	seg.size += int64(len(b))
+	s.diskSize += int64(len(b))
+	return nil
+}
+
+// reserve makes room for n more bytes when a max disk size is configured
+func (s *DiskStore) reserve(n int64) error {
+	if s.maxDiskSize <= 0 || s.diskSize+n <= s.maxDiskSize {
+		return nil
+	}
+
+	if n > s.maxDiskSize {
+		return ErrNotStored
+	}
+
+	// compact only rewrites sealed segments, seal the active one so that its dead records are reclaimed too
+	if s.active.size > 0 {
+		if err := s.openSegment(s.active.id + 1); err != nil {
+			return err
+		}
+	}
+
+	if err := s.compact(true); err != nil {
+		return err
+	}
+
+	for s.diskSize+n > s.maxDiskSize {
+		var oldest *diskSegment
+		for _, seg := range s.segments {
+			if seg != s.active && (oldest == nil || seg.id < oldest.id) {
+				oldest = seg
+			}
+		}
+
+		if oldest == nil {
+			return ErrNotStored
+		}
+
+		for key, entry := range s.index {
+			if entry.seg == oldest.id {
+				delete(s.index, key)
+			}
+		}
+
+		if err := s.removeSegment(oldest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DiskStore) expireAt(expires time.Duration) int64 {
+	switch expires {
+	case DEFAULT:
+		expires = s.defaultExpiration
+	case FOREVER:
+		expires = time.Duration(0)
+	}
+
+	if expires <= 0 {
+		return 0
+	}
+	return time.Now().Add(expires).UnixNano()
+}
+
+func (s *DiskStore) put(key string, value []byte, expireAt int64) error {
+	var rec = &diskRecord{kind: diskKindPut, expireAt: expireAt, key: key, value: value}
+	if err := s.reserve(rec.size()); err != nil {
+		return err
+	}
+	return s.write(rec)
+}
+
+// lookup returns the live entry of key, the caller holds the lock
+func (s *DiskStore) lookup(key string) *diskEntry {
+	entry := s.index[key]
+	if entry == nil || entry.expired(time.Now().UnixNano()) {
+		return nil
+	}
+	return entry
+}
+
+func (s *DiskStore) read(entry *diskEntry) ([]byte, error) {
+	var b = make([]byte, entry.valueLen)
+	if _, err := s.segments[entry.seg].file.ReadAt(b, entry.valueOff); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Get (see CacheStore interface)
+func (s *DiskStore) Get(key string, value interface{}) error {
+	s.mu.RLock()
+	entry := s.lookup(key)
+	if entry == nil {
+		s.mu.RUnlock()
+		return ErrCacheMiss
+	}
+
+	b, err := s.read(entry)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return utils.Deserialize(b, value)
+}
+
+// Set (see CacheStore interface)
+func (s *DiskStore) Set(key string, value interface{}, expires time.Duration) error {
+	b, err := utils.Serialize(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(key, b, s.expireAt(expires))
+}
+
+// Add (see CacheStore interface)
+func (s *DiskStore) Add(key string, value interface{}, expires time.Duration) error {
+	b, err := utils.Serialize(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(key) != nil {
+		return ErrNotStored
+	}
+	return s.put(key, b, s.expireAt(expires))
+}
+
+// Replace (see CacheStore interface)
+func (s *DiskStore) Replace(key string, value interface{}, expires time.Duration) error {
+	b, err := utils.Serialize(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(key) == nil {
+		return ErrNotStored
+	}
+	return s.put(key, b, s.expireAt(expires))
+}
+
+// Delete (see CacheStore interface)
+func (s *DiskStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(key) == nil {
+		return ErrCacheMiss
+	}
+	return s.write(&diskRecord{kind: diskKindDelete, key: key})
+}
+
+// Expire changes the expiration of an existing key
+func (s *DiskStore) Expire(key string, expires time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.lookup(key)
+	if entry == nil {
+		return ErrCacheMiss
+	}
+
+	b, err := s.read(entry)
+	if err != nil {
+		return err
+	}
+	return s.put(key, b, s.expireAt(expires))
+}
+
+// Increment (see CacheStore interface)
+func (s *DiskStore) Increment(key string, delta uint64) (uint64, error) {
+	return s.incr(key, func(n uint64) uint64 { return n + delta })
+}
+
+// Decrement (see CacheStore interface), the value does not go below 0
+func (s *DiskStore) Decrement(key string, delta uint64) (uint64, error) {
+	return s.incr(key, func(n uint64) uint64 {
+		if delta > n {
+			return 0
+		}
+		return n - delta
+	})
+}
+
+func (s *DiskStore) incr(key string, fn func(n uint64) uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.lookup(key)
+	if entry == nil {
+		return 0, ErrCacheMiss
+	}
+
+	b, err := s.read(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	n = fn(n)
+	return n, s.put(key, []byte(strconv.FormatUint(n, 10)), entry.expireAt)
+}
+
+// Flush (see CacheStore interface)
+func (s *DiskStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next = s.active.id + 1
+	for _, seg := range s.segments {
+		if err := s.removeSegment(seg); err != nil {
+			return err
+		}
+	}
+
+	s.index = make(map[string]*diskEntry)
+	return s.openSegment(next)
+}
+
+// Size the total size of the segment files
+func (s *DiskStore) Size() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.diskSize
+}
+
+// Compact removes expired entries and rewrites sealed segments that are mostly dead
+func (s *DiskStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact(false)
+}
+
+// compact with all set every sealed segment is rewritten
+func (s *DiskStore) compact(all bool) error {
+	var now = time.Now().UnixNano()
+	for key, entry := range s.index {
+		if entry.expired(now) {
+			s.segments[entry.seg].live -= entry.size
+			delete(s.index, key)
+		}
+	}
+
+	var ids []int
+	for id, seg := range s.segments {
+		if seg != s.active {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	// tombstones are only needed while an older segment may still hold the deleted value
+	var olderKept bool
+	for _, id := range ids {
+		var seg = s.segments[id]
+		if !all && seg.live*2 > seg.size {
+			olderKept = true
+			continue
+		}
+
+		if err := s.rewrite(seg, olderKept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite copies the live records of seg to the active segment and removes seg.
+// With keepTombstones every dropped key that is not live any more gets a tombstone,
+// otherwise an older value of an expired or deleted key comes back after a restart
+func (s *DiskStore) rewrite(seg *diskSegment, keepTombstones bool) error {
+	if seg.live > 0 || keepTombstones {
+		var off int64
+		var tombstones = make(map[string]bool)
+		var r = bufio.NewReader(io.NewSectionReader(seg.file, 0, seg.size))
+		for {
+			rec, err := readDiskRecord(r)
+			if err == io.EOF || err == errDiskCorrupt {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+
+			var entry = s.index[rec.key]
+			var live = rec.kind == diskKindPut && entry != nil && entry.seg == seg.id && entry.off == off
+			off += rec.size()
+
+			switch {
+			case live:
+			case entry == nil && keepTombstones && !tombstones[rec.key]:
+				tombstones[rec.key] = true
+				rec = &diskRecord{kind: diskKindDelete, key: rec.key}
+			default:
+				continue
+			}
+
+			if err := s.write(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return s.removeSegment(seg)
+}
+
+func (s *DiskStore) loop() {
+	defer s.wg.Done()
+
+	var ticker = time.NewTicker(s.compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			_ = s.Compact()
+		}
+	}
+}
+
+func (s *DiskStore) closeFiles() error {
+	var err error
+	for _, seg := range s.segments {
+		if e := seg.file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Close stops the background compaction and closes the segment files
+func (s *DiskStore) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeFiles()
+}
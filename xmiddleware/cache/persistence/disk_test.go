@@ -0,0 +1,258 @@
+package persistence_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xmiddleware/cache/persistence"
+)
+
+func newDiskStore(t *testing.T, dir string, opts ...persistence.DiskOption) *persistence.DiskStore {
+	opts = append([]persistence.DiskOption{persistence.WithCompactInterval(0)}, opts...)
+	s, err := persistence.NewDiskStore(dir, time.Hour, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDiskStore(t *testing.T) {
+	var dir = t.TempDir()
+	var s = newDiskStore(t, dir)
+
+	if err := s.Set("str", "hello", persistence.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("str", "world", persistence.DEFAULT); err != persistence.ErrNotStored {
+		t.Fatal(err)
+	}
+	if err := s.Replace("missing", "world", persistence.DEFAULT); err != persistence.ErrNotStored {
+		t.Fatal(err)
+	}
+
+	if err := s.Set("n", 10, persistence.FOREVER); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Increment("n", 5); err != nil || n != 15 {
+		t.Fatal(n, err)
+	}
+	if n, err := s.Decrement("n", 20); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+	if _, err := s.Increment("missing", 1); err != persistence.ErrCacheMiss {
+		t.Fatal(err)
+	}
+
+	if err := s.Set("gone", "x", persistence.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Set("short", "x", persistence.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Expire("short", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// 重启后恢复
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s = newDiskStore(t, dir)
+	defer s.Close()
+
+	var str string
+	if err := s.Get("str", &str); err != nil || str != "hello" {
+		t.Fatal(str, err)
+	}
+
+	var n int
+	if err := s.Get("n", &n); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+
+	for _, key := range []string{"gone", "short"} {
+		if err := s.Get(key, &str); err != persistence.ErrCacheMiss {
+			t.Fatal(key, err)
+		}
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get("str", &str); err != persistence.ErrCacheMiss {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskStoreCompact(t *testing.T) {
+	var dir = t.TempDir()
+	var s = newDiskStore(t, dir, persistence.WithSegmentSize(1024))
+
+	for i := 0; i < 200; i++ {
+		if err := s.Set("key"+strconv.Itoa(i%10), make([]byte, 64), persistence.DEFAULT); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("key0"); err != nil {
+		t.Fatal(err)
+	}
+
+	var before = s.Size()
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Size() >= before/2 {
+		t.Fatal(before, s.Size())
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s = newDiskStore(t, dir, persistence.WithSegmentSize(1024))
+	defer s.Close()
+
+	var b []byte
+	for i := 1; i < 10; i++ {
+		if err := s.Get("key"+strconv.Itoa(i), &b); err != nil || len(b) != 64 {
+			t.Fatal(i, err)
+		}
+	}
+	if err := s.Get("key0", &b); err != persistence.ErrCacheMiss {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskStoreCompactExpired(t *testing.T) {
+	var dir = t.TempDir()
+	var s = newDiskStore(t, dir, persistence.WithSegmentSize(1024))
+
+	// 第一个段大部分是有效数据, 压缩时保留
+	if err := s.Set("a", "old", persistence.FOREVER); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		if err := s.Set("key"+strconv.Itoa(i), make([]byte, 64), persistence.DEFAULT); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 第二个段大部分是无效数据, 压缩时重写, 过期的覆盖写入被丢弃
+	if err := s.Set("a", "new", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := s.Set("x", make([]byte, 64), persistence.DEFAULT); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Set("y", "y", persistence.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后旧的值不会重新生效
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s = newDiskStore(t, dir, persistence.WithSegmentSize(1024))
+	defer s.Close()
+
+	var a string
+	if err := s.Get("a", &a); err != persistence.ErrCacheMiss {
+		t.Fatal(a, err)
+	}
+
+	var b []byte
+	if err := s.Get("key0", &b); err != nil || len(b) != 64 {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskStoreMaxSize(t *testing.T) {
+	var s = newDiskStore(t, t.TempDir(), persistence.WithSegmentSize(1024), persistence.WithMaxDiskSize(4096))
+	defer s.Close()
+
+	for i := 0; i < 200; i++ {
+		if err := s.Set("key"+strconv.Itoa(i), make([]byte, 64), persistence.DEFAULT); err != nil {
+			t.Fatal(err)
+		}
+		if s.Size() > 4096 {
+			t.Fatal(s.Size())
+		}
+	}
+
+	var b []byte
+	if err := s.Get("key199", &b); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get("key0", &b); err != persistence.ErrCacheMiss {
+		t.Fatal(err)
+	}
+
+	if err := s.Set("big", make([]byte, 8192), persistence.DEFAULT); err != persistence.ErrNotStored {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskStoreMaxSizeOverwrite(t *testing.T) {
+	var s = newDiskStore(t, t.TempDir(), persistence.WithMaxDiskSize(4096))
+	defer s.Close()
+
+	// 上限小于段大小时, 活跃段中被覆盖的记录同样可以回收
+	for i := 0; i < 200; i++ {
+		if err := s.Set("key", make([]byte, 100), persistence.DEFAULT); err != nil {
+			t.Fatal(i, err)
+		}
+		if s.Size() > 4096 {
+			t.Fatal(s.Size())
+		}
+	}
+
+	var b []byte
+	if err := s.Get("key", &b); err != nil || len(b) != 100 {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskStoreTornWrite(t *testing.T) {
+	var dir = t.TempDir()
+	var s = newDiskStore(t, dir)
+	if err := s.Set("a", "a", persistence.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	f, err := os.OpenFile(files[len(files)-1], os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	s = newDiskStore(t, dir)
+	defer s.Close()
+
+	var a string
+	if err := s.Get("a", &a); err != nil || a != "a" {
+		t.Fatal(a, err)
+	}
+	if err := s.Set("b", "b", persistence.DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get("b", &a); err != nil || a != "b" {
+		t.Fatal(a, err)
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"time"
 )
 
+var errUnsupportedType = errors.New("unsupported type")
+
 type Array []interface{}
 type Map map[string]interface{}
 
@@ -111,7 +113,7 @@ func next(p *xml.Decoder) (_ xml.Name, _ interface{}, err error) {
 			// name
 			se, e = nextStart(p)
 			xerror.Panic(e)
-			xerror.PanicT(se.Name.Local != "name", "invalid response")
+			xerror.Assert(se.Name.Local != "name", "invalid response")
 
 			var name string
 			xerror.Panic(p.DecodeElement(&name, &se))
@@ -122,7 +124,7 @@ func next(p *xml.Decoder) (_ xml.Name, _ interface{}, err error) {
 			// value
 			_, value, e := next(p)
 			xerror.Panic(e)
-			xerror.PanicT(se.Name.Local != "value", "invalid response")
+			xerror.Assert(se.Name.Local != "value", "invalid response")
 
 			st[name] = value
 
@@ -190,7 +192,7 @@ func toXml(v interface{}, typ bool) (s string) {
 
 	switch k {
 	case reflect.Invalid:
-		xerror.Panic("unsupported type")
+		xerror.Panic(errUnsupportedType)
 	case reflect.Bool:
 		var b string
 		if v.(bool) {
@@ -208,14 +210,14 @@ func toXml(v interface{}, typ bool) (s string) {
 		}
 		return fmt.Sprintf("%v", v)
 	case reflect.Uintptr:
-		xerror.Panic("unsupported type")
+		xerror.Panic(errUnsupportedType)
 	case reflect.Float32, reflect.Float64:
 		if typ {
 			return fmt.Sprintf("<double>%v</double>", v)
 		}
 		return fmt.Sprintf("%v", v)
 	case reflect.Complex64, reflect.Complex128:
-		xerror.Panic("unsupported type")
+		xerror.Panic(errUnsupportedType)
 	case reflect.Array:
 		s = "<array><data>"
 		for n := 0; n < r.Len(); n++ {
@@ -226,9 +228,9 @@ func toXml(v interface{}, typ bool) (s string) {
 		s += "</data></array>"
 		return s
 	case reflect.Chan:
-		xerror.Panic("unsupported type")
+		xerror.Panic(errUnsupportedType)
 	case reflect.Func:
-		xerror.Panic("unsupported type")
+		xerror.Panic(errUnsupportedType)
 	case reflect.Interface:
 		return toXml(r.Elem(), typ)
 	case reflect.Map:
@@ -242,7 +244,7 @@ func toXml(v interface{}, typ bool) (s string) {
 		s += "</struct>"
 		return s
 	case reflect.Ptr:
-		xerror.Panic("unsupported type")
+		xerror.Panic(errUnsupportedType)
 	case reflect.Slice:
 		s = "<array><data>"
 		for n := 0; n < r.Len(); n++ {
@@ -294,6 +296,11 @@ func NewClient(url string) *Client {
 	}
 }
 
+// SetTransport exposes the ability to define custom transports, e.g. breaker.Transport
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.HttpClient.Transport = transport
+}
+
 func makeRequest(name string, args ...interface{}) *bytes.Buffer {
 	buf := new(bytes.Buffer)
 	buf.WriteString(`<?xml version="1.0"?><methodCall>`)
@@ -317,20 +324,20 @@ func call(client *http.Client, url, name string, args ...interface{}) (v interfa
 	defer io.Copy(ioutil.Discard, r.Body)
 	defer r.Body.Close()
 
-	xerror.PanicT(r.StatusCode/100 != 2, http.StatusText(http.StatusBadRequest))
+	xerror.Assert(r.StatusCode/100 != 2, http.StatusText(http.StatusBadRequest))
 
 	p := xml.NewDecoder(r.Body)
 	se := xerror.PanicErr(nextStart(p)).(xml.StartElement) // methodResponse
-	xerror.PanicT(se.Name.Local != "methodResponse", "invalid response: missing methodResponse")
+	xerror.Assert(se.Name.Local != "methodResponse", "invalid response: missing methodResponse")
 
 	se = xerror.PanicErr(nextStart(p)).(xml.StartElement)
-	xerror.PanicT(se.Name.Local != "params", "invalid response: missing params")
+	xerror.Assert(se.Name.Local != "params", "invalid response: missing params")
 
 	se = xerror.PanicErr(nextStart(p)).(xml.StartElement)
-	xerror.PanicT(se.Name.Local != "param", "invalid response: missing param")
+	xerror.Assert(se.Name.Local != "param", "invalid response: missing param")
 
 	se = xerror.PanicErr(nextStart(p)).(xml.StartElement)
-	xerror.PanicT(se.Name.Local != "value", "invalid response: missing value")
+	xerror.Assert(se.Name.Local != "value", "invalid response: missing value")
 
 	_, v, e = next(p)
 	return v, e
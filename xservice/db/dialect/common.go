@@ -1,6 +1,9 @@
 package dialect
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type commonDialect struct {
 	delimiter string
@@ -47,3 +50,51 @@ func (c commonDialect) ShowTables() string {
 func (c commonDialect) GetDelimiter() string {
 	return c.delimiter
 }
+
+func (c commonDialect) Quote(ident string) string {
+	return c.delimiter + strings.Replace(ident, c.delimiter, c.delimiter+c.delimiter, -1) + c.delimiter
+}
+
+func (c commonDialect) Placeholder(n int) string { return "?" }
+
+func (c commonDialect) ForceIndex(index string) string { return "" }
+
+func (c commonDialect) Paginate(limit, offset string, ordered bool) (string, string) {
+	var suffix []string
+	if limit != "" {
+		suffix = append(suffix, "LIMIT "+limit)
+	}
+
+	if offset != "" {
+		suffix = append(suffix, "OFFSET "+offset)
+	}
+	return "", strings.Join(suffix, " ")
+}
+
+func (c commonDialect) Returning(cols []string) (string, string) { return "", "" }
+
+func (c commonDialect) Upsert(conflict, update []string) string { return "" }
+
+func (c commonDialect) MaxParams() int { return 65535 }
+
+// onConflict the upsert of postgresql and sqlite, conflict is required
+func (c commonDialect) onConflict(d Dialect, conflict, update []string) string {
+	if len(conflict) == 0 {
+		return ""
+	}
+
+	var cols = make([]string, len(conflict))
+	for i, col := range conflict {
+		cols[i] = d.Quote(col)
+	}
+
+	if len(update) == 0 {
+		return "ON CONFLICT (" + strings.Join(cols, ",") + ") DO NOTHING"
+	}
+
+	var sets = make([]string, len(update))
+	for i, col := range update {
+		sets[i] = d.Quote(col) + "=excluded." + d.Quote(col)
+	}
+	return "ON CONFLICT (" + strings.Join(cols, ",") + ") DO UPDATE SET " + strings.Join(sets, ",")
+}
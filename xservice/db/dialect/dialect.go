@@ -6,6 +6,7 @@ package dialect
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 // Dialect is methods set of different driver.
@@ -33,6 +34,31 @@ type Dialect interface {
 
 	// GetDelimiter return the delimiter of Dialect.
 	GetDelimiter() string
+
+	// Quote quotes a table or column name.
+	Quote(ident string) string
+
+	// Placeholder returns the placeholder of the nth argument, n starts from 1.
+	Placeholder(n int) string
+
+	// ForceIndex returns the clause after the table name that forces an index, or empty if not supported.
+	ForceIndex(index string) string
+
+	// Paginate returns the prefix after SELECT and the suffix after ORDER BY,
+	// ordered reports whether the statement has an ORDER BY.
+	Paginate(limit, offset string, ordered bool) (prefix, suffix string)
+
+	// Returning returns the output before VALUES and the suffix at the end of an insert
+	// that return cols, both are empty if not supported.
+	Returning(cols []string) (output, suffix string)
+
+	// Upsert returns the clause after VALUES that updates the update columns when a row conflicts
+	// with the unique index of conflict, nothing is updated if update is empty.
+	// It returns empty if not supported.
+	Upsert(conflict, update []string) string
+
+	// MaxParams returns the max number of arguments of a statement.
+	MaxParams() int
 }
 
 // GetDialect return the default Dialect.
@@ -40,22 +66,22 @@ func GetDialect() Dialect {
 	return GetDialectByDriver("mysql")
 }
 
-// GetDialectByDriver return the Dialect of given driver.
+// GetDialectByDriver return the Dialect of given driver, the database/sql driver names are accepted too.
 func GetDialectByDriver(driver string) Dialect {
-	switch driver {
+	switch strings.ToLower(driver) {
 	case "mysql":
 		return mysql{
 			commonDialect: commonDialect{delimiter: "`"},
 		}
-	case "mssql":
+	case "mssql", "sqlserver":
 		return mssql{
 			commonDialect: commonDialect{delimiter: "`"},
 		}
-	case "postgresql":
+	case "postgresql", "postgres", "pgx", "cloudsqlpostgres":
 		return postgresql{
 			commonDialect: commonDialect{delimiter: `"`},
 		}
-	case "sqlite":
+	case "sqlite", "sqlite3":
 		return sqlite{
 			commonDialect: commonDialect{delimiter: "`"},
 		}
@@ -256,3 +282,69 @@ func (sql *SQLComponent) prepareInsert(delimiter string) {
 
 	sql.Statement = "insert into " + sql.TableName + fields + " values " + quesMark
 }
+
+// Rebind replaces the ? placeholders of query with the placeholders of d.
+// Quoted strings, quoted identifiers and comments are kept as is, ?? is a literal ?,
+// for example the postgresql jsonb operators ??, ??| and ??&.
+func Rebind(d Dialect, query string) string {
+	if d.Placeholder(1) == "?" {
+		return query
+	}
+
+	var buf strings.Builder
+	var n int
+	for i := 0; i < len(query); {
+		var c = query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			var end = quoteEnd(query, i+1, c)
+			buf.WriteString(query[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			var end = strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			buf.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			var end = strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			buf.WriteString(query[i : i+end])
+			i += end
+		case c == '?' && strings.HasPrefix(query[i:], "??"):
+			buf.WriteByte('?')
+			i += 2
+		case c == '?':
+			n++
+			buf.WriteString(d.Placeholder(n))
+			i++
+		default:
+			_, size := utf8.DecodeRuneInString(query[i:])
+			buf.WriteString(query[i : i+size])
+			i += size
+		}
+	}
+	return buf.String()
+}
+
+// quoteEnd returns the end of the quoted region that starts before i, a doubled quote is an escaped quote
+func quoteEnd(query string, i int, quote byte) int {
+	for i < len(query) {
+		if query[i] != quote {
+			i++
+			continue
+		}
+
+		if i+1 < len(query) && query[i+1] == quote {
+			i += 2
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
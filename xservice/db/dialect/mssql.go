@@ -4,6 +4,11 @@
 
 package dialect
 
+import (
+	"strconv"
+	"strings"
+)
+
 type mssql struct {
 	commonDialect
 }
@@ -11,3 +16,49 @@ type mssql struct {
 func (mssql) GetName() string {
 	return "mssql"
 }
+
+// Quote mssql quotes names with brackets
+func (mssql) Quote(ident string) string {
+	return "[" + strings.Replace(ident, "]", "]]", -1) + "]"
+}
+
+func (mssql) Placeholder(n int) string { return "@p" + strconv.Itoa(n) }
+
+// MaxParams mssql accepts at most 2100 arguments in a request
+func (mssql) MaxParams() int { return 2100 }
+
+func (d mssql) ForceIndex(index string) string {
+	return "WITH (INDEX(" + d.Quote(index) + "))"
+}
+
+// Paginate TOP is used when there is only limit, otherwise OFFSET FETCH which requires ORDER BY
+func (d mssql) Paginate(limit, offset string, ordered bool) (string, string) {
+	if offset == "" {
+		if limit == "" {
+			return "", ""
+		}
+		return "TOP " + limit + " ", ""
+	}
+
+	var suffix = "OFFSET " + offset + " ROWS"
+	if limit != "" {
+		suffix += " FETCH NEXT " + limit + " ROWS ONLY"
+	}
+
+	if !ordered {
+		suffix = "ORDER BY (SELECT NULL) " + suffix
+	}
+	return "", suffix
+}
+
+func (d mssql) Returning(cols []string) (string, string) {
+	if len(cols) == 0 {
+		return "", ""
+	}
+
+	var inserted = make([]string, len(cols))
+	for i, c := range cols {
+		inserted[i] = "INSERTED." + d.Quote(c)
+	}
+	return "OUTPUT " + strings.Join(inserted, ","), ""
+}
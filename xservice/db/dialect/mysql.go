@@ -4,6 +4,8 @@
 
 package dialect
 
+import "strings"
+
 type mysql struct {
 	commonDialect
 }
@@ -19,3 +21,33 @@ func (mysql) ShowColumns(table string) string {
 func (mysql) ShowTables() string {
 	return "show tables"
 }
+
+func (d mysql) ForceIndex(index string) string {
+	return "FORCE INDEX (" + d.Quote(index) + ")"
+}
+
+// Upsert mysql detects conflicts by the primary key and unique indexes, conflict is not needed
+func (d mysql) Upsert(conflict, update []string) string {
+	if len(update) == 0 {
+		if len(conflict) == 0 {
+			return ""
+		}
+
+		// nothing to update, set the column to itself to ignore the conflicting rows
+		return "ON DUPLICATE KEY UPDATE " + d.Quote(conflict[0]) + "=" + d.Quote(conflict[0])
+	}
+
+	var sets = make([]string, len(update))
+	for i, c := range update {
+		sets[i] = d.Quote(c) + "=VALUES(" + d.Quote(c) + ")"
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ",")
+}
+
+// Paginate OFFSET requires LIMIT in mysql
+func (d mysql) Paginate(limit, offset string, ordered bool) (string, string) {
+	if limit == "" && offset != "" {
+		limit = "18446744073709551615"
+	}
+	return d.commonDialect.Paginate(limit, offset, ordered)
+}
@@ -4,6 +4,11 @@
 
 package dialect
 
+import (
+	"strconv"
+	"strings"
+)
+
 type postgresql struct {
 	commonDialect
 }
@@ -15,3 +20,21 @@ func (postgresql) GetName() string {
 func (postgresql) ShowTables() string {
 	return "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname != 'pg_catalog' AND schemaname != 'information_schema';"
 }
+
+func (postgresql) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (d postgresql) Upsert(conflict, update []string) string {
+	return d.onConflict(d, conflict, update)
+}
+
+func (d postgresql) Returning(cols []string) (string, string) {
+	if len(cols) == 0 {
+		return "", ""
+	}
+
+	var quoted = make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = d.Quote(c)
+	}
+	return "", "RETURNING " + strings.Join(quoted, ",")
+}
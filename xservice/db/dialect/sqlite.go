@@ -19,3 +19,19 @@ func (sqlite) ShowColumns(table string) string {
 func (sqlite) ShowTables() string {
 	return "SELECT name as tablename FROM sqlite_master WHERE type ='table'"
 }
+
+// Upsert ON CONFLICT is supported since sqlite 3.24
+func (d sqlite) Upsert(conflict, update []string) string {
+	return d.onConflict(d, conflict, update)
+}
+
+// MaxParams SQLITE_MAX_VARIABLE_NUMBER defaults to 999 before sqlite 3.32
+func (d sqlite) MaxParams() int { return 999 }
+
+// Paginate OFFSET requires LIMIT in sqlite, LIMIT -1 means no limit
+func (d sqlite) Paginate(limit, offset string, ordered bool) (string, string) {
+	if limit == "" && offset != "" {
+		limit = "-1"
+	}
+	return d.commonDialect.Paginate(limit, offset, ordered)
+}
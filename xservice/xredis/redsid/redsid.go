@@ -1,6 +1,7 @@
 package redsid
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-redis/redis/v7"
 	"github.com/pubgo/x/retry"
@@ -41,16 +42,23 @@ type Cfg struct {
 }
 
 // 检查name 是否存在, 不存在则设置时间
+// redis 出错时按照 RetryTime 重试, 重试失败返回 false
 func (t *Cfg) checkName(name string, id int) (ok bool) {
-	retry.Retry(time.Second, func(u uint, duration time.Duration) {
+	err := retry.Do(context.Background(), func(ctx context.Context) error {
 		_ok, err := t.client.SetNX(name, id, t.ExpiredTime).Result()
 		if err == redis.Nil {
 			err = nil
 		}
-		xerror.PanicM(err, "redis SetNX error, params(%s,%d)", name, id)
+		xerror.PanicF(err, "redis SetNX error, params(%s,%d)", name, id)
 		ok = _ok
-	})
-	return
+		return nil
+	},
+		retry.WithMaxElapsed(t.RetryTime),
+		retry.WithStrategy(retry.BinaryExponential(time.Millisecond*50, time.Second*2)),
+		retry.WithJitter(retry.FullJitter()),
+		retry.WithBudget(retry.DefaultBudget),
+	)
+	return err == nil && ok
 }
 
 func (t *Cfg) Start() {
@@ -1,29 +1,157 @@
 package abc
 
-import "time"
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
 
-type IStorage interface {
-	PutObject(filename string, value []byte) error
-	GetObject(filename string) ([]byte, error)
-	GetStoragePath(filename string) string
-}
+var (
+	ErrNotFound     = errors.New("storage: object not found")
+	ErrNotSupport   = errors.New("storage: not support")
+	ErrInvalidKey   = errors.New("storage: invalid key")
+	ErrInvalidRange = errors.New("storage: invalid range")
+)
 
+// Storage
+// 统一的对象存储接口, key 为 "/" 分隔的相对路径, 参考 ValidKey
+// 对象不存在时返回 ErrNotFound, 不支持的操作返回 ErrNotSupport
 type Storage interface {
-	Save(filepath string, file File) error
-	Path(filepath string) string
-	Exists(filepath string) bool
-	Delete(filepath string) error
-	Open(filepath string) (File, error)
-	ModifiedTime(filepath string) (time.Time, error)
-	Size(filepath string) int64
-	URL(filename string) string
-	HasBaseURL() bool
-	IsNotExist(err error) bool
-}
-
-type File interface {
-	Size() int64
-	Read(b []byte) (int, error)
-	ReadAll() ([]byte, error)
-	Close() error
+	// Put 写入 r 中的所有数据, 覆盖已经存在的对象
+	Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) (*ObjectInfo, error)
+
+	// Get 读取对象, 通过 WithRange 读取部分数据, 返回的 ObjectInfo.Size 为整个对象的大小
+	Get(ctx context.Context, key string, opts ...GetOption) (io.ReadCloser, *ObjectInfo, error)
+
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// List 按照 key 的字典序列出以 prefix 开头的对象, cursor 为上一次返回的 ListResult.Cursor
+	// limit <= 0 时由实现决定每次返回的数量
+	List(ctx context.Context, prefix, cursor string, limit int) (*ListResult, error)
+
+	// Delete 对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+
+	// Copy 复制对象, 包括 ContentType 和 Metadata
+	Copy(ctx context.Context, src, dst string) error
+}
+
+// ObjectInfo 对象的属性
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ETag        string
+	ModTime     time.Time
+	Metadata    map[string]string
+}
+
+// ListResult Cursor 为空时表示没有更多的对象
+type ListResult struct {
+	Objects []ObjectInfo
+	Cursor  string
+}
+
+// PutOptions Put 的配置
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
+
+type PutOption func(opts *PutOptions)
+
+// WithContentType 默认根据 key 的扩展名推断
+func WithContentType(contentType string) PutOption {
+	return func(opts *PutOptions) {
+		opts.ContentType = contentType
+	}
+}
+
+// WithMetadata 自定义的元数据, key 不区分大小写, 统一转换为小写
+func WithMetadata(key, value string) PutOption {
+	return func(opts *PutOptions) {
+		if opts.Metadata == nil {
+			opts.Metadata = make(map[string]string)
+		}
+		opts.Metadata[strings.ToLower(key)] = value
+	}
+}
+
+// NewPutOptions 合并 opts, 并且设置默认的 ContentType
+func NewPutOptions(key string, opts ...PutOption) PutOptions {
+	var _opts PutOptions
+	for _, opt := range opts {
+		opt(&_opts)
+	}
+
+	if _opts.ContentType == "" {
+		_opts.ContentType = ContentType(key)
+	}
+	return _opts
+}
+
+// GetOptions Get 的配置
+type GetOptions struct {
+	Offset int64
+	// Length < 0 表示读取到结尾
+	Length int64
+}
+
+type GetOption func(opts *GetOptions)
+
+// WithRange 从 offset 开始读取 length 个字节, length < 0 表示读取到结尾
+func WithRange(offset, length int64) GetOption {
+	return func(opts *GetOptions) {
+		opts.Offset = offset
+		opts.Length = length
+	}
+}
+
+// NewGetOptions 合并 opts, 默认读取整个对象
+func NewGetOptions(opts ...GetOption) GetOptions {
+	var _opts = GetOptions{Length: -1}
+	for _, opt := range opts {
+		opt(&_opts)
+	}
+	return _opts
+}
+
+// Range 根据对象的大小计算读取的范围 [start, end), offset 超出对象大小时返回 ErrInvalidRange
+func (opts GetOptions) Range(size int64) (start, end int64, err error) {
+	if opts.Offset < 0 || (opts.Offset > 0 && opts.Offset >= size) {
+		return 0, 0, ErrInvalidRange
+	}
+
+	end = size
+	if opts.Length >= 0 && opts.Offset+opts.Length < size {
+		end = opts.Offset + opts.Length
+	}
+	return opts.Offset, end, nil
+}
+
+// ValidKey
+// key 不能为空, 不能以 "/" 开头或者结尾, 不能包含空的路径, "." 和 "..", 以及 "\" 和 NUL
+func ValidKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return ErrInvalidKey
+	}
+
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// ContentType 根据 key 的扩展名推断 ContentType, 无法推断时为 application/octet-stream
+func ContentType(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
 }
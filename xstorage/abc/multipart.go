@@ -0,0 +1,31 @@
+package abc
+
+import "context"
+
+// Part 已经上传的分片, Number 从 1 开始
+type Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// Multipart
+// 分片上传, 分片可以并发上传, 上传失败之后通过 uploadID 继续上传
+// CompleteMultipart 按照 Number 的顺序合并 parts, 之前上传的同名对象被覆盖
+type Multipart interface {
+	InitMultipart(ctx context.Context, key string, opts PutOptions) (uploadID string, err error)
+
+	// UploadPart 上传分片, 返回分片的 ETag, 重复上传同一个分片时覆盖之前的数据
+	UploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (etag string, err error)
+
+	CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error
+
+	// AbortMultipart 取消上传, 删除已经上传的分片
+	AbortMultipart(ctx context.Context, key, uploadID string) error
+}
+
+// MultipartStorage 支持分片上传的存储
+type MultipartStorage interface {
+	Storage
+	Multipart
+}
@@ -0,0 +1,178 @@
+// Package alioss 基于 oss.Bucket 的阿里云对象存储
+package alioss
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+var _ abc.Storage = (*OSS)(nil)
+
+const ossMetaPrefix = "X-Oss-Meta-"
+
+// New 只依赖 oss.Bucket, 客户端的创建和配置由调用方负责
+func New(bucket *oss.Bucket) *OSS {
+	return &OSS{bucket: bucket}
+}
+
+type OSS struct {
+	bucket *oss.Bucket
+}
+
+// convertErr 404 转换为 abc.ErrNotFound, 416 转换为 abc.ErrInvalidRange
+func convertErr(key string, err error) error {
+	if se, ok := err.(oss.ServiceError); ok {
+		switch se.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("oss: %s: %w", key, abc.ErrNotFound)
+		case http.StatusRequestedRangeNotSatisfiable:
+			return fmt.Errorf("oss: %s: %w", key, abc.ErrInvalidRange)
+		}
+	}
+	return err
+}
+
+func (s *OSS) Put(ctx context.Context, key string, r io.Reader, opts ...abc.PutOption) (*abc.ObjectInfo, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var _opts = abc.NewPutOptions(key, opts...)
+	var options = []oss.Option{oss.ContentType(_opts.ContentType)}
+	for k, v := range _opts.Metadata {
+		options = append(options, oss.Meta(k, v))
+	}
+
+	if err := s.bucket.PutObject(key, r, options...); err != nil {
+		return nil, convertErr(key, err)
+	}
+	return s.Stat(ctx, key)
+}
+
+// Get 先读取对象的属性计算范围, 再读取数据
+func (s *OSS) Get(ctx context.Context, key string, opts ...abc.GetOption) (io.ReadCloser, *abc.ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, end, err := abc.NewGetOptions(opts...).Range(info.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if start == end {
+		return ioutil.NopCloser(strings.NewReader("")), info, nil
+	}
+
+	r, err := s.bucket.GetObject(key, oss.Range(start, end-1))
+	if err != nil {
+		return nil, nil, convertErr(key, err)
+	}
+	return r, info, nil
+}
+
+func (s *OSS) Stat(ctx context.Context, key string) (*abc.ObjectInfo, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	header, err := s.bucket.GetObjectDetailedMeta(key)
+	if err != nil {
+		return nil, convertErr(key, err)
+	}
+
+	size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	modTime, _ := http.ParseTime(header.Get("Last-Modified"))
+
+	var info = &abc.ObjectInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: header.Get("Content-Type"),
+		ETag:        strings.Trim(header.Get("ETag"), `"`),
+		ModTime:     modTime,
+	}
+
+	for k := range header {
+		if strings.HasPrefix(k, ossMetaPrefix) {
+			if info.Metadata == nil {
+				info.Metadata = make(map[string]string)
+			}
+			info.Metadata[strings.ToLower(strings.TrimPrefix(k, ossMetaPrefix))] = header.Get(k)
+		}
+	}
+	return info, nil
+}
+
+// List cursor 为 oss 的 marker
+func (s *OSS) List(ctx context.Context, prefix, cursor string, limit int) (*abc.ListResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var options = []oss.Option{oss.Prefix(prefix), oss.Marker(cursor)}
+	if limit > 0 {
+		options = append(options, oss.MaxKeys(limit))
+	}
+
+	res, err := s.bucket.ListObjects(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = &abc.ListResult{}
+	if res.IsTruncated {
+		result.Cursor = res.NextMarker
+	}
+
+	for _, obj := range res.Objects {
+		result.Objects = append(result.Objects, abc.ObjectInfo{
+			Key:     obj.Key,
+			Size:    obj.Size,
+			ETag:    strings.Trim(obj.ETag, `"`),
+			ModTime: obj.LastModified,
+		})
+	}
+	return result, nil
+}
+
+func (s *OSS) Delete(ctx context.Context, key string) error {
+	if err := abc.ValidKey(key); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return convertErr(key, s.bucket.DeleteObject(key))
+}
+
+// Copy oss 默认复制 ContentType 和 Metadata
+func (s *OSS) Copy(ctx context.Context, src, dst string) error {
+	if err := abc.ValidKey(dst); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := s.bucket.CopyObject(src, dst)
+	return convertErr(src, err)
+}
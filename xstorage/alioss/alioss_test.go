@@ -0,0 +1,257 @@
+package alioss_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pubgo/x/xstorage/abc"
+	"github.com/pubgo/x/xstorage/alioss"
+	"github.com/pubgo/x/xstorage/storagetest"
+	"github.com/pubgo/x/xstorage/upload"
+)
+
+type fakeObject struct {
+	data    []byte
+	etag    string
+	header  http.Header
+	modTime time.Time
+}
+
+// fakeOSS 不验证签名的 OSS 服务, 只支持 path style
+type fakeOSS struct {
+	bucket string
+
+	mu      sync.Mutex
+	nextID  int
+	objects map[string]*fakeObject
+	uploads map[string]map[int][]byte
+	headers map[string]http.Header
+}
+
+func newFakeOSS() *fakeOSS {
+	return &fakeOSS{
+		bucket:  "bucket",
+		objects: make(map[string]*fakeObject),
+		uploads: make(map[string]map[int][]byte),
+		headers: make(map[string]http.Header),
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeOSS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	var path = strings.TrimPrefix(r.URL.Path, "/")
+	if path != f.bucket && !strings.HasPrefix(path, f.bucket+"/") {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	var key = strings.TrimPrefix(strings.TrimPrefix(path, f.bucket), "/")
+	var query = r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, query)
+	case r.Method == http.MethodPost && query["uploads"] != nil:
+		f.nextID++
+		var id = strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		f.headers[id] = objectHeader(r.Header)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", f.bucket, key, id)
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%X"`, md5.Sum(body)))
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		f.complete(w, key, query.Get("uploadId"), body)
+	case r.Method == http.MethodDelete && query.Get("uploadId") != "":
+		delete(f.uploads, query.Get("uploadId"))
+		delete(f.headers, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Oss-Copy-Source") != "":
+		src, _ := url.QueryUnescape(strings.TrimPrefix(r.Header.Get("X-Oss-Copy-Source"), "/"+f.bucket+"/"))
+		obj, ok := f.objects[src]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.objects[key] = &fakeObject{data: obj.data, etag: obj.etag, header: obj.header, modTime: time.Now()}
+		fmt.Fprintf(w, "<CopyObjectResult><ETag>\"%s\"</ETag></CopyObjectResult>", obj.etag)
+	case r.Method == http.MethodPut:
+		f.objects[key] = &fakeObject{data: body, etag: fmt.Sprintf("%X", md5.Sum(body)), header: objectHeader(r.Header), modTime: time.Now()}
+		w.Header().Set("ETag", `"`+f.objects[key].etag+`"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", `"`+obj.etag+`"`)
+		http.ServeContent(w, r, "", obj.modTime, bytes.NewReader(obj.data))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func objectHeader(header http.Header) http.Header {
+	var h = http.Header{}
+	for k, v := range header {
+		if k == "Content-Type" || strings.HasPrefix(k, "X-Oss-Meta-") {
+			h[k] = v
+		}
+	}
+	return h
+}
+
+func (f *fakeOSS) complete(w http.ResponseWriter, key, id string, body []byte) {
+	parts, ok := f.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	var req struct {
+		Parts []struct {
+			Number int    `xml:"PartNumber"`
+			ETag   string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	var data []byte
+	var sums = md5.New()
+	for i, p := range req.Parts {
+		part, ok := parts[p.Number]
+		var sum = md5.Sum(part)
+		if !ok || p.Number != i+1 || p.ETag != fmt.Sprintf(`"%X"`, sum) {
+			writeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		data = append(data, part...)
+		sums.Write(sum[:])
+	}
+
+	var etag = fmt.Sprintf("%X-%d", sums.Sum(nil), len(req.Parts))
+	f.objects[key] = &fakeObject{data: data, etag: etag, header: f.headers[id], modTime: time.Now()}
+	delete(f.uploads, id)
+	delete(f.headers, id)
+	fmt.Fprintf(w, "<CompleteMultipartUploadResult><ETag>\"%s\"</ETag></CompleteMultipartUploadResult>", etag)
+}
+
+// list 请求带 encoding-type=url, 返回的 key 和 marker 需要编码
+func (f *fakeOSS) list(w http.ResponseWriter, query url.Values) {
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, query.Get("prefix")) && k > query.Get("marker") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var limit = 1000
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil {
+		limit = n
+	}
+
+	var res = "<ListBucketResult>"
+	if len(keys) > limit {
+		keys = keys[:limit]
+		res += "<IsTruncated>true</IsTruncated><NextMarker>" + url.QueryEscape(keys[limit-1]) + "</NextMarker>"
+	}
+	for _, k := range keys {
+		res += fmt.Sprintf("<Contents><Key>%s</Key><LastModified>%s</LastModified><ETag>\"%s\"</ETag><Size>%d</Size></Contents>",
+			url.QueryEscape(k), f.objects[k].modTime.UTC().Format(time.RFC3339), f.objects[k].etag, len(f.objects[k].data))
+	}
+	fmt.Fprint(w, res+"</ListBucketResult>")
+}
+
+func newTestOSS(t *testing.T) (*alioss.OSS, *fakeOSS) {
+	var fake = newFakeOSS()
+	var server = httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	// fake 不返回 crc64, 关闭客户端的校验
+	client, err := oss.New(server.URL, "ak", "sk", oss.EnableCRC(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket, err := client.Bucket(fake.bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alioss.New(bucket), fake
+}
+
+func TestOSS(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) abc.Storage {
+		s, _ := newTestOSS(t)
+		return s
+	})
+}
+
+func TestOSSUpload(t *testing.T) {
+	var ctx = context.Background()
+	s, fake := newTestOSS(t)
+
+	var data = bytes.Repeat([]byte("0123456789"), 10000)
+	info, err := upload.New(s, upload.WithPartSize(8<<10), upload.WithParallel(3)).Upload(ctx, "big", bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size != int64(len(data)) || !strings.HasSuffix(info.ETag, "-13") {
+		t.Fatalf("%+v", info)
+	}
+
+	r, _, err := s.Get(ctx, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	got, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(got, data) || len(fake.uploads) != 0 {
+		t.Fatalf("data mismatch or %d uploads left", len(fake.uploads))
+	}
+}
@@ -0,0 +1,67 @@
+package alioss
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+var _ abc.Multipart = (*OSS)(nil)
+
+func (s *OSS) imur(key, uploadID string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{Bucket: s.bucket.BucketName, Key: key, UploadID: uploadID}
+}
+
+func (s *OSS) InitMultipart(ctx context.Context, key string, opts abc.PutOptions) (string, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return "", err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var options = []oss.Option{oss.ContentType(opts.ContentType)}
+	for k, v := range opts.Metadata {
+		options = append(options, oss.Meta(k, v))
+	}
+
+	imur, err := s.bucket.InitiateMultipartUpload(key, options...)
+	if err != nil {
+		return "", convertErr(key, err)
+	}
+	return imur.UploadID, nil
+}
+
+func (s *OSS) UploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	part, err := s.bucket.UploadPart(s.imur(key, uploadID), bytes.NewReader(data), int64(len(data)), number)
+	if err != nil {
+		return "", convertErr(key, err)
+	}
+	return strings.Trim(part.ETag, `"`), nil
+}
+
+func (s *OSS) CompleteMultipart(ctx context.Context, key, uploadID string, parts []abc.Part) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var _parts = make([]oss.UploadPart, len(parts))
+	for i, p := range parts {
+		_parts[i] = oss.UploadPart{PartNumber: p.Number, ETag: `"` + strings.Trim(p.ETag, `"`) + `"`}
+	}
+
+	_, err := s.bucket.CompleteMultipartUpload(s.imur(key, uploadID), _parts)
+	return convertErr(key, err)
+}
+
+func (s *OSS) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	return convertErr(key, s.bucket.AbortMultipartUpload(s.imur(key, uploadID)))
+}
@@ -0,0 +1,399 @@
+// Package cas 基于 abc.Storage 的内容寻址存储
+// 相同内容的对象按照 sha256 只保存一次, key 映射到内容的 digest, 通过引用计数回收没有引用的内容
+//
+// 存储中的布局
+//
+//	<prefix>/blobs/<digest[:2]>/<digest>  内容
+//	<prefix>/refs/<digest>                引用计数
+//	<prefix>/keys/<key>                   key 对应的 digest
+//	<prefix>/tmp/<random>                 写入过程中的临时对象
+//
+// 引用计数的修改在进程内加锁, 多个进程不能同时修改同一个存储
+package cas
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+const (
+	defaultPrefix      = "cas"
+	defaultGracePeriod = time.Hour
+)
+
+// ErrInvalidDigest digest 不是 64 位的小写 sha256
+var ErrInvalidDigest = errors.New("cas: invalid digest")
+
+// Option CAS 配置
+type Option func(c *CAS)
+
+// WithPrefix 存储中的目录, 默认为 cas
+func WithPrefix(prefix string) Option {
+	return func(c *CAS) {
+		c.prefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithGracePeriod
+// GC 只回收修改时间在 grace 之前的内容, 避免回收刚刚 Put 还没有 Link 的内容, 默认为 1 小时
+func WithGracePeriod(grace time.Duration) Option {
+	return func(c *CAS) {
+		c.grace = grace
+	}
+}
+
+// New storage 可以是任意的 abc.Storage
+func New(storage abc.Storage, opts ...Option) *CAS {
+	var c = &CAS{storage: storage, prefix: defaultPrefix, grace: defaultGracePeriod, pending: make(map[string]int)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type CAS struct {
+	storage abc.Storage
+	prefix  string
+	grace   time.Duration
+
+	// mu 保护引用计数, key 的映射和 pending
+	mu sync.Mutex
+
+	// pending 正在 Put 的 digest, GC 跳过这些内容
+	pending map[string]int
+}
+
+// ValidDigest 检查 digest 是否为 sha256 的小写十六进制
+func ValidDigest(digest string) error {
+	if len(digest) != sha256.Size*2 || strings.ToLower(digest) != digest {
+		return ErrInvalidDigest
+	}
+
+	if _, err := hex.DecodeString(digest); err != nil {
+		return ErrInvalidDigest
+	}
+	return nil
+}
+
+func (c *CAS) blobKey(digest string) string {
+	return c.prefix + "/blobs/" + digest[:2] + "/" + digest
+}
+
+func (c *CAS) refKey(digest string) string {
+	return c.prefix + "/refs/" + digest
+}
+
+func (c *CAS) keyKey(key string) string {
+	return c.prefix + "/keys/" + key
+}
+
+// Put
+// 写入内容, 返回内容的 digest, 相同的内容只保存一次
+// 返回的内容没有引用, 需要在 grace 之内通过 Link 引用, 否则会被 GC 回收
+func (c *CAS) Put(ctx context.Context, r io.Reader, opts ...abc.PutOption) (string, error) {
+	var id = make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	// 先写入临时对象, 计算出 digest 之后再复制
+	var tmp = c.prefix + "/tmp/" + hex.EncodeToString(id)
+	var h = sha256.New()
+	if _, err := c.storage.Put(ctx, tmp, io.TeeReader(r, h), opts...); err != nil {
+		_ = c.storage.Delete(context.Background(), tmp)
+		return "", err
+	}
+	defer c.storage.Delete(context.Background(), tmp)
+
+	// 内容已经存在时同样覆盖写入, 更新修改时间, 避免在 Link 之前被 GC 回收
+	// 复制期间不持有锁, 只标记为 pending, GC 不会回收正在写入的内容
+	var digest = hex.EncodeToString(h.Sum(nil))
+	c.mu.Lock()
+	c.pending[digest]++
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		if c.pending[digest]--; c.pending[digest] == 0 {
+			delete(c.pending, digest)
+		}
+		c.mu.Unlock()
+	}()
+
+	if err := c.storage.Copy(ctx, tmp, c.blobKey(digest)); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// PutKey 写入内容并且将 key 指向内容
+func (c *CAS) PutKey(ctx context.Context, key string, r io.Reader, opts ...abc.PutOption) (string, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return "", err
+	}
+
+	digest, err := c.Put(ctx, r, opts...)
+	if err != nil {
+		return "", err
+	}
+	return digest, c.Link(ctx, key, digest)
+}
+
+// Get 通过 digest 读取内容, 返回的 ObjectInfo.Key 为 digest
+func (c *CAS) Get(ctx context.Context, digest string, opts ...abc.GetOption) (io.ReadCloser, *abc.ObjectInfo, error) {
+	if err := ValidDigest(digest); err != nil {
+		return nil, nil, err
+	}
+
+	r, info, err := c.storage.Get(ctx, c.blobKey(digest), opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.Key = digest
+	return r, info, nil
+}
+
+// GetKey 通过 key 读取内容
+func (c *CAS) GetKey(ctx context.Context, key string, opts ...abc.GetOption) (io.ReadCloser, *abc.ObjectInfo, error) {
+	digest, err := c.Resolve(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, info, err := c.Get(ctx, digest, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.Key = key
+	return r, info, nil
+}
+
+// Resolve 返回 key 对应的 digest, key 不存在时返回 abc.ErrNotFound
+func (c *CAS) Resolve(ctx context.Context, key string) (string, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return "", err
+	}
+
+	data, err := c.read(ctx, c.keyKey(key))
+	if err != nil {
+		return "", err
+	}
+
+	var digest = string(data)
+	if err := ValidDigest(digest); err != nil {
+		return "", fmt.Errorf("cas: %s: %w", key, err)
+	}
+	return digest, nil
+}
+
+func (c *CAS) read(ctx context.Context, key string) ([]byte, error) {
+	r, _, err := c.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Refs 内容的引用计数
+func (c *CAS) Refs(ctx context.Context, digest string) (int64, error) {
+	if err := ValidDigest(digest); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refs(ctx, digest)
+}
+
+// refs 没有引用时不保存计数
+func (c *CAS) refs(ctx context.Context, digest string) (int64, error) {
+	data, err := c.read(ctx, c.refKey(digest))
+	if errors.Is(err, abc.ErrNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+func (c *CAS) addRefs(ctx context.Context, digest string, delta int64) error {
+	n, err := c.refs(ctx, digest)
+	if err != nil {
+		return err
+	}
+
+	if n += delta; n <= 0 {
+		return c.storage.Delete(ctx, c.refKey(digest))
+	}
+
+	_, err = c.storage.Put(ctx, c.refKey(digest), strings.NewReader(strconv.FormatInt(n, 10)), abc.WithContentType("text/plain"))
+	return err
+}
+
+// Link
+// 将 key 指向 digest 并且增加引用计数, key 之前指向的内容减少引用计数
+// 内容不存在时返回 abc.ErrNotFound
+func (c *CAS) Link(ctx context.Context, key, digest string) error {
+	if err := abc.ValidKey(key); err != nil {
+		return err
+	}
+
+	if err := ValidDigest(digest); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.storage.Stat(ctx, c.blobKey(digest)); err != nil {
+		return err
+	}
+
+	old, err := c.Resolve(ctx, key)
+	if err != nil && !errors.Is(err, abc.ErrNotFound) {
+		return err
+	}
+
+	if old == digest {
+		return nil
+	}
+
+	if err := c.addRefs(ctx, digest, 1); err != nil {
+		return err
+	}
+
+	if _, err := c.storage.Put(ctx, c.keyKey(key), strings.NewReader(digest), abc.WithContentType("text/plain")); err != nil {
+		_ = c.addRefs(ctx, digest, -1)
+		return err
+	}
+
+	if old != "" {
+		return c.addRefs(ctx, old, -1)
+	}
+	return nil
+}
+
+// Unlink 删除 key 并且减少引用计数, key 不存在时不返回错误
+func (c *CAS) Unlink(ctx context.Context, key string) error {
+	if err := abc.ValidKey(key); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	digest, err := c.Resolve(ctx, key)
+	if errors.Is(err, abc.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := c.storage.Delete(ctx, c.keyKey(key)); err != nil {
+		return err
+	}
+	return c.addRefs(ctx, digest, -1)
+}
+
+// GC
+// 删除没有引用并且修改时间在 grace 之前的内容, 以及遗留的临时对象
+// 返回删除的内容数量
+func (c *CAS) GC(ctx context.Context) (int, error) {
+	var deadline = time.Now().Add(-c.grace)
+
+	var removed int
+	err := c.walk(ctx, c.prefix+"/blobs/", func(obj abc.ObjectInfo) error {
+		if obj.ModTime.After(deadline) {
+			return nil
+		}
+
+		var digest = obj.Key[strings.LastIndex(obj.Key, "/")+1:]
+		if ValidDigest(digest) != nil {
+			return nil
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.pending[digest] > 0 {
+			return nil
+		}
+
+		n, err := c.refs(ctx, digest)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		// 列出之后可能被 Put 重新写入
+		info, err := c.storage.Stat(ctx, obj.Key)
+		if errors.Is(err, abc.ErrNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if info.ModTime.After(deadline) {
+			return nil
+		}
+
+		if err := c.storage.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, err
+	}
+
+	err = c.walk(ctx, c.prefix+"/tmp/", func(obj abc.ObjectInfo) error {
+		if obj.ModTime.After(deadline) {
+			return nil
+		}
+		return c.storage.Delete(ctx, obj.Key)
+	})
+	return removed, err
+}
+
+// walk 遍历 prefix 下的所有对象, 先列出所有对象再回调, 回调中可以删除对象
+func (c *CAS) walk(ctx context.Context, prefix string, fn func(obj abc.ObjectInfo) error) error {
+	var objects []abc.ObjectInfo
+	var cursor string
+	for {
+		res, err := c.storage.List(ctx, prefix, cursor, 0)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, res.Objects...)
+		if cursor = res.Cursor; cursor == "" {
+			break
+		}
+	}
+
+	for _, obj := range objects {
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
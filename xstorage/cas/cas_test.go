@@ -0,0 +1,224 @@
+package cas_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xstorage/abc"
+	"github.com/pubgo/x/xstorage/cas"
+	"github.com/pubgo/x/xstorage/memory"
+)
+
+func count(t *testing.T, s abc.Storage, prefix string) int {
+	t.Helper()
+
+	res, err := s.List(context.Background(), prefix, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(res.Objects)
+}
+
+func TestCAS(t *testing.T) {
+	var ctx = context.Background()
+	var s = memory.New()
+	var c = cas.New(s, cas.WithGracePeriod(0))
+
+	// 多个用户上传相同的图片
+	var digest string
+	for _, user := range []string{"alice", "bob", "carol"} {
+		d, err := c.PutKey(ctx, "avatar/"+user+".png", strings.NewReader("same image"), abc.WithContentType("image/png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest != "" && d != digest {
+			t.Fatal(d, digest)
+		}
+		digest = d
+	}
+
+	if err := cas.ValidDigest(digest); err != nil {
+		t.Fatal(digest)
+	}
+
+	if n := count(t, s, "cas/blobs/"); n != 1 {
+		t.Fatalf("blobs: %d", n)
+	}
+
+	if n, err := c.Refs(ctx, digest); err != nil || n != 3 {
+		t.Fatal(n, err)
+	}
+
+	r, info, err := c.GetKey(ctx, "avatar/bob.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(data) != "same image" || info.Key != "avatar/bob.png" || info.ContentType != "image/png" {
+		t.Fatalf("%q %+v", data, info)
+	}
+
+	// 重复 Link 不增加引用
+	if err := c.Link(ctx, "avatar/bob.png", digest); err != nil {
+		t.Fatal(err)
+	}
+
+	// key 指向新的内容
+	other, err := c.PutKey(ctx, "avatar/bob.png", strings.NewReader("new image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := c.Refs(ctx, digest); n != 2 {
+		t.Fatalf("refs: %d", n)
+	}
+
+	for _, key := range []string{"avatar/alice.png", "avatar/carol.png", "avatar/missing.png"} {
+		if err := c.Unlink(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Resolve(ctx, "avatar/alice.png"); !errors.Is(err, abc.ErrNotFound) {
+		t.Fatal(err)
+	}
+
+	removed, err := c.GC(ctx)
+	if err != nil || removed != 1 {
+		t.Fatal(removed, err)
+	}
+
+	if _, _, err := c.Get(ctx, digest); !errors.Is(err, abc.ErrNotFound) {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.Get(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(t, s, "cas/tmp/"); n != 0 {
+		t.Fatalf("tmp: %d", n)
+	}
+
+	if err := c.Link(ctx, "a", digest); !errors.Is(err, abc.ErrNotFound) {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.Get(ctx, "not-a-digest"); !errors.Is(err, cas.ErrInvalidDigest) {
+		t.Fatal(err)
+	}
+}
+
+func TestCASGracePeriod(t *testing.T) {
+	var ctx = context.Background()
+	var c = cas.New(memory.New(), cas.WithPrefix("/dedup/"), cas.WithGracePeriod(time.Hour))
+
+	// 没有引用, 但是还在 grace 之内
+	digest, err := c.Put(ctx, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, err := c.GC(ctx); err != nil || removed != 0 {
+		t.Fatal(removed, err)
+	}
+
+	if err := c.Link(ctx, "a", digest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCASPutExisting(t *testing.T) {
+	var ctx = context.Background()
+	var c = cas.New(memory.New(), cas.WithGracePeriod(50*time.Millisecond))
+
+	if _, err := c.Put(ctx, strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// 重复写入已经存在的内容, 重新计算 grace
+	digest, err := c.Put(ctx, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, err := c.GC(ctx); err != nil || removed != 0 {
+		t.Fatal(removed, err)
+	}
+
+	if err := c.Link(ctx, "a", digest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blockCopy Copy 时通知 started, 直到 release 关闭
+type blockCopy struct {
+	abc.Storage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockCopy) Copy(ctx context.Context, src, dst string) error {
+	close(b.started)
+	<-b.release
+	return b.Storage.Copy(ctx, src, dst)
+}
+
+func TestCASPutPending(t *testing.T) {
+	var ctx = context.Background()
+	var s = &blockCopy{Storage: memory.New(), started: make(chan struct{}), release: make(chan struct{})}
+	var c = cas.New(s.Storage, cas.WithGracePeriod(50*time.Millisecond))
+
+	digest, err := c.Put(ctx, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// 同一个 storage, 第二次 Put 在 Copy 中阻塞
+	var blocked = cas.New(s, cas.WithGracePeriod(50*time.Millisecond))
+	var done = make(chan error, 1)
+	go func() {
+		_, err := blocked.Put(ctx, strings.NewReader("data"))
+		done <- err
+	}()
+	<-s.started
+
+	// Copy 期间不持有锁, 并且 GC 跳过正在写入的内容
+	var gc = make(chan error, 1)
+	go func() {
+		removed, err := blocked.GC(ctx)
+		if err == nil && removed != 0 {
+			err = fmt.Errorf("removed %d", removed)
+		}
+		gc <- err
+	}()
+
+	select {
+	case err := <-gc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("GC blocks while Put is copying")
+	}
+
+	if err := blocked.Link(ctx, "a", digest); err != nil {
+		t.Fatal(err)
+	}
+
+	close(s.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := c.Refs(ctx, digest); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+}
@@ -1,77 +1,571 @@
 package local
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pubgo/x/xstorage/abc"
 )
 
-type StorageLocal struct {
-	savePath string
+var _ abc.Storage = (*Local)(nil)
+
+const (
+	// metaDir 保存 ContentType, Metadata 和校验和
+	metaDir = ".meta"
+	// tmpPrefix 写入过程中的临时文件
+	tmpPrefix = ".tmp-"
+	// uploadDir 分片上传过程中的分片
+	uploadDir = ".uploads"
+
+	defaultListLimit = 1000
+
+	// lockStripes key 锁的分段数
+	lockStripes = 64
+)
+
+// ErrChecksum 文件内容和写入时的校验和不一致
+var ErrChecksum = errors.New("storage: checksum mismatch")
+
+type meta struct {
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	SHA256      string            `json:"sha256"`
 }
 
-var localObject *StorageLocal
+// Option Local 配置
+type Option func(l *Local)
 
-func GetStorageLocal() (*StorageLocal, error) {
-	if localObject == nil {
-		err := LocalInit()
-		if err != nil {
-			return nil, err
+// WithBaseURL SignURL 生成的地址前缀, 例如 https://example.com/files
+func WithBaseURL(baseURL string) Option {
+	return func(l *Local) {
+		l.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithSecret SignURL 的签名密钥
+func WithSecret(secret []byte) Option {
+	return func(l *Local) {
+		l.secret = secret
+	}
+}
+
+// New
+// 以 root 为根目录的本地存储, key 不能访问 root 之外的文件
+// 通过临时文件和 rename 写入, 读取时不会看到写入一半的文件
+func New(root string, opts ...Option) (*Local, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, metaDir), 0755); err != nil {
+		return nil, err
+	}
+
+	// root 本身可以是符号链接
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+
+	var l = &Local{root: root}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l, nil
+}
+
+type Local struct {
+	root    string
+	baseURL string
+	secret  []byte
+
+	// locks 按 key 分段, 写入和删除持有写锁, 读取数据和元数据时持有读锁
+	locks [lockStripes]sync.RWMutex
+}
+
+func (l *Local) lock(key string) *sync.RWMutex {
+	var h = fnv.New32a()
+	h.Write([]byte(key))
+	return &l.locks[h.Sum32()%lockStripes]
+}
+
+// Root 根目录
+func (l *Local) Root() string {
+	return l.root
+}
+
+func validKey(key string) error {
+	if err := abc.ValidKey(key); err != nil {
+		return err
+	}
+
+	for _, dir := range []string{metaDir, uploadDir} {
+		if strings.HasPrefix(key, dir+"/") || key == dir {
+			return abc.ErrInvalidKey
 		}
 	}
 
-	return localObject, nil
+	for _, seg := range strings.Split(key, "/") {
+		if strings.HasPrefix(seg, tmpPrefix) {
+			return abc.ErrInvalidKey
+		}
+	}
+	return nil
 }
 
-func LocalInit() error {
+// path key 对应的数据文件和元数据文件
+func (l *Local) path(key string) (string, string, error) {
+	if err := validKey(key); err != nil {
+		return "", "", err
+	}
+
+	var name = filepath.FromSlash(key)
+	return filepath.Join(l.root, name), filepath.Join(l.root, metaDir, name+".json"), nil
+}
+
+// within 检查 dir 在解析符号链接之后仍然在 root 之内
+func (l *Local) within(dir string) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	if real != l.root && !strings.HasPrefix(real, l.root+string(filepath.Separator)) {
+		return fmt.Errorf("storage: %s escapes root: %w", dir, abc.ErrInvalidKey)
+	}
 	return nil
 }
 
-func (s *StorageLocal) Init() error {
+// resolve 检查已经存在的文件在解析符号链接之后仍然在 root 之内
+func (l *Local) resolve(key, name string) error {
+	if err := l.within(name); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("local: %s: %w", key, abc.ErrNotFound)
+		}
+		return err
+	}
+	return nil
+}
+
+func (l *Local) mkdir(name string) error {
+	var dir = filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return l.within(dir)
+}
+
+// writeFile 写入临时文件, 同步到磁盘之后 rename
+func (l *Local) writeFile(name string, r io.Reader) error {
+	if err := l.mkdir(name); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), tmpPrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	defer func() {
+		if !ok {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		return err
+	}
+
+	ok = true
 	return nil
 }
 
-func (s *StorageLocal) PutObject(filename string, value []byte) (string, error) {
-	if len(value) == 0 {
-		return "", errors.New("param[value] is empty")
+// ctxReader 每次读取之前检查 ctx
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, opts ...abc.PutOption) (*abc.ObjectInfo, error) {
+	name, metaName, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var _opts = abc.NewPutOptions(key, opts...)
+	var h = sha256.New()
+
+	// 先写入数据的临时文件计算校验和, 持有 key 的锁 rename 数据文件之后写入元数据
+	if err := l.mkdir(name); err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(name), tmpPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, io.TeeReader(ctxReader{ctx: ctx, r: r}, h))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(meta{ContentType: _opts.ContentType, Metadata: _opts.Metadata, SHA256: hex.EncodeToString(h.Sum(nil))})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, err
+	}
+
+	var mu = l.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return nil, err
+	}
+
+	if err := l.writeFile(metaName, strings.NewReader(string(data))); err != nil {
+		return nil, err
+	}
+	return l.stat(key, name, metaName)
+}
+
+func (l *Local) readMeta(metaName string) (*meta, error) {
+	var m meta
+	data, err := ioutil.ReadFile(metaName)
+	if os.IsNotExist(err) {
+		// 不是通过 Put 写入的文件
+		return &m, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &m, json.Unmarshal(data, &m)
+}
+
+func (l *Local) stat(key, name, metaName string) (*abc.ObjectInfo, error) {
+	if err := l.resolve(key, name); err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("local: %s: %w", key, abc.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
-	if filename == "" {
-		return "", errors.New("param[filename] is empty")
+	if fi.IsDir() {
+		return nil, fmt.Errorf("local: %s: %w", key, abc.ErrNotFound)
 	}
 
-	err := s.Init()
+	m, err := l.readMeta(metaName)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if m.ContentType == "" {
+		m.ContentType = abc.ContentType(key)
 	}
 
-	writeFile, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	var etag = m.SHA256
+	if etag == "" {
+		etag = fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size())
+	}
+
+	return &abc.ObjectInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: m.ContentType,
+		ETag:        etag,
+		ModTime:     fi.ModTime(),
+		Metadata:    m.Metadata,
+	}, nil
+}
+
+// Stat ETag 为 sha256, 不是通过 Put 写入的文件使用修改时间和大小
+func (l *Local) Stat(ctx context.Context, key string) (*abc.ObjectInfo, error) {
+	name, metaName, err := l.path(key)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	var mu = l.lock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+	return l.stat(key, name, metaName)
+}
+
+type sectionReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (l *Local) Get(ctx context.Context, key string, opts ...abc.GetOption) (io.ReadCloser, *abc.ObjectInfo, error) {
+	name, metaName, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := l.resolve(key, name); err != nil {
+		return nil, nil, err
+	}
+
+	var mu = l.lock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("local: %s: %w", key, abc.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := l.stat(key, name, metaName)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	start, end, err := abc.NewGetOptions(opts...).Range(info.Size)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return sectionReadCloser{Reader: io.NewSectionReader(f, start, end-start), Closer: f}, info, nil
+}
+
+// Verify 重新计算文件的 sha256, 和写入时不一致时返回 ErrChecksum
+func (l *Local) Verify(ctx context.Context, key string) error {
+	name, metaName, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := l.resolve(key, name); err != nil {
+		return err
+	}
+
+	m, f, err := l.open(key, name, metaName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if m.SHA256 == "" {
+		return abc.ErrNotSupport
 	}
-	defer writeFile.Close()
 
-	_, err = writeFile.Write(value)
+	var h = sha256.New()
+	if _, err := io.Copy(h, ctxReader{ctx: ctx, r: f}); err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != m.SHA256 {
+		return fmt.Errorf("local: %s: %w", key, ErrChecksum)
+	}
+	return nil
+}
+
+// open 持有 key 的读锁读取元数据并打开文件, 两者属于同一次写入
+func (l *Local) open(key, name, metaName string) (*meta, *os.File, error) {
+	var mu = l.lock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	m, err := l.readMeta(metaName)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
-	return filename, nil
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("local: %s: %w", key, abc.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, f, nil
 }
 
-func (s *StorageLocal) GetObject(filename string) ([]byte, error) {
-	if filename == "" {
-		return nil, errors.New("param[filename] is empty")
+// List cursor 为上一页最后一个 key
+func (l *Local) List(ctx context.Context, prefix, cursor string, limit int) (*abc.ListResult, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
 	}
 
-	err := s.Init()
+	// 只遍历 prefix 所在的目录
+	var dir = l.root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir = filepath.Join(l.root, filepath.FromSlash(prefix[:i]))
+	}
+
+	var keys []string
+	err := filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(fi.Name(), tmpPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, name)
+		if err != nil {
+			return err
+		}
+
+		var key = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			if key == metaDir || key == uploadDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasPrefix(key, prefix) && key > cursor {
+			keys = append(keys, key)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	sort.Strings(keys)
+
+	var res = &abc.ListResult{}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		res.Cursor = keys[limit-1]
+	}
 
-	return ioutil.ReadFile(filename)
+	for _, key := range keys {
+		name, metaName, err := l.path(key)
+		if err != nil {
+			// 目录中不符合 key 规则的文件
+			continue
+		}
+
+		info, err := l.stat(key, name, metaName)
+		if err != nil {
+			if errors.Is(err, abc.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		res.Objects = append(res.Objects, *info)
+	}
+	return res, nil
 }
 
-func (s *StorageLocal) GetStoragePath(fileName string) string {
-	return fileName
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, metaName, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	var mu = l.lock(key)
+	mu.Lock()
+	for _, name := range []string{name, metaName} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			mu.Unlock()
+			return err
+		}
+	}
+	mu.Unlock()
+
+	l.removeEmptyDirs(filepath.Dir(name), l.root)
+	l.removeEmptyDirs(filepath.Dir(metaName), filepath.Join(l.root, metaDir))
+	return nil
+}
+
+// removeEmptyDirs 删除 dir 到 stop 之间的空目录
+func (l *Local) removeEmptyDirs(dir, stop string) {
+	for dir != stop && strings.HasPrefix(dir, stop) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (l *Local) Copy(ctx context.Context, src, dst string) error {
+	if err := validKey(dst); err != nil {
+		return err
+	}
+
+	r, info, err := l.Get(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var opts = []abc.PutOption{abc.WithContentType(info.ContentType)}
+	for k, v := range info.Metadata {
+		opts = append(opts, abc.WithMetadata(k, v))
+	}
+
+	_, err = l.Put(ctx, dst, r, opts...)
+	return err
 }
@@ -0,0 +1,176 @@
+package local_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pubgo/x/xstorage/abc"
+	"github.com/pubgo/x/xstorage/local"
+	"github.com/pubgo/x/xstorage/storagetest"
+)
+
+func newLocal(t *testing.T, opts ...local.Option) *local.Local {
+	l, err := local.New(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLocal(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) abc.Storage {
+		return newLocal(t)
+	})
+}
+
+func TestLocalSandbox(t *testing.T) {
+	var ctx = context.Background()
+	var l = newLocal(t)
+
+	for _, key := range []string{".meta/x.json", "a/.tmp-123"} {
+		if _, err := l.Put(ctx, key, strings.NewReader("x")); !errors.Is(err, abc.ErrInvalidKey) {
+			t.Fatal(key, err)
+		}
+	}
+
+	// 指向 root 之外的符号链接
+	var outside = t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(l.Root(), "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := l.Get(ctx, "link/secret"); !errors.Is(err, abc.ErrInvalidKey) {
+		t.Fatal(err)
+	}
+	if _, err := l.Put(ctx, "link/new", strings.NewReader("x")); !errors.Is(err, abc.ErrInvalidKey) {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalVerify(t *testing.T) {
+	var ctx = context.Background()
+	var l = newLocal(t)
+
+	if _, err := l.Put(ctx, "a/b.txt", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Verify(ctx, "a/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(l.Root(), "a", "b.txt"), []byte("HELLO"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Verify(ctx, "a/b.txt"); !errors.Is(err, local.ErrChecksum) {
+		t.Fatal(err)
+	}
+
+	// 删除之后清理空目录
+	if err := l.Delete(ctx, "a/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(l.Root(), "a")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalConcurrentPut(t *testing.T) {
+	var ctx = context.Background()
+	var l = newLocal(t)
+
+	// 并发写入同一个 key, 数据和元数据属于同一次写入
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := l.Put(ctx, "a.txt", strings.NewReader(strings.Repeat(strconv.Itoa(i), 1000))); err != nil {
+				t.Error(err)
+			}
+			if err := l.Verify(ctx, "a.txt"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLocalSignURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var ctx = context.Background()
+	var l = newLocal(t, local.WithBaseURL("/files"), local.WithSecret([]byte("secret")))
+	if _, err := l.Put(ctx, "dir/hello world.txt", strings.NewReader("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/files/*key", l.Handler("key"))
+
+	var do = func(u string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, u, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	u, err := l.SignURL("dir/hello world.txt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := do(u)
+	if w.Code != http.StatusOK || w.Body.String() != "hello world" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatal(w.Code, w.Body.String(), w.Header())
+	}
+
+	w = do(u, "Range", "bytes=6-")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "world" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	w = do(u, "If-None-Match", w.Header().Get("ETag"))
+	if w.Code != http.StatusNotModified {
+		t.Fatal(w.Code)
+	}
+
+	// 修改签名
+	parsed, _ := url.Parse(u)
+	query := parsed.Query()
+	query.Set("signature", "x"+query.Get("signature"))
+	parsed.RawQuery = query.Encode()
+	if w = do(parsed.String()); w.Code != http.StatusForbidden {
+		t.Fatal(w.Code)
+	}
+
+	// 过期
+	u, _ = l.SignURL("dir/hello world.txt", -time.Second)
+	if w = do(u); w.Code != http.StatusForbidden {
+		t.Fatal(w.Code)
+	}
+
+	if _, err := newLocal(t).SignURL("a", time.Minute); !errors.Is(err, abc.ErrNotSupport) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,161 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+var _ abc.Multipart = (*Local)(nil)
+
+// maxPartNumber 和 S3 保持一致
+const maxPartNumber = 10000
+
+type upload struct {
+	Key         string            `json:"key"`
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// uploadPath 分片保存在 .uploads/<uploadID> 目录
+func (l *Local) uploadPath(uploadID string) (string, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return "", fmt.Errorf("local: upload %q: %w", uploadID, abc.ErrNotFound)
+	}
+	return filepath.Join(l.root, uploadDir, uploadID), nil
+}
+
+// readUpload 检查 uploadID 存在并且属于 key
+func (l *Local) readUpload(key, uploadID string) (string, *upload, error) {
+	dir, err := l.uploadPath(uploadID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "upload.json"))
+	if os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("local: upload %s: %w", uploadID, abc.ErrNotFound)
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	var u upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return "", nil, err
+	}
+
+	if u.Key != key {
+		return "", nil, fmt.Errorf("local: upload %s of %s: %w", uploadID, key, abc.ErrNotFound)
+	}
+	return dir, &u, nil
+}
+
+func partName(dir string, number int) string {
+	return filepath.Join(dir, fmt.Sprintf("%05d", number))
+}
+
+func (l *Local) InitMultipart(ctx context.Context, key string, opts abc.PutOptions) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+
+	var id = make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	var uploadID = hex.EncodeToString(id)
+	data, err := json.Marshal(upload{Key: key, ContentType: opts.ContentType, Metadata: opts.Metadata})
+	if err != nil {
+		return "", err
+	}
+
+	dir, _ := l.uploadPath(uploadID)
+	if err := l.writeFile(filepath.Join(dir, "upload.json"), bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+// UploadPart ETag 为分片的 md5
+func (l *Local) UploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	if number < 1 || number > maxPartNumber {
+		return "", fmt.Errorf("local: invalid part number %d", number)
+	}
+
+	dir, _, err := l.readUpload(key, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var etag = fmt.Sprintf("%x", md5.Sum(data))
+	if err := l.writeFile(partName(dir, number), bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+
+	if err := l.writeFile(partName(dir, number)+".etag", bytes.NewReader([]byte(etag))); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+// CompleteMultipart 检查分片的 ETag, 按照顺序合并之后写入
+func (l *Local) CompleteMultipart(ctx context.Context, key, uploadID string, parts []abc.Part) error {
+	dir, u, err := l.readUpload(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	var readers []io.Reader
+	for i, p := range parts {
+		if i > 0 && p.Number <= parts[i-1].Number {
+			return fmt.Errorf("local: parts of upload %s are not in ascending order", uploadID)
+		}
+
+		etag, err := ioutil.ReadFile(partName(dir, p.Number) + ".etag")
+		if err != nil || string(etag) != p.ETag {
+			return fmt.Errorf("local: invalid part %d of upload %s", p.Number, uploadID)
+		}
+
+		f, err := os.Open(partName(dir, p.Number))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+
+	var opts = []abc.PutOption{abc.WithContentType(u.ContentType)}
+	for k, v := range u.Metadata {
+		opts = append(opts, abc.WithMetadata(k, v))
+	}
+
+	if _, err := l.Put(ctx, key, io.MultiReader(readers...), opts...); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (l *Local) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	dir, _, err := l.readUpload(key, uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
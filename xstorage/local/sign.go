@@ -0,0 +1,111 @@
+package local
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+// ErrSignature 签名错误或者已经过期
+var ErrSignature = errors.New("storage: invalid or expired signature")
+
+func (l *Local) signature(key string, expires int64) string {
+	var mac = hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL
+// 生成 expire 之后过期的下载地址, 由 Handler 验证签名并返回文件
+// 需要通过 WithSecret 设置签名密钥
+func (l *Local) SignURL(key string, expire time.Duration) (string, error) {
+	if len(l.secret) == 0 {
+		return "", abc.ErrNotSupport
+	}
+
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+
+	var expires = time.Now().Add(expire).Unix()
+	var query = url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", l.signature(key, expires))
+
+	var segs = strings.Split(key, "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	return l.baseURL + "/" + strings.Join(segs, "/") + "?" + query.Encode(), nil
+}
+
+// VerifySign 验证 SignURL 生成的签名
+func (l *Local) VerifySign(key, expires, signature string) error {
+	if len(l.secret) == 0 {
+		return abc.ErrNotSupport
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(l.signature(key, exp))) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// Handler
+// 返回签名的文件, param 为路由中 key 的通配参数, 例如
+// r.GET("/files/*key", l.Handler("key"))
+// 支持 Range 和 If-None-Match
+func (l *Local) Handler(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var key = strings.TrimPrefix(c.Param(param), "/")
+		if err := l.VerifySign(key, c.Query("expires"), c.Query("signature")); err != nil {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		name, metaName, err := l.path(key)
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		info, err := l.stat(key, name, metaName)
+		if errors.Is(err, abc.ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		defer f.Close()
+
+		c.Header("Content-Type", info.ContentType)
+		c.Header("ETag", `"`+info.ETag+`"`)
+		http.ServeContent(c.Writer, c.Request, "", info.ModTime, f)
+	}
+}
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+var _ abc.Storage = (*Memory)(nil)
+
+const defaultListLimit = 1000
+
+type object struct {
+	info abc.ObjectInfo
+	data []byte
+}
+
+// New 内存存储, 用于测试和开发环境
+func New() *Memory {
+	return &Memory{objects: make(map[string]*object)}
+}
+
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]*object
+}
+
+func cloneInfo(info abc.ObjectInfo) *abc.ObjectInfo {
+	if info.Metadata != nil {
+		var md = make(map[string]string, len(info.Metadata))
+		for k, v := range info.Metadata {
+			md[k] = v
+		}
+		info.Metadata = md
+	}
+	return &info
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, opts ...abc.PutOption) (*abc.ObjectInfo, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var _opts = abc.NewPutOptions(key, opts...)
+	var sum = md5.Sum(data)
+	var obj = &object{
+		data: data,
+		info: *cloneInfo(abc.ObjectInfo{
+			Key:         key,
+			Size:        int64(len(data)),
+			ContentType: _opts.ContentType,
+			ETag:        hex.EncodeToString(sum[:]),
+			ModTime:     time.Now(),
+			Metadata:    _opts.Metadata,
+		}),
+	}
+
+	m.mu.Lock()
+	m.objects[key] = obj
+	m.mu.Unlock()
+	return cloneInfo(obj.info), nil
+}
+
+func (m *Memory) get(key string) (*object, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj := m.objects[key]
+	if obj == nil {
+		return nil, abc.ErrNotFound
+	}
+	return obj, nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string, opts ...abc.GetOption) (io.ReadCloser, *abc.ObjectInfo, error) {
+	obj, err := m.get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, end, err := abc.NewGetOptions(opts...).Range(obj.info.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(obj.data[start:end])), cloneInfo(obj.info), nil
+}
+
+func (m *Memory) Stat(ctx context.Context, key string) (*abc.ObjectInfo, error) {
+	obj, err := m.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return cloneInfo(obj.info), nil
+}
+
+// List cursor 为上一页最后一个 key
+func (m *Memory) List(ctx context.Context, prefix, cursor string, limit int) (*abc.ListResult, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	m.mu.RLock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) && key > cursor {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var res = &abc.ListResult{}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		res.Cursor = keys[limit-1]
+	}
+
+	for _, key := range keys {
+		res.Objects = append(res.Objects, *cloneInfo(m.objects[key].info))
+	}
+	m.mu.RUnlock()
+	return res, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	if err := abc.ValidKey(key); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Copy(ctx context.Context, src, dst string) error {
+	if err := abc.ValidKey(dst); err != nil {
+		return err
+	}
+
+	obj, err := m.get(src)
+	if err != nil {
+		return err
+	}
+
+	var info = cloneInfo(obj.info)
+	info.Key = dst
+	info.ModTime = time.Now()
+
+	m.mu.Lock()
+	m.objects[dst] = &object{info: *info, data: obj.data}
+	m.mu.Unlock()
+	return nil
+}
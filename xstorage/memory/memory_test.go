@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/pubgo/x/xstorage/abc"
+	"github.com/pubgo/x/xstorage/memory"
+	"github.com/pubgo/x/xstorage/storagetest"
+)
+
+func TestMemory(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) abc.Storage {
+		return memory.New()
+	})
+}
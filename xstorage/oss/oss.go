@@ -27,7 +27,7 @@ func New(name ...string) Oss {
 		}
 	}
 
-	xerror.PanicT(_endpoint == "", "name or endpoint is empty")
+	xerror.Assert(_endpoint == "", "name or endpoint is empty")
 	return Oss{
 		name:     _name,
 		bucket:   name[len(name)-1],
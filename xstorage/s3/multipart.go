@@ -0,0 +1,88 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+var _ abc.Multipart = (*S3)(nil)
+
+// completePart CompleteMultipartUpload 请求中的分片
+type completePart struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+// InitMultipart 创建分片上传, 返回 upload id
+func (s *S3) InitMultipart(ctx context.Context, key string, opts abc.PutOptions) (string, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return "", err
+	}
+
+	var res struct {
+		UploadID string `xml:"UploadId"`
+	}
+
+	var query = url.Values{"uploads": {""}}
+	if err := s.doXML(ctx, http.MethodPost, key, query, putHeader(opts), nil, &res); err != nil {
+		return "", err
+	}
+
+	if res.UploadID == "" {
+		return "", fmt.Errorf("s3: %s: empty upload id", key)
+	}
+	return res.UploadID, nil
+}
+
+// UploadPart 上传分片, number 从 1 开始, 返回分片的 ETag
+func (s *S3) UploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	var query = url.Values{
+		"partNumber": {strconv.Itoa(number)},
+		"uploadId":   {uploadID},
+	}
+
+	resp, err := s.doOK(ctx, http.MethodPut, key, query, nil, data)
+	if err != nil {
+		return "", convertErr(key, err)
+	}
+	resp.Body.Close()
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// CompleteMultipart parts 需要按照分片编号递增
+func (s *S3) CompleteMultipart(ctx context.Context, key, uploadID string, parts []abc.Part) error {
+	var req = struct {
+		XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+		Parts   []completePart `xml:"Part"`
+	}{Parts: make([]completePart, len(parts))}
+
+	for i, p := range parts {
+		req.Parts[i] = completePart{Number: p.Number, ETag: `"` + strings.Trim(p.ETag, `"`) + `"`}
+	}
+
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var query = url.Values{"uploadId": {uploadID}}
+	return convertErr(key, s.doXML(ctx, http.MethodPost, key, query, nil, body, nil))
+}
+
+// AbortMultipart 取消分片上传, 删除已经上传的分片
+func (s *S3) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	var query = url.Values{"uploadId": {uploadID}}
+	resp, err := s.doOK(ctx, http.MethodDelete, key, query, nil, nil)
+	if err != nil {
+		return convertErr(key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,470 @@
+// Package s3 S3 协议的对象存储, 兼容 AWS S3, MinIO 等, 使用 SigV4 签名
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+var _ abc.Storage = (*S3)(nil)
+
+const (
+	amzMetaPrefix = "X-Amz-Meta-"
+
+	defaultRegion   = "us-east-1"
+	defaultPartSize = 16 << 20
+)
+
+// Error S3 返回的错误
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+	Resource   string `xml:"Resource"`
+	RequestID  string `xml:"RequestId"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// readError 读取响应中的错误, 没有内容时只有状态码
+func readError(resp *http.Response) error {
+	var e = &Error{StatusCode: resp.StatusCode}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	_ = xml.Unmarshal(data, e)
+	return e
+}
+
+// convertErr 404 转换为 abc.ErrNotFound, 416 转换为 abc.ErrInvalidRange
+func convertErr(key string, err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		switch e.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("s3: %s: %w", key, abc.ErrNotFound)
+		case http.StatusRequestedRangeNotSatisfiable:
+			return fmt.Errorf("s3: %s: %w", key, abc.ErrInvalidRange)
+		}
+	}
+	return err
+}
+
+// Option S3 配置
+type Option func(s *S3)
+
+// WithRegion 默认为 us-east-1
+func WithRegion(region string) Option {
+	return func(s *S3) {
+		s.signer.region = region
+	}
+}
+
+// WithPathStyle 使用 endpoint/bucket/key 的地址, MinIO 等需要开启
+func WithPathStyle() Option {
+	return func(s *S3) {
+		s.pathStyle = true
+	}
+}
+
+// WithPartSize
+// 超过 partSize 的对象使用分片上传, 默认为 16MB
+// S3 要求除最后一个分片之外, 每个分片不小于 5MB
+func WithPartSize(size int64) Option {
+	return func(s *S3) {
+		if size > 0 {
+			s.partSize = size
+		}
+	}
+}
+
+// WithHTTPClient 默认为 http.DefaultClient
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *S3) {
+		s.client = client
+	}
+}
+
+// New
+// endpoint 为服务地址, 例如 https://s3.amazonaws.com, http://127.0.0.1:9000
+func New(endpoint, bucket, accessKey, secretKey string, opts ...Option) (*S3, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "" || u.Host == "" || bucket == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q or bucket %q", endpoint, bucket)
+	}
+
+	var s = &S3{
+		endpoint: u,
+		bucket:   bucket,
+		signer:   signer{accessKey: accessKey, secretKey: secretKey, region: defaultRegion},
+		partSize: defaultPartSize,
+		client:   http.DefaultClient,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	signer    signer
+	pathStyle bool
+	partSize  int64
+	client    *http.Client
+	now       func() time.Time
+}
+
+// url key 为空时为 bucket 的地址
+func (s *S3) url(key string, query url.Values) *url.URL {
+	var u = &url.URL{Scheme: s.endpoint.Scheme, Host: s.endpoint.Host}
+
+	var path = strings.TrimRight(s.endpoint.Path, "/") + "/"
+	if s.pathStyle {
+		path += s.bucket + "/"
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+
+	if key != "" {
+		path += key
+	} else if s.pathStyle {
+		path = strings.TrimSuffix(path, "/")
+	}
+
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+	return u
+}
+
+// do 发送签名之后的请求, body 为 nil 时内容为空
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.URL = s.url(key, query)
+	req.Host = req.URL.Host
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	var payloadHash = emptySHA256
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	req.ContentLength = int64(len(body))
+
+	s.signer.sign(req, payloadHash, s.now())
+	return s.client.Do(req)
+}
+
+// doOK 状态码不是 2xx 时返回 *Error
+func (s *S3) doOK(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	resp, err := s.do(ctx, method, key, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, readError(resp)
+	}
+	return resp, nil
+}
+
+// doXML 读取 2xx 响应中的 xml, 某些接口在 200 的响应中返回 Error
+func (s *S3) doXML(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte, v interface{}) error {
+	resp, err := s.doOK(ctx, method, key, query, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Contains(data[:min(len(data), 256)], []byte("<Error>")) {
+		var e = &Error{StatusCode: resp.StatusCode}
+		if err := xml.Unmarshal(data, e); err != nil {
+			return err
+		}
+		return e
+	}
+
+	if v == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, v)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func putHeader(opts abc.PutOptions) http.Header {
+	var header = http.Header{}
+	header.Set("Content-Type", opts.ContentType)
+	for k, v := range opts.Metadata {
+		header.Set(amzMetaPrefix+k, v)
+	}
+	return header
+}
+
+func objectInfo(key string, header http.Header) *abc.ObjectInfo {
+	size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	modTime, _ := http.ParseTime(header.Get("Last-Modified"))
+
+	var info = &abc.ObjectInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: header.Get("Content-Type"),
+		ETag:        strings.Trim(header.Get("ETag"), `"`),
+		ModTime:     modTime,
+	}
+
+	for k := range header {
+		if strings.HasPrefix(k, amzMetaPrefix) {
+			if info.Metadata == nil {
+				info.Metadata = make(map[string]string)
+			}
+			info.Metadata[strings.ToLower(strings.TrimPrefix(k, amzMetaPrefix))] = header.Get(k)
+		}
+	}
+	return info
+}
+
+// Put
+// 读取 partSize 的数据, 没有读完时使用分片上传
+// 小对象只分配实际大小的内存
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, opts ...abc.PutOption) (*abc.ObjectInfo, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, err
+	}
+
+	var _opts = abc.NewPutOptions(key, opts...)
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, r, s.partSize)
+	switch err {
+	case nil:
+		if err := s.putMultipart(ctx, key, _opts, buf.Bytes(), r); err != nil {
+			return nil, err
+		}
+	case io.EOF:
+		resp, err := s.doOK(ctx, http.MethodPut, key, nil, putHeader(_opts), buf.Bytes())
+		if err != nil {
+			return nil, convertErr(key, err)
+		}
+		resp.Body.Close()
+	default:
+		return nil, err
+	}
+	return s.Stat(ctx, key)
+}
+
+// putMultipart first 为第一个分片, 出错时取消上传
+func (s *S3) putMultipart(ctx context.Context, key string, opts abc.PutOptions, first []byte, r io.Reader) (err error) {
+	uploadID, err := s.InitMultipart(ctx, key, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = s.AbortMultipart(context.Background(), key, uploadID)
+		}
+	}()
+
+	var parts []abc.Part
+	var buf = first
+	for n := len(first); n > 0; {
+		etag, err := s.UploadPart(ctx, key, uploadID, len(parts)+1, buf[:n])
+		if err != nil {
+			return err
+		}
+		parts = append(parts, abc.Part{Number: len(parts) + 1, ETag: etag, Size: int64(n)})
+
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+	}
+	return s.CompleteMultipart(ctx, key, uploadID, parts)
+}
+
+// Get 读取整个对象时只需要一次请求, 读取部分数据时先读取对象的属性计算范围
+func (s *S3) Get(ctx context.Context, key string, opts ...abc.GetOption) (io.ReadCloser, *abc.ObjectInfo, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	var _opts = abc.NewGetOptions(opts...)
+	if _opts.Offset == 0 && _opts.Length < 0 {
+		resp, err := s.doOK(ctx, http.MethodGet, key, nil, nil, nil)
+		if err != nil {
+			return nil, nil, convertErr(key, err)
+		}
+		return resp.Body, objectInfo(key, resp.Header), nil
+	}
+
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, end, err := _opts.Range(info.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if start == end {
+		return ioutil.NopCloser(strings.NewReader("")), info, nil
+	}
+
+	var header = http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	resp, err := s.doOK(ctx, http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		return nil, nil, convertErr(key, err)
+	}
+	return resp.Body, info, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*abc.ObjectInfo, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.doOK(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return nil, convertErr(key, err)
+	}
+	resp.Body.Close()
+	return objectInfo(key, resp.Header), nil
+}
+
+type listResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// List 使用 ListObjectsV2, cursor 为 continuation token, 不返回 ContentType 和 Metadata
+func (s *S3) List(ctx context.Context, prefix, cursor string, limit int) (*abc.ListResult, error) {
+	var query = url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	if cursor != "" {
+		query.Set("continuation-token", cursor)
+	}
+	if limit > 0 {
+		query.Set("max-keys", strconv.Itoa(limit))
+	}
+
+	var res listResult
+	if err := s.doXML(ctx, http.MethodGet, "", query, nil, nil, &res); err != nil {
+		return nil, err
+	}
+
+	var result = &abc.ListResult{}
+	if res.IsTruncated {
+		result.Cursor = res.NextContinuationToken
+	}
+
+	for _, obj := range res.Contents {
+		result.Objects = append(result.Objects, abc.ObjectInfo{
+			Key:     obj.Key,
+			Size:    obj.Size,
+			ETag:    strings.Trim(obj.ETag, `"`),
+			ModTime: obj.LastModified,
+		})
+	}
+	return result, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := abc.ValidKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.doOK(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		if errors.Is(convertErr(key, err), abc.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Copy 服务端复制, 默认复制 ContentType 和 Metadata
+func (s *S3) Copy(ctx context.Context, src, dst string) error {
+	if err := abc.ValidKey(src); err != nil {
+		return err
+	}
+
+	if err := abc.ValidKey(dst); err != nil {
+		return err
+	}
+
+	var header = http.Header{}
+	header.Set("X-Amz-Copy-Source", uriEncode("/"+s.bucket+"/"+src, false))
+	return convertErr(src, s.doXML(ctx, http.MethodPut, dst, nil, header, nil, nil))
+}
+
+// SignURL 生成 expire 之后过期的下载地址
+func (s *S3) SignURL(key string, expire time.Duration) (string, error) {
+	return s.PresignURL(http.MethodGet, key, expire)
+}
+
+// PresignURL
+// 生成 expire 之后过期的预签名地址, 例如通过 PUT 直接上传
+// expire 不能超过 7 天
+func (s *S3) PresignURL(method, key string, expire time.Duration) (string, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return "", err
+	}
+
+	if expire <= 0 || expire > maxPresignExpire {
+		return "", fmt.Errorf("s3: invalid presign expire %s", expire)
+	}
+	return s.signer.presign(method, s.url(key, nil), expire, s.now()), nil
+}
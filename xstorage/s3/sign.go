@@ -0,0 +1,173 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm = "AWS4-HMAC-SHA256"
+	service   = "s3"
+
+	amzDateFormat = "20060102T150405Z"
+	dateFormat    = "20060102"
+
+	// unsignedPayload 预签名地址不对内容签名
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// emptySHA256 空内容的 sha256
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// maxPresignExpire 预签名地址最长有效期为 7 天
+	maxPresignExpire = 7 * 24 * time.Hour
+)
+
+func hashHex(data []byte) string {
+	var h = sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	var mac = hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode 按照 SigV4 的规则编码, 只保留 RFC 3986 的非保留字符
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery 按照 key 和 value 排序之后编码, 同时作为请求的 RawQuery
+func canonicalQuery(query url.Values) string {
+	var keys = make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		var values = append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// signedHeaders 参与签名的 header, host 由请求的地址决定
+func signedHeaders(header http.Header) []string {
+	var names = []string{"host"}
+	for k := range header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "content-type" || k == "content-md5" || k == "range" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// canonicalRequest
+// uri 为编码之后的路径, query 为 canonicalQuery 的结果
+func canonicalRequest(method, uri, query, host string, header http.Header, signed []string, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(method + "\n")
+	b.WriteString(uri + "\n")
+	b.WriteString(query + "\n")
+	for _, k := range signed {
+		var value string
+		if k == "host" {
+			value = host
+		} else {
+			var values = append([]string(nil), header.Values(k)...)
+			for i, v := range values {
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(k + ":" + value + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(strings.Join(signed, ";") + "\n")
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+type signer struct {
+	accessKey string
+	secretKey string
+	region    string
+}
+
+func (s signer) scope(t time.Time) string {
+	return t.Format(dateFormat) + "/" + s.region + "/" + service + "/aws4_request"
+}
+
+// signature 对 canonical request 签名
+func (s signer) signature(t time.Time, canonical string) string {
+	var stringToSign = algorithm + "\n" + t.Format(amzDateFormat) + "\n" + s.scope(t) + "\n" + hashHex([]byte(canonical))
+
+	var key = hmacSHA256([]byte("AWS4"+s.secretKey), t.Format(dateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// sign
+// 通过 Authorization header 签名, payloadHash 为请求内容的 sha256
+// req.URL.RawQuery 需要是 canonicalQuery 的结果
+func (s signer) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	var host = req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	var signed = signedHeaders(req.Header)
+	var canonical = canonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, host, req.Header, signed, payloadHash)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		algorithm, s.accessKey, s.scope(t), strings.Join(signed, ";"), s.signature(t, canonical)))
+}
+
+// presign 通过 query 签名, 只对 host 签名, 不对内容签名
+func (s signer) presign(method string, u *url.URL, expire time.Duration, t time.Time) string {
+	t = t.UTC()
+
+	var query = u.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expire/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	var rawQuery = canonicalQuery(query)
+	var canonical = canonicalRequest(method, u.EscapedPath(), rawQuery, u.Host, nil, []string{"host"}, unsignedPayload)
+	return u.Scheme + "://" + u.Host + u.EscapedPath() + "?" + rawQuery + "&X-Amz-Signature=" + s.signature(t, canonical)
+}
@@ -0,0 +1,262 @@
+// Package storagetest abc.Storage 的一致性测试, 每个存储后端都需要通过
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+// Run 运行所有的一致性测试, newStorage 为每个测试返回一个空的存储
+func Run(t *testing.T, newStorage func(t *testing.T) abc.Storage) {
+	var tests = []struct {
+		name string
+		fn   func(t *testing.T, s abc.Storage)
+	}{
+		{"PutGet", testPutGet},
+		{"Overwrite", testOverwrite},
+		{"NotFound", testNotFound},
+		{"Range", testRange},
+		{"List", testList},
+		{"Delete", testDelete},
+		{"Copy", testCopy},
+		{"InvalidKey", testInvalidKey},
+		{"Large", testLarge},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+func put(t *testing.T, s abc.Storage, key, data string, opts ...abc.PutOption) *abc.ObjectInfo {
+	t.Helper()
+
+	info, err := s.Put(context.Background(), key, strings.NewReader(data), opts...)
+	if err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+	return info
+}
+
+func get(t *testing.T, s abc.Storage, key string, opts ...abc.GetOption) (string, *abc.ObjectInfo) {
+	t.Helper()
+
+	r, info, err := s.Get(context.Background(), key, opts...)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Get(%q): read: %v", key, err)
+	}
+	return string(data), info
+}
+
+func testPutGet(t *testing.T, s abc.Storage) {
+	var ctx = context.Background()
+
+	info := put(t, s, "dir/a.txt", "hello", abc.WithMetadata("Owner", "alice"))
+	if info.Key != "dir/a.txt" || info.Size != 5 || info.ETag == "" {
+		t.Fatalf("Put info: %+v", info)
+	}
+
+	data, info := get(t, s, "dir/a.txt")
+	if data != "hello" || info.Size != 5 {
+		t.Fatalf("Get: %q %+v", data, info)
+	}
+
+	stat, err := s.Stat(ctx, "dir/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size != 5 || stat.ModTime.IsZero() || stat.ETag == "" || !strings.HasPrefix(stat.ContentType, "text/plain") {
+		t.Fatalf("Stat: %+v", stat)
+	}
+	if stat.Metadata["owner"] != "alice" {
+		t.Fatalf("Stat metadata: %v", stat.Metadata)
+	}
+
+	put(t, s, "b.bin", "", abc.WithContentType("image/png"))
+	data, info = get(t, s, "b.bin")
+	if data != "" || info.Size != 0 || info.ContentType != "image/png" {
+		t.Fatalf("Get empty: %q %+v", data, info)
+	}
+}
+
+func testOverwrite(t *testing.T, s abc.Storage) {
+	put(t, s, "a", "first version")
+	put(t, s, "a", "second")
+
+	if data, info := get(t, s, "a"); data != "second" || info.Size != 6 {
+		t.Fatalf("Overwrite: %q %+v", data, info)
+	}
+}
+
+func testNotFound(t *testing.T, s abc.Storage) {
+	var ctx = context.Background()
+
+	if _, _, err := s.Get(ctx, "missing"); !errors.Is(err, abc.ErrNotFound) {
+		t.Fatalf("Get missing: %v", err)
+	}
+
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, abc.ErrNotFound) {
+		t.Fatalf("Stat missing: %v", err)
+	}
+}
+
+func testRange(t *testing.T, s abc.Storage) {
+	put(t, s, "r", "0123456789")
+
+	for _, tt := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{2, 3, "234"},
+		{5, -1, "56789"},
+		{8, 100, "89"},
+	} {
+		if data, info := get(t, s, "r", abc.WithRange(tt.offset, tt.length)); data != tt.want || info.Size != 10 {
+			t.Fatalf("Range(%d, %d): %q %+v", tt.offset, tt.length, data, info)
+		}
+	}
+
+	if _, _, err := s.Get(context.Background(), "r", abc.WithRange(10, 1)); !errors.Is(err, abc.ErrInvalidRange) {
+		t.Fatalf("Range beyond size: %v", err)
+	}
+}
+
+func testList(t *testing.T, s abc.Storage) {
+	var ctx = context.Background()
+
+	var want []string
+	for i := 0; i < 5; i++ {
+		key := "list/" + strconv.Itoa(i)
+		want = append(want, key)
+		put(t, s, key, key)
+	}
+	put(t, s, "listx", "x")
+	put(t, s, "other/0", "0")
+
+	var got []string
+	var cursor string
+	for i := 0; ; i++ {
+		if i > len(want) {
+			t.Fatal("List does not terminate")
+		}
+
+		res, err := s.List(ctx, "list/", cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Objects) > 2 {
+			t.Fatalf("List limit: %d", len(res.Objects))
+		}
+
+		for _, obj := range res.Objects {
+			if obj.Size != int64(len(obj.Key)) {
+				t.Fatalf("List size: %+v", obj)
+			}
+			got = append(got, obj.Key)
+		}
+
+		if cursor = res.Cursor; cursor == "" {
+			break
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("List: %v, want %v", got, want)
+	}
+
+	res, err := s.List(ctx, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Objects) != 7 || res.Cursor != "" {
+		t.Fatalf("List all: %+v", res)
+	}
+}
+
+func testDelete(t *testing.T, s abc.Storage) {
+	var ctx = context.Background()
+
+	put(t, s, "d", "data")
+	if err := s.Delete(ctx, "d"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Stat(ctx, "d"); !errors.Is(err, abc.ErrNotFound) {
+		t.Fatalf("Stat deleted: %v", err)
+	}
+
+	if err := s.Delete(ctx, "d"); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+}
+
+func testCopy(t *testing.T, s abc.Storage) {
+	var ctx = context.Background()
+
+	put(t, s, "src", "copy me", abc.WithContentType("text/markdown"), abc.WithMetadata("k", "v"))
+	if err := s.Copy(ctx, "src", "dst/copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, info := get(t, s, "dst/copy")
+	if data != "copy me" || info.ContentType != "text/markdown" || info.Metadata["k"] != "v" {
+		t.Fatalf("Copy: %q %+v", data, info)
+	}
+
+	if data, _ := get(t, s, "src"); data != "copy me" {
+		t.Fatalf("Copy changed src: %q", data)
+	}
+
+	if err := s.Copy(ctx, "missing", "dst/missing"); !errors.Is(err, abc.ErrNotFound) {
+		t.Fatalf("Copy missing: %v", err)
+	}
+}
+
+func testInvalidKey(t *testing.T, s abc.Storage) {
+	var ctx = context.Background()
+
+	for _, key := range []string{"", "/abs", "a/../b", "../escape", "a//b", "dir/"} {
+		if _, err := s.Put(ctx, key, strings.NewReader("x")); !errors.Is(err, abc.ErrInvalidKey) {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+}
+
+func testLarge(t *testing.T, s abc.Storage) {
+	var data = make([]byte, 3<<20+17)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	info, err := s.Put(context.Background(), "large", io.MultiReader(bytes.NewReader(data[:1<<20]), bytes.NewReader(data[1<<20:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(data)) {
+		t.Fatalf("Put size: %d", info.Size)
+	}
+
+	got, _ := get(t, s, "large")
+	if got != string(data) {
+		t.Fatal("Large: data mismatch")
+	}
+}
@@ -0,0 +1,108 @@
+package upload
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// session 日志中记录的上传
+type session struct {
+	Key      string       `json:"key"`
+	UploadID string       `json:"upload_id"`
+	Size     int64        `json:"size"`
+	PartSize int64        `json:"part_size"`
+	Parts    []partRecord `json:"parts,omitempty"`
+}
+
+// partRecord 已经上传的分片, CRC64 用于继续上传时检查本地数据是否变化
+type partRecord struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+	MD5    string `json:"md5"`
+	CRC64  uint64 `json:"crc64"`
+}
+
+// journal
+// 每个 key 一个 json 文件, 通过临时文件和 rename 写入
+// nil 表示不记录日志
+type journal struct {
+	dir string
+}
+
+func (j *journal) path(key string) string {
+	var h = sha1.Sum([]byte(key))
+	return filepath.Join(j.dir, hex.EncodeToString(h[:])+".json")
+}
+
+// load 没有日志时返回 nil
+func (j *journal) load(key string) (*session, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(j.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	// sha1 冲突或者日志被修改
+	if s.Key != key {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (j *journal) save(s *session) error {
+	if j == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(j.dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(j.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), j.path(s.Key))
+}
+
+func (j *journal) remove(key string) error {
+	if j == nil {
+		return nil
+	}
+
+	if err := os.Remove(j.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
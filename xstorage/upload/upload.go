@@ -0,0 +1,458 @@
+// Package upload 大文件的分片上传
+// 分片并发上传, 每个分片单独重试, 通过本地日志记录已经上传的分片, 中断之后从上次的位置继续上传
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pubgo/x/retry"
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+const (
+	defaultPartSize = 8 << 20
+	defaultParallel = 4
+
+	// maxParts 分片数量超过时增加分片大小
+	maxParts = 10000
+)
+
+// ErrChecksum 服务端返回的 ETag 和本地计算的 md5 不一致
+var ErrChecksum = errors.New("upload: checksum mismatch")
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// Progress 上传进度, 每个分片上传完成之后回调
+type Progress struct {
+	Key      string
+	Total    int64
+	Uploaded int64
+	Parts    int
+	Done     int
+}
+
+// Option Uploader 配置
+type Option func(u *Uploader)
+
+// WithPartSize 分片大小, 默认为 8MB
+func WithPartSize(size int64) Option {
+	return func(u *Uploader) {
+		if size > 0 {
+			u.partSize = size
+		}
+	}
+}
+
+// WithParallel 同时上传的分片数, 默认为 4
+func WithParallel(n int) Option {
+	return func(u *Uploader) {
+		if n > 0 {
+			u.parallel = n
+		}
+	}
+}
+
+// WithJournal
+// 在 dir 中记录上传的进度, 上传失败之后不取消上传, 下次上传同一个 key 时继续上传
+func WithJournal(dir string) Option {
+	return func(u *Uploader) {
+		u.journal = &journal{dir: dir}
+	}
+}
+
+// WithRetry 每个分片的重试策略, 默认重试 3 次, 指数退避
+func WithRetry(opts ...retry.Option) Option {
+	return func(u *Uploader) {
+		u.retry = opts
+	}
+}
+
+// WithProgress 上传进度的回调, 不会并发调用
+func WithProgress(fn func(p Progress)) Option {
+	return func(u *Uploader) {
+		u.progress = fn
+	}
+}
+
+// New storage 需要支持分片上传, 例如 s3.S3, alioss.OSS, local.Local
+func New(storage abc.MultipartStorage, opts ...Option) *Uploader {
+	var u = &Uploader{
+		storage:  storage,
+		partSize: defaultPartSize,
+		parallel: defaultParallel,
+		retry: []retry.Option{
+			retry.WithAttempt(3),
+			retry.WithStrategy(retry.Exponential(100*time.Millisecond, 2, 5*time.Second)),
+		},
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+type Uploader struct {
+	storage  abc.MultipartStorage
+	partSize int64
+	parallel int
+	journal  *journal
+	retry    []retry.Option
+	progress func(p Progress)
+}
+
+// UploadFile 上传本地文件
+func (u *Uploader) UploadFile(ctx context.Context, key, name string, opts ...abc.PutOption) (*abc.ObjectInfo, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return u.Upload(ctx, key, f, fi.Size(), opts...)
+}
+
+// Upload
+// 上传 r 中的 size 字节, 不超过一个分片时直接上传
+// 开启日志时, 之前上传中断的分片重新计算 crc64, 和日志一致时不再上传
+func (u *Uploader) Upload(ctx context.Context, key string, r io.ReaderAt, size int64, opts ...abc.PutOption) (*abc.ObjectInfo, error) {
+	if err := abc.ValidKey(key); err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		return nil, fmt.Errorf("upload: invalid size %d", size)
+	}
+
+	var partSize = u.partSize
+	if n := (size + partSize - 1) / partSize; n > maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+
+	if size <= partSize {
+		return u.put(ctx, key, r, size, opts)
+	}
+
+	var _opts = abc.NewPutOptions(key, opts...)
+	s, resumed, err := u.session(ctx, key, size, partSize, _opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := u.upload(ctx, s, r)
+	if err != nil && resumed && errors.Is(err, abc.ErrNotFound) {
+		// 服务端的上传已经过期, 重新上传
+		_ = u.journal.remove(key)
+		if s, _, err = u.session(ctx, key, size, partSize, _opts); err != nil {
+			return nil, err
+		}
+		info, err = u.upload(ctx, s, r)
+	}
+	return info, err
+}
+
+// put 不需要分片时直接上传
+func (u *Uploader) put(ctx context.Context, key string, r io.ReaderAt, size int64, opts []abc.PutOption) (info *abc.ObjectInfo, err error) {
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		info, err = u.storage.Put(ctx, key, io.NewSectionReader(r, 0, size), opts...)
+		return err
+	}, u.retry...)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size != size {
+		return nil, fmt.Errorf("upload: %s: size %d, want %d: %w", key, info.Size, size, ErrChecksum)
+	}
+
+	if u.progress != nil {
+		u.progress(Progress{Key: key, Total: size, Uploaded: size, Parts: 1, Done: 1})
+	}
+	return info, nil
+}
+
+// session 读取日志中的上传, 和本次上传不一致时取消之前的上传
+func (u *Uploader) session(ctx context.Context, key string, size, partSize int64, opts abc.PutOptions) (*session, bool, error) {
+	s, err := u.journal.load(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if s != nil {
+		if s.Size == size && s.PartSize == partSize {
+			return s, true, nil
+		}
+
+		_ = u.storage.AbortMultipart(ctx, key, s.UploadID)
+		if err := u.journal.remove(key); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var uploadID string
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		uploadID, err = u.storage.InitMultipart(ctx, key, opts)
+		return err
+	}, u.retry...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s = &session{Key: key, UploadID: uploadID, Size: size, PartSize: partSize}
+	if err := u.journal.save(s); err != nil {
+		_ = u.storage.AbortMultipart(ctx, key, uploadID)
+		return nil, false, err
+	}
+	return s, false, nil
+}
+
+func (u *Uploader) upload(ctx context.Context, s *session, r io.ReaderAt) (info *abc.ObjectInfo, err error) {
+	defer func() {
+		// 没有日志时无法继续上传, 取消上传
+		if err != nil && u.journal == nil {
+			_ = u.storage.AbortMultipart(context.Background(), s.Key, s.UploadID)
+		}
+	}()
+
+	var numParts = int((s.Size + s.PartSize - 1) / s.PartSize)
+	var buf = make([]byte, s.PartSize)
+	var st = &state{session: s, done: make(map[int]partRecord)}
+
+	// 检查日志中已经上传的分片
+	for _, p := range s.Parts {
+		if p.Number < 1 || p.Number > numParts {
+			continue
+		}
+
+		data, err := readPart(r, s, p.Number, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		if crc64.Checksum(data, crcTable) == p.CRC64 {
+			st.done[p.Number] = p
+			st.uploaded += p.Size
+		}
+	}
+
+	if len(st.done) > 0 {
+		u.report(st, numParts)
+	}
+
+	var pending []int
+	for number := 1; number <= numParts; number++ {
+		if _, ok := st.done[number]; !ok {
+			pending = append(pending, number)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var numbers = make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for i := 0; i < u.parallel && i < len(pending); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var buf = make([]byte, s.PartSize)
+			for number := range numbers {
+				if err := u.uploadPart(ctx, st, r, number, buf, numParts); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, number := range pending {
+		select {
+		case numbers <- number:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(numbers)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return u.complete(ctx, st, numParts)
+}
+
+func readPart(r io.ReaderAt, s *session, number int, buf []byte) ([]byte, error) {
+	var offset = int64(number-1) * s.PartSize
+	var n = s.PartSize
+	if offset+n > s.Size {
+		n = s.Size - offset
+	}
+
+	var data = buf[:n]
+	if _, err := io.ReadFull(io.NewSectionReader(r, offset, n), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (u *Uploader) uploadPart(ctx context.Context, st *state, r io.ReaderAt, number int, buf []byte, numParts int) error {
+	var s = st.session
+	data, err := readPart(r, s, number, buf)
+	if err != nil {
+		return err
+	}
+
+	var sum = md5.Sum(data)
+	var md5Hex = hex.EncodeToString(sum[:])
+
+	var etag string
+	err = retry.Do(ctx, func(ctx context.Context) (err error) {
+		etag, err = u.storage.UploadPart(ctx, s.Key, s.UploadID, number, data)
+		if err != nil {
+			if errors.Is(err, abc.ErrNotFound) {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+
+		// 服务端加密等情况下 ETag 不是 md5, 不检查
+		if isMD5(etag) && !strings.EqualFold(etag, md5Hex) {
+			return fmt.Errorf("upload: %s part %d: etag %s, md5 %s: %w", s.Key, number, etag, md5Hex, ErrChecksum)
+		}
+		return nil
+	}, u.retry...)
+	if err != nil {
+		return err
+	}
+
+	var p = partRecord{
+		Number: number,
+		ETag:   etag,
+		Size:   int64(len(data)),
+		MD5:    md5Hex,
+		CRC64:  crc64.Checksum(data, crcTable),
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.done[number] = p
+	st.uploaded += p.Size
+	s.Parts = st.parts()
+	if err := u.journal.save(s); err != nil {
+		return err
+	}
+
+	u.report(st, numParts)
+	return nil
+}
+
+func (u *Uploader) report(st *state, numParts int) {
+	if u.progress != nil {
+		u.progress(Progress{Key: st.session.Key, Total: st.session.Size, Uploaded: st.uploaded, Parts: numParts, Done: len(st.done)})
+	}
+}
+
+// complete 合并分片, 检查大小, ETag 为分片 md5 的组合时检查 ETag
+func (u *Uploader) complete(ctx context.Context, st *state, numParts int) (*abc.ObjectInfo, error) {
+	var s = st.session
+	var records = st.parts()
+	var parts = make([]abc.Part, len(records))
+	var sums = md5.New()
+	for i, p := range records {
+		parts[i] = abc.Part{Number: p.Number, ETag: p.ETag, Size: p.Size}
+		b, _ := hex.DecodeString(p.MD5)
+		sums.Write(b)
+	}
+
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		return u.storage.CompleteMultipart(ctx, s.Key, s.UploadID, parts)
+	}, u.retry...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.journal.remove(s.Key); err != nil {
+		return nil, err
+	}
+
+	info, err := u.storage.Stat(ctx, s.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size != s.Size {
+		return nil, fmt.Errorf("upload: %s: size %d, want %d: %w", s.Key, info.Size, s.Size, ErrChecksum)
+	}
+
+	var want = fmt.Sprintf("%x-%d", sums.Sum(nil), numParts)
+	if strings.HasSuffix(info.ETag, fmt.Sprintf("-%d", numParts)) && isMD5(strings.TrimSuffix(info.ETag, fmt.Sprintf("-%d", numParts))) &&
+		!strings.EqualFold(info.ETag, want) {
+		return nil, fmt.Errorf("upload: %s: etag %s, want %s: %w", s.Key, info.ETag, want, ErrChecksum)
+	}
+	return info, nil
+}
+
+// Abort 取消日志中 key 的上传
+func (u *Uploader) Abort(ctx context.Context, key string) error {
+	s, err := u.journal.load(key)
+	if err != nil || s == nil {
+		return err
+	}
+
+	if err := u.storage.AbortMultipart(ctx, key, s.UploadID); err != nil && !errors.Is(err, abc.ErrNotFound) {
+		return err
+	}
+	return u.journal.remove(key)
+}
+
+func isMD5(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// state 一次上传的状态
+type state struct {
+	mu       sync.Mutex
+	session  *session
+	done     map[int]partRecord
+	uploaded int64
+}
+
+// parts 按照编号排序的分片
+func (st *state) parts() []partRecord {
+	var parts = make([]partRecord, 0, len(st.done))
+	for _, p := range st.done {
+		parts = append(parts, p)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts
+}
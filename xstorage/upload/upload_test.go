@@ -0,0 +1,211 @@
+package upload_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/retry"
+	"github.com/pubgo/x/xstorage/abc"
+	"github.com/pubgo/x/xstorage/local"
+	"github.com/pubgo/x/xstorage/upload"
+)
+
+var errBroken = errors.New("broken connection")
+
+// flaky 在 fail 返回 true 时上传分片失败, attempt 为同一个分片的上传次数
+type flaky struct {
+	*local.Local
+
+	mu       sync.Mutex
+	calls    int
+	attempts map[int]int
+	fail     func(calls, number, attempt int) bool
+	etag     string
+}
+
+func (f *flaky) UploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	f.mu.Lock()
+	f.calls++
+	if f.attempts == nil {
+		f.attempts = make(map[int]int)
+	}
+	f.attempts[number]++
+	var fail = f.fail != nil && f.fail(f.calls, number, f.attempts[number])
+	f.mu.Unlock()
+
+	if fail {
+		return "", errBroken
+	}
+
+	etag, err := f.Local.UploadPart(ctx, key, uploadID, number, data)
+	if f.etag != "" {
+		return f.etag, err
+	}
+	return etag, err
+}
+
+func newFlaky(t *testing.T) *flaky {
+	l, err := local.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &flaky{Local: l}
+}
+
+func randData(n int) []byte {
+	var data = make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(data)
+	return data
+}
+
+func check(t *testing.T, s abc.Storage, key string, want []byte) {
+	t.Helper()
+
+	r, _, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(data, want) {
+		t.Fatalf("%s: data mismatch, %d bytes", key, len(data))
+	}
+}
+
+var fastRetry = upload.WithRetry(retry.WithAttempt(3), retry.WithStrategy(retry.Constant(time.Millisecond)))
+
+func TestUpload(t *testing.T) {
+	var ctx = context.Background()
+	var s = newFlaky(t)
+	var data = randData(1<<20 + 123)
+
+	// 偶数分片第一次上传失败, 通过重试上传成功
+	s.fail = func(calls, number, attempt int) bool { return number%2 == 0 && attempt == 1 }
+
+	var last upload.Progress
+	var u = upload.New(s, upload.WithPartSize(64<<10), upload.WithParallel(4), fastRetry,
+		upload.WithProgress(func(p upload.Progress) {
+			if p.Key != "big.bin" {
+				return
+			}
+			if p.Uploaded < last.Uploaded || p.Done != last.Done+1 {
+				t.Errorf("progress: %+v after %+v", p, last)
+			}
+			last = p
+		}))
+
+	info, err := u.Upload(ctx, "big.bin", bytes.NewReader(data), int64(len(data)), abc.WithMetadata("k", "v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size != int64(len(data)) || info.Metadata["k"] != "v" {
+		t.Fatalf("%+v", info)
+	}
+
+	if last.Uploaded != int64(len(data)) || last.Parts != 17 || last.Done != 17 {
+		t.Fatalf("progress: %+v", last)
+	}
+	check(t, s, "big.bin", data)
+
+	// 不超过一个分片时直接上传
+	if info, err = u.Upload(ctx, "small", bytes.NewReader(data[:10]), 10); err != nil || info.Size != 10 {
+		t.Fatal(info, err)
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	var ctx = context.Background()
+	var s = newFlaky(t)
+	var data = randData(10<<10 + 1)
+	var journal = t.TempDir()
+
+	// 第 4 次之后全部失败
+	s.fail = func(calls, number, attempt int) bool { return calls > 4 }
+
+	var opts = []upload.Option{upload.WithPartSize(1 << 10), upload.WithParallel(1), upload.WithJournal(journal), fastRetry}
+	if _, err := upload.New(s, opts...).Upload(ctx, "a", bytes.NewReader(data), int64(len(data))); !errors.Is(err, errBroken) {
+		t.Fatal(err)
+	}
+
+	files, _ := ioutil.ReadDir(journal)
+	if len(files) != 1 {
+		t.Fatalf("journal: %d files", len(files))
+	}
+
+	// 修改第 2 个分片的数据, 需要重新上传
+	data[1<<10] ^= 0xff
+
+	s.calls = 0
+	s.fail = nil
+
+	var first upload.Progress
+	opts = append(opts, upload.WithProgress(func(p upload.Progress) {
+		if first.Done == 0 {
+			first = p
+		}
+	}))
+
+	if _, err := upload.New(s, opts...).Upload(ctx, "a", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	// 11 个分片, 已经上传 4 个, 其中一个数据变化
+	if s.calls != 8 || first.Done != 3 || first.Uploaded != 3<<10 {
+		t.Fatalf("calls %d, first progress %+v", s.calls, first)
+	}
+	check(t, s, "a", data)
+
+	if files, _ = ioutil.ReadDir(journal); len(files) != 0 {
+		t.Fatalf("journal: %d files", len(files))
+	}
+}
+
+func TestUploadAbort(t *testing.T) {
+	var ctx = context.Background()
+	var s = newFlaky(t)
+	var data = randData(4 << 10)
+	var journal = t.TempDir()
+
+	s.fail = func(calls, number, attempt int) bool { return calls > 1 }
+
+	var u = upload.New(s, upload.WithPartSize(1<<10), upload.WithJournal(journal), fastRetry)
+	if _, err := u.Upload(ctx, "a", bytes.NewReader(data), int64(len(data))); !errors.Is(err, errBroken) {
+		t.Fatal(err)
+	}
+
+	if err := u.Abort(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := ioutil.ReadDir(journal); len(files) != 0 {
+		t.Fatalf("journal: %d files", len(files))
+	}
+
+	if files, _ := ioutil.ReadDir(s.Root() + "/.uploads"); len(files) != 0 {
+		t.Fatalf("uploads: %d", len(files))
+	}
+}
+
+func TestUploadChecksum(t *testing.T) {
+	var s = newFlaky(t)
+	var data = randData(4 << 10)
+	s.etag = "0123456789abcdef0123456789abcdef"
+
+	var u = upload.New(s, upload.WithPartSize(1<<10), fastRetry)
+	if _, err := u.Upload(context.Background(), "a", bytes.NewReader(data), int64(len(data))); !errors.Is(err, upload.ErrChecksum) {
+		t.Fatal(err)
+	}
+
+	// 没有日志时取消上传
+	if files, _ := ioutil.ReadDir(s.Root() + "/.uploads"); len(files) != 0 {
+		t.Fatalf("uploads: %d", len(files))
+	}
+}
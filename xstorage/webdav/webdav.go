@@ -0,0 +1,326 @@
+// Package webdav 基于 gowebdav.Client 的存储
+// ContentType 和 Metadata 保存在 .meta 目录下的 json 文件中
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	gowebdav "github.com/pubgo/x/webdav"
+	"github.com/pubgo/x/xstorage/abc"
+)
+
+var _ abc.Storage = (*WebDAV)(nil)
+
+const (
+	// metaDir 保存 ContentType, Metadata 和校验和
+	metaDir = ".meta"
+
+	defaultListLimit = 1000
+)
+
+type meta struct {
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	SHA256      string            `json:"sha256"`
+}
+
+// New client 的根地址作为存储的根目录
+func New(client *gowebdav.Client) *WebDAV {
+	return &WebDAV{client: client}
+}
+
+type WebDAV struct {
+	client *gowebdav.Client
+}
+
+// Client 底层的 webdav 客户端
+func (w *WebDAV) Client() *gowebdav.Client {
+	return w.client
+}
+
+func validKey(key string) error {
+	if err := abc.ValidKey(key); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(key, metaDir+"/") || key == metaDir {
+		return abc.ErrInvalidKey
+	}
+	return nil
+}
+
+func metaPath(key string) string {
+	return "/" + metaDir + "/" + key + ".json"
+}
+
+func convertErr(key string, err error) error {
+	if gowebdav.IsErrNotFound(err) {
+		return fmt.Errorf("webdav: %s: %w", key, abc.ErrNotFound)
+	}
+	return err
+}
+
+// ctxReader 每次读取之前检查 ctx
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Put 先写入数据, 再写入元数据
+func (w *WebDAV) Put(ctx context.Context, key string, r io.Reader, opts ...abc.PutOption) (*abc.ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	var _opts = abc.NewPutOptions(key, opts...)
+	var h = sha256.New()
+	if err := w.client.WriteStream("/"+key, io.TeeReader(ctxReader{ctx: ctx, r: r}, h), 0644); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(meta{ContentType: _opts.ContentType, Metadata: _opts.Metadata, SHA256: hex.EncodeToString(h.Sum(nil))})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.client.WriteStream(metaPath(key), bytes.NewReader(data), 0644); err != nil {
+		return nil, err
+	}
+	return w.Stat(ctx, key)
+}
+
+func (w *WebDAV) readMeta(key string) (*meta, error) {
+	var m meta
+	data, err := w.client.Read(metaPath(key))
+	if gowebdav.IsErrNotFound(err) {
+		// 不是通过 Put 写入的文件
+		return &m, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &m, json.Unmarshal(data, &m)
+}
+
+// Stat ETag 为 sha256, 不是通过 Put 写入的文件使用服务端的 ETag
+func (w *WebDAV) Stat(ctx context.Context, key string) (*abc.ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fi, err := w.client.Stat("/" + key)
+	if err != nil {
+		return nil, convertErr(key, err)
+	}
+
+	if fi.IsDir() {
+		return nil, fmt.Errorf("webdav: %s: %w", key, abc.ErrNotFound)
+	}
+
+	m, err := w.readMeta(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var info = &abc.ObjectInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: m.ContentType,
+		ETag:        m.SHA256,
+		ModTime:     fi.ModTime(),
+		Metadata:    m.Metadata,
+	}
+
+	if f, ok := fi.(*gowebdav.File); ok {
+		if info.ContentType == "" {
+			info.ContentType = f.ContentType()
+		}
+		if info.ETag == "" {
+			info.ETag = strings.Trim(f.ETag(), `"`)
+		}
+	}
+
+	if info.ContentType == "" {
+		info.ContentType = abc.ContentType(key)
+	}
+	return info, nil
+}
+
+// Get 先读取对象的属性计算范围, 再通过 Range 读取数据
+func (w *WebDAV) Get(ctx context.Context, key string, opts ...abc.GetOption) (io.ReadCloser, *abc.ObjectInfo, error) {
+	info, err := w.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, end, err := abc.NewGetOptions(opts...).Range(info.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if start == end {
+		return ioutil.NopCloser(strings.NewReader("")), info, nil
+	}
+
+	var r io.ReadCloser
+	if start == 0 && end == info.Size {
+		r, err = w.client.ReadStream("/" + key)
+	} else {
+		r, err = w.client.ReadStreamRange("/"+key, start, end-start)
+	}
+	if err != nil {
+		return nil, nil, convertErr(key, err)
+	}
+	return r, info, nil
+}
+
+// walk 递归列出 dir 下以 prefix 开头的文件
+func (w *WebDAV) walk(ctx context.Context, dir, prefix string, keys *[]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	files, err := w.client.ReadDir("/" + dir)
+	if err != nil {
+		if gowebdav.IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, fi := range files {
+		var key = path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			if key == metaDir {
+				continue
+			}
+
+			// 跳过和 prefix 不相关的目录
+			if !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				continue
+			}
+
+			if err := w.walk(ctx, key, prefix, keys); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if strings.HasPrefix(key, prefix) {
+			*keys = append(*keys, key)
+		}
+	}
+	return nil
+}
+
+// List cursor 为上一页最后一个 key
+func (w *WebDAV) List(ctx context.Context, prefix, cursor string, limit int) (*abc.ListResult, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	// 只遍历 prefix 所在的目录
+	var dir string
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir = prefix[:i]
+	}
+
+	var all []string
+	if err := w.walk(ctx, dir, prefix, &all); err != nil {
+		return nil, err
+	}
+	sort.Strings(all)
+
+	var keys = all[sort.SearchStrings(all, cursor):]
+	if len(keys) > 0 && keys[0] == cursor {
+		keys = keys[1:]
+	}
+
+	var res = &abc.ListResult{}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		res.Cursor = keys[limit-1]
+	}
+
+	for _, key := range keys {
+		if validKey(key) != nil {
+			// 目录中不符合 key 规则的文件
+			continue
+		}
+
+		info, err := w.Stat(ctx, key)
+		if err != nil {
+			if errors.Is(err, abc.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		res.Objects = append(res.Objects, *info)
+	}
+	return res, nil
+}
+
+// Delete 不删除空目录
+func (w *WebDAV) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := w.client.Remove("/" + key); err != nil {
+		return err
+	}
+	return w.client.Remove(metaPath(key))
+}
+
+// Copy 服务端复制数据和元数据
+func (w *WebDAV) Copy(ctx context.Context, src, dst string) error {
+	if err := validKey(src); err != nil {
+		return err
+	}
+
+	if err := validKey(dst); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := w.client.Copy("/"+src, "/"+dst, true); err != nil {
+		return convertErr(src, err)
+	}
+
+	err := w.client.Copy(metaPath(src), metaPath(dst), true)
+	if gowebdav.IsErrNotFound(err) {
+		// src 没有元数据
+		return w.client.Remove(metaPath(dst))
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gowebdav "github.com/pubgo/x/webdav"
+	"github.com/pubgo/x/xstorage/abc"
+	"github.com/pubgo/x/xstorage/storagetest"
+	"github.com/pubgo/x/xstorage/webdav"
+	xwebdav "golang.org/x/net/webdav"
+)
+
+func newWebDAV(t *testing.T) *webdav.WebDAV {
+	var server = httptest.NewServer(&xwebdav.Handler{
+		FileSystem: xwebdav.NewMemFS(),
+		LockSystem: xwebdav.NewMemLS(),
+	})
+	t.Cleanup(server.Close)
+	return webdav.New(gowebdav.NewClient(server.URL, "", ""))
+}
+
+func TestWebDAV(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) abc.Storage {
+		return newWebDAV(t)
+	})
+}
+
+func TestWebDAVForeignFile(t *testing.T) {
+	var ctx = context.Background()
+	var w = newWebDAV(t)
+
+	// 不是通过 Put 写入的文件没有元数据
+	if err := w.Client().WriteStream("/dir/a.json", strings.NewReader(`{"a":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, info, err := w.Get(ctx, "dir/a.json", abc.WithRange(1, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, _ := ioutil.ReadAll(r)
+	if string(data) != `"a"` || info.Size != 7 || info.ETag == "" || !strings.HasPrefix(info.ContentType, "application/json") {
+		t.Fatalf("%q %+v", data, info)
+	}
+
+	if _, err := w.Put(ctx, ".meta/dir/a.json.json", strings.NewReader("x")); !errors.Is(err, abc.ErrInvalidKey) {
+		t.Fatal(err)
+	}
+
+	res, err := w.List(ctx, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Objects) != 1 || res.Objects[0].Key != "dir/a.json" {
+		t.Fatalf("%+v", res)
+	}
+}
@@ -0,0 +1,46 @@
+package xtask
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNotFuncType    = errors.New("func type not match error")
+	ErrParamsNotMatch = errors.New("params not match error")
+	ErrTaskClosed     = errors.New("task is closed")
+	ErrGroupPanic     = errors.New("group panic error")
+)
+
+// MultiError 多个任务的错误, errors.Is 和 errors.As 匹配其中任意一个错误
+type MultiError []error
+
+func (m MultiError) Error() string {
+	var msgs = make([]string, len(m))
+	for i := range m {
+		msgs[i] = m[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+func (m MultiError) Is(target error) bool {
+	for i := range m {
+		if errors.Is(m[i], target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MultiError) As(target interface{}) bool {
+	for i := range m {
+		if errors.As(m[i], target) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package xtask
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // ITask interface
 type ITask interface {
@@ -15,4 +18,42 @@ type ITask interface {
 	Do(args ...interface{})
 	Stop()
 	IsClosed() bool
+	// errors collected by ErrPolicyCollect or ErrPolicyFailFast
+	Errs() []error
+}
+
+// ICtxTask context aware task interface
+type ICtxTask interface {
+	// all tasks, queued and running
+	Size() int32
+	// task that running
+	CurSize() int32
+	// task average execution time
+	CurDur() time.Duration
+	// wait for task to finish
+	Wait()
+	// Do blocks until the task is queued, ctx is done or the task is stopped
+	Do(ctx context.Context, args ...interface{}) error
+	// DoFuture same as Do, returns the Future of the task
+	DoFuture(ctx context.Context, args ...interface{}) (*Future, error)
+	// Stop drains the queued tasks until ctx is done, then cancels the rest
+	Stop(ctx context.Context) error
+	IsClosed() bool
+	// errors collected by ErrPolicyCollect or ErrPolicyFailFast
+	Errs() []error
+}
+
+// IPriorityTask priority and delayed task interface
+type IPriorityTask interface {
+	ICtxTask
+	// DoPriority same as Do, with the given priority
+	DoPriority(ctx context.Context, priority uint8, args ...interface{}) error
+	// DoAt runs the task after at
+	DoAt(ctx context.Context, at time.Time, args ...interface{}) error
+	// DoAfter runs the task after dur
+	DoAfter(ctx context.Context, dur time.Duration, args ...interface{}) error
+	// ready queue size of each priority, index is the priority
+	QueueSize() []int
+	// delayed task size
+	DelaySize() int
 }
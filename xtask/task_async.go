@@ -1,27 +1,27 @@
 package xtask
 
 import (
-	"fmt"
 	"github.com/pubgo/x/xtry"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/pubgo/xerror"
 )
 
 // NewAsyncTask
 // max: 最大并发数
 // maxDur: 任务最大执行时间
-func NewAsyncTask(max int32, maxDur time.Duration, fn interface{}) ITask {
+// opts: 任务配置, 例如任务出错之后的处理策略
+func NewAsyncTask(max int32, maxDur time.Duration, fn interface{}, opts ...TaskOption) ITask {
 	_t := &_AsyncTask{
-		max:     max,
-		maxDur:  maxDur,
-		_tfn:    xtry.Try(fn),
-		mux:     &sync.Mutex{},
-		_curDur: make(chan time.Duration, max),
-		taskQ:   make(chan func(...interface{}) (err error), max),
+		taskOptions: newTaskOptions(opts...),
+		max:         max,
+		maxDur:      maxDur,
+		_tfn:        xtry.Wrap(fn),
+		mux:         &sync.Mutex{},
+		_curDur:     make(chan time.Duration, max),
+		taskQ:       make(chan func(...interface{}) (err error), max),
+		done:        make(chan struct{}),
 	}
 	go _t._loop()
 	return _t
@@ -30,6 +30,7 @@ func NewAsyncTask(max int32, maxDur time.Duration, fn interface{}) ITask {
 // AsyncTask struct
 type _AsyncTask struct {
 	ITask
+	*taskOptions
 
 	_tfn func(...interface{}) func(...interface{}) (err error)
 
@@ -38,7 +39,7 @@ type _AsyncTask struct {
 	max    int32
 	maxDur time.Duration
 
-	curDur  time.Duration
+	curDur  int64
 	_curDur chan time.Duration
 
 	taskL int32
@@ -46,8 +47,11 @@ type _AsyncTask struct {
 
 	mux *sync.Mutex
 
+	// Stop 只设置 _stop1 并且关闭 done, taskQ 和 _curDur 不关闭
+	// 停止之后 _loop 取消队列中的任务, 执行中的任务结束之后照常调用 taskDone
 	_stop1 int32
 	_stop  sync.Once
+	done   chan struct{}
 }
 
 // Size
@@ -67,10 +71,16 @@ func (t *_AsyncTask) CurSize() int32 {
 	return atomic.LoadInt32(&t.taskL) - int32(len(t.taskQ))
 }
 
+// Errs
+// get the errors collected by ErrPolicyCollect or ErrPolicyFailFast
+func (t *_AsyncTask) Errs() []error {
+	return t.taskOptions.Errs()
+}
+
 // CurDur
 // current duration
 func (t *_AsyncTask) CurDur() time.Duration {
-	return t.curDur
+	return time.Duration(atomic.LoadInt64(&t.curDur))
 }
 
 // Wait wait for xtask done
@@ -78,22 +88,30 @@ func (t *_AsyncTask) Wait() {
 	t.wg.Wait()
 }
 
+// taskAdd 只在 Do 中调用, Size 小于 max 时 taskQ 不会阻塞
 func (t *_AsyncTask) taskAdd(fn func(...interface{}) error) {
-	if !t.IsClosed() {
-		t.taskQ <- fn
-		t.wg.Add(1)
-		atomic.AddInt32(&t.taskL, 1)
-	}
+	t.wg.Add(1)
+	atomic.AddInt32(&t.taskL, 1)
+	t.taskQ <- fn
 }
 
 func (t *_AsyncTask) taskDone(ct time.Time) {
-	if !t.IsClosed() {
-		t.wg.Done()
-		atomic.AddInt32(&t.taskL, -1)
-		t._curDur <- time.Now().Sub(ct)
+	atomic.AddInt32(&t.taskL, -1)
+	t.wg.Done()
+
+	// 停止之后 _loop 不再读取 _curDur, 不能阻塞
+	select {
+	case t._curDur <- time.Now().Sub(ct):
+	default:
 	}
 }
 
+// taskCancel 取消停止之后还在队列中的任务
+func (t *_AsyncTask) taskCancel() {
+	atomic.AddInt32(&t.taskL, -1)
+	t.wg.Done()
+}
+
 // Do
 // handle xtask
 func (t *_AsyncTask) Do(args ...interface{}) {
@@ -105,16 +123,15 @@ func (t *_AsyncTask) Do(args ...interface{}) {
 	defer t.mux.Unlock()
 
 	for !t.IsClosed() {
-		if t.Size() < t.max && t.curDur < t.maxDur {
+		if t.Size() < t.max && t.CurDur() < t.maxDur {
 			t.taskAdd(t._tfn(args...))
 			return
 		}
 
 		if t.Size() < int32(runtime.NumCPU()*2) {
-			t.curDur = 0
+			atomic.StoreInt64(&t.curDur, 0)
 		}
 
-		xerror.Debug(fmt.Sprintf("info q_l:%d, cur_dur:%s, max_q:%d, max_dur:%s, ", t.taskL, t.curDur, t.max, t.maxDur))
 		time.Sleep(time.Microsecond)
 	}
 }
@@ -124,32 +141,51 @@ func (t *_AsyncTask) Do(args ...interface{}) {
 func (t *_AsyncTask) _taskHandle(fn func(...interface{}) (err error)) {
 	go func() {
 		_t := time.Now()
-		xerror.Exit(fn, "XTask.AsyncTask: max_task_queue_len:%d, task_queue_len:%d, cur_dur:%s, max_dur:%s", t.max, t.taskL, t.curDur, t.maxDur)
+		t.handleErr(fn(), t.Stop, "XTask.AsyncTask: max_task_queue_len:%d, task_queue_len:%d, cur_dur:%s, max_dur:%s", t.max, t.Size(), t.CurDur(), t.maxDur)
 		t.taskDone(_t)
 	}()
 }
 
-// Stop stop xtask
+// Stop
+// stop xtask, 可以在任务中调用, 例如 ErrPolicyFailFast
+// 队列中还没有执行的任务被取消, 执行中的任务不受影响
 func (t *_AsyncTask) Stop() {
 	t._stop.Do(func() {
-		atomic.CompareAndSwapInt32(&t._stop1, 0, 1)
-		atomic.CompareAndSwapInt32(&t.taskL, t.taskL, 0)
-		close(t._curDur)
-		close(t.taskQ)
+		atomic.StoreInt32(&t._stop1, 1)
+		close(t.done)
 	})
 }
 
 func (t *_AsyncTask) _loop() {
 	for {
 		select {
-		case _fn, ok := <-t.taskQ:
-			if ok {
-				t._taskHandle(_fn)
-			}
-		case _curDur, ok := <-t._curDur:
-			if ok {
-				t.curDur = (t.curDur + _curDur) / 2
+		case <-t.done:
+			t._drain()
+			return
+		case _fn := <-t.taskQ:
+			if t.IsClosed() {
+				t.taskCancel()
+				continue
 			}
+			t._taskHandle(_fn)
+		case _curDur := <-t._curDur:
+			atomic.StoreInt64(&t.curDur, (t.CurDur().Nanoseconds()+_curDur.Nanoseconds())/2)
+		}
+	}
+}
+
+// _drain 取消队列中的任务
+// 持有 mux 时 Do 中不会再有 taskAdd, 之后 Do 检查到 IsClosed 直接返回
+func (t *_AsyncTask) _drain() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	for {
+		select {
+		case <-t.taskQ:
+			t.taskCancel()
+		default:
+			return
 		}
 	}
 }
@@ -0,0 +1,114 @@
+package xtask
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewAsyncTaskWithContext
+// ctx: 任务上下文, ctx 结束后取消所有的任务
+// max: 最大并发数
+// maxDur: 任务最大执行时间, 超时后取消该任务的上下文, 0 表示不限制
+// fn: 任务函数, 第一个参数为 context.Context 时注入任务的上下文
+// opts: 任务配置, 例如任务出错之后的处理策略
+func NewAsyncTaskWithContext(ctx context.Context, max int32, maxDur time.Duration, fn interface{}, opts ...TaskOption) ICtxTask {
+	_t := &_CtxTask{_taskBase: newTaskBase(ctx, "CtxTask", max, maxDur, fn, opts...)}
+	_t.taskQ = make(chan *_ctxJob, _t.queueSize)
+
+	go _t._loop()
+	go func() {
+		<-_t.ctx.Done()
+		_t.close()
+	}()
+	return _t
+}
+
+// _CtxTask struct
+type _CtxTask struct {
+	*_taskBase
+
+	taskQ chan *_ctxJob
+	mux   sync.RWMutex
+}
+
+// Do
+// 任务入队, 队列已满时阻塞, 直到入队成功, ctx 结束或者任务关闭
+func (t *_CtxTask) Do(ctx context.Context, args ...interface{}) error {
+	_args, err := t.fn.args(args...)
+	if err != nil {
+		return err
+	}
+
+	return t.push(ctx, &_ctxJob{args: _args})
+}
+
+// DoFuture
+// 同 Do, 入队成功之后返回任务的 Future
+func (t *_CtxTask) DoFuture(ctx context.Context, args ...interface{}) (*Future, error) {
+	_args, err := t.fn.args(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	_job := &_ctxJob{args: _args, future: newFuture()}
+	if err := t.push(ctx, _job); err != nil {
+		return nil, err
+	}
+	return _job.future, nil
+}
+
+func (t *_CtxTask) push(ctx context.Context, job *_ctxJob) (err error) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	if t.IsClosed() {
+		return ErrTaskClosed
+	}
+
+	t.taskAdd()
+	select {
+	case t.taskQ <- job:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-t.closing:
+		err = ErrTaskClosed
+	}
+	t.taskDone()
+	return err
+}
+
+// Stop
+// 停止接收新任务, 等待队列中的任务执行完成, ctx 结束后取消剩余的任务
+func (t *_CtxTask) Stop(ctx context.Context) error {
+	return t.stop(ctx, t.close)
+}
+
+func (t *_CtxTask) close() {
+	t._stop.Do(func() {
+		atomic.StoreInt32(&t._stop1, 1)
+		close(t.closing)
+
+		// 等待正在入队的 Do 返回之后再关闭队列
+		t.mux.Lock()
+		close(t.taskQ)
+		t.mux.Unlock()
+	})
+}
+
+func (t *_CtxTask) _loop() {
+	defer close(t.loopDone)
+
+	for _job := range t.taskQ {
+		// 任务已取消, 丢弃队列中剩余的任务
+		if !t.acquire() {
+			_job.cancel(t.ctx.Err())
+			t.taskDone()
+			continue
+		}
+
+		go t._taskHandle(_job)
+	}
+}
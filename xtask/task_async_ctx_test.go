@@ -0,0 +1,204 @@
+package xtask_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xtask"
+)
+
+func TestCtxTaskDrain(t *testing.T) {
+	var n int32
+	_task := xtask.NewAsyncTaskWithContext(context.Background(), 4, 0, func(i int) {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&n, 1)
+	})
+
+	for i := 0; i < 100; i++ {
+		if err := _task.Do(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := _task.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&n) != 100 {
+		t.Fatalf("expect 100 tasks done, got %d", n)
+	}
+
+	if err := _task.Do(context.Background(), 1); err != xtask.ErrTaskClosed {
+		t.Fatalf("expect ErrTaskClosed, got %v", err)
+	}
+}
+
+func TestCtxTaskStopCancel(t *testing.T) {
+	var started, canceled int32
+	_task := xtask.NewAsyncTaskWithContext(context.Background(), 2, 0, func(ctx context.Context, i int) {
+		atomic.AddInt32(&started, 1)
+		<-ctx.Done()
+		atomic.AddInt32(&canceled, 1)
+	})
+
+	for i := 0; i < 4; i++ {
+		if err := _task.Do(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := _task.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+
+	_task.Wait()
+	if _task.Size() != 0 {
+		t.Fatalf("expect empty task, got %d", _task.Size())
+	}
+
+	if atomic.LoadInt32(&started) != atomic.LoadInt32(&canceled) {
+		t.Fatalf("started %d, canceled %d", started, canceled)
+	}
+}
+
+func TestCtxTaskDoBlock(t *testing.T) {
+	_task := xtask.NewAsyncTaskWithContext(context.Background(), 1, 0, func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_task.Stop(ctx)
+	}()
+
+	// one running, one waiting for running, one queued
+	for i := 0; i < 3; i++ {
+		if err := _task.Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := _task.Do(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+
+	if err := _task.Do(context.Background(), 1); err == nil {
+		t.Fatal("expect params not match error")
+	}
+}
+
+func TestCtxTaskFuture(t *testing.T) {
+	_task := xtask.NewAsyncTaskWithContext(context.Background(), 4, 0, func(i int) (string, error) {
+		if i%2 == 1 {
+			return "", fmt.Errorf("odd %d", i)
+		}
+		return fmt.Sprint(i), nil
+	}, xtask.WithErrPolicy(xtask.ErrPolicyIgnore))
+	defer _task.Stop(context.Background())
+
+	var futures []*xtask.Future
+	for i := 0; i < 10; i++ {
+		f, err := _task.DoFuture(context.Background(), i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, f)
+	}
+
+	for i, f := range futures {
+		var s string
+		err := f.Result(context.Background(), &s)
+		if i%2 == 1 {
+			if err == nil {
+				t.Fatalf("expect error for %d", i)
+			}
+			continue
+		}
+
+		if err != nil || s != fmt.Sprint(i) {
+			t.Fatalf("expect %d, got %q, %v", i, s, err)
+		}
+	}
+}
+
+func TestCtxTaskErrPolicy(t *testing.T) {
+	errCh := make(chan error, 100)
+	_task := xtask.NewAsyncTaskWithContext(context.Background(), 4, 0, func(i int) {
+		if i%10 == 0 {
+			panic(fmt.Errorf("bad item %d", i))
+		}
+	}, xtask.WithErrPolicy(xtask.ErrPolicyCollect), xtask.WithErrChan(errCh))
+
+	for i := 0; i < 100; i++ {
+		if err := _task.Do(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := _task.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(_task.Errs()) != 10 || len(errCh) != 10 {
+		t.Fatalf("expect 10 errors, got %d, %d", len(_task.Errs()), len(errCh))
+	}
+
+	_task = xtask.NewAsyncTaskWithContext(context.Background(), 1, 0, func(i int) error {
+		return fmt.Errorf("bad item %d", i)
+	}, xtask.WithErrPolicy(xtask.ErrPolicyFailFast))
+
+	for i := 0; i < 100; i++ {
+		if err := _task.Do(context.Background(), i); err != nil {
+			break
+		}
+	}
+	_task.Wait()
+
+	if len(_task.Errs()) != 1 || !_task.IsClosed() {
+		t.Fatalf("expect fail fast, got %v", _task.Errs())
+	}
+}
+
+func TestAsyncTaskFailFast(t *testing.T) {
+	var n int32
+	_task := xtask.NewAsyncTask(4, time.Second, func(i int) {
+		atomic.AddInt32(&n, 1)
+		time.Sleep(time.Millisecond)
+		if i >= 10 {
+			panic(fmt.Errorf("bad item %d", i))
+		}
+	}, xtask.WithErrPolicy(xtask.ErrPolicyFailFast))
+
+	// 任务中停止之后 Do 直接返回, 不会向关闭的队列发送任务
+	for i := 0; i < 1000; i++ {
+		_task.Do(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_task.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Wait blocks after fail fast")
+	}
+
+	if len(_task.Errs()) != 1 || !_task.IsClosed() || _task.Size() != 0 {
+		t.Fatalf("expect fail fast, got %v, size %d", _task.Errs(), _task.Size())
+	}
+
+	if atomic.LoadInt32(&n) >= 1000 {
+		t.Fatalf("expect queued tasks canceled, got %d", n)
+	}
+	_task.Stop()
+}
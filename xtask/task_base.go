@@ -0,0 +1,194 @@
+package xtask
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type _ctxJob struct {
+	args   []reflect.Value
+	future *Future
+
+	// 优先级任务使用
+	priority uint8
+	at       time.Time
+	seq      uint64
+}
+
+func (t *_ctxJob) cancel(err error) {
+	if t.future != nil {
+		t.future.set(nil, err)
+	}
+}
+
+// _taskBase
+// context 任务的公共部分, 计数, 并发控制, 任务执行和停止
+type _taskBase struct {
+	*taskOptions
+
+	name string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	fn *_taskFn
+
+	wg sync.WaitGroup
+
+	max    int32
+	maxDur time.Duration
+
+	curDur int64
+
+	taskL int32
+	curL  int32
+	sem   chan struct{}
+
+	closing  chan struct{}
+	loopDone chan struct{}
+
+	_stop1 int32
+	_stop  sync.Once
+}
+
+func newTaskBase(ctx context.Context, name string, max int32, maxDur time.Duration, fn interface{}, opts ...TaskOption) *_taskBase {
+	if max < 1 {
+		max = 1
+	}
+
+	_t := &_taskBase{
+		taskOptions: newTaskOptions(opts...),
+		name:        name,
+		fn:          newTaskFn(fn),
+		max:         max,
+		maxDur:      maxDur,
+		sem:         make(chan struct{}, max),
+		closing:     make(chan struct{}),
+		loopDone:    make(chan struct{}),
+	}
+	if _t.queueSize < 1 {
+		_t.queueSize = int(max)
+	}
+
+	_t.ctx, _t.cancel = context.WithCancel(ctx)
+	return _t
+}
+
+// Size
+// get current xtask size, queued and running
+func (t *_taskBase) Size() int32 {
+	return atomic.LoadInt32(&t.taskL)
+}
+
+// IsClosed
+func (t *_taskBase) IsClosed() bool {
+	return atomic.LoadInt32(&t._stop1) == 1
+}
+
+// CurSize
+// get current running xtask size
+func (t *_taskBase) CurSize() int32 {
+	return atomic.LoadInt32(&t.curL)
+}
+
+// CurDur
+// current duration
+func (t *_taskBase) CurDur() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.curDur))
+}
+
+// Wait wait for xtask done
+func (t *_taskBase) Wait() {
+	t.wg.Wait()
+}
+
+// stop
+// 停止接收新任务, 等待队列中的任务执行完成, ctx 结束后取消剩余的任务
+func (t *_taskBase) stop(ctx context.Context, closeFn func()) error {
+	closeFn()
+
+	done := make(chan struct{})
+	go func() {
+		<-t.loopDone
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.cancel()
+		return nil
+	case <-ctx.Done():
+		t.cancel()
+		<-t.loopDone
+		return ctx.Err()
+	}
+}
+
+func (t *_taskBase) taskAdd() {
+	t.wg.Add(1)
+	atomic.AddInt32(&t.taskL, 1)
+}
+
+func (t *_taskBase) taskDone() {
+	atomic.AddInt32(&t.taskL, -1)
+	t.wg.Done()
+}
+
+func (t *_taskBase) durAdd(dur time.Duration) {
+	for {
+		_old := atomic.LoadInt64(&t.curDur)
+		if atomic.CompareAndSwapInt64(&t.curDur, _old, (_old+int64(dur))/2) {
+			return
+		}
+	}
+}
+
+// acquire
+// 获取并发令牌, 任务取消时返回 false
+func (t *_taskBase) acquire() bool {
+	select {
+	case t.sem <- struct{}{}:
+	case <-t.ctx.Done():
+		return false
+	}
+
+	if t.ctx.Err() != nil {
+		<-t.sem
+		return false
+	}
+	return true
+}
+
+// _taskHandle
+// 此处不允许出错, 所有的错误必须在worker中自行处理, 任务取消导致的错误除外
+// 调用之前必须获取并发令牌
+func (t *_taskBase) _taskHandle(job *_ctxJob) {
+	atomic.AddInt32(&t.curL, 1)
+	_t := time.Now()
+	defer func() {
+		atomic.AddInt32(&t.curL, -1)
+		t.durAdd(time.Since(_t))
+		<-t.sem
+		t.taskDone()
+	}()
+
+	ctx := t.ctx
+	if t.maxDur > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.maxDur)
+		defer cancel()
+	}
+
+	out, err := t.fn.call(ctx, job.args)
+	if job.future != nil {
+		job.future.set(out, err)
+	}
+
+	if err != nil && ctx.Err() == nil {
+		t.handleErr(err, t.cancel, "XTask.%s: max_task:%d, task_len:%d, cur_dur:%s, max_dur:%s", t.name, t.max, t.Size(), t.CurDur(), t.maxDur)
+	}
+}
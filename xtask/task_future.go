@@ -0,0 +1,83 @@
+package xtask
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pubgo/xerror"
+)
+
+// Future 异步任务的执行结果
+type Future struct {
+	done chan struct{}
+	out  []reflect.Value
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) set(out []reflect.Value, err error) {
+	f.out = out
+	f.err = err
+	close(f.done)
+}
+
+// Done 任务执行完成之后关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待任务执行完成, 返回任务的错误
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Get 等待任务执行完成, 返回任务函数的所有返回值
+func (f *Future) Get(ctx context.Context) ([]interface{}, error) {
+	if err := f.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var vals = make([]interface{}, 0, len(f.out))
+	for i := range f.out {
+		vals = append(vals, f.out[i].Interface())
+	}
+	return vals, nil
+}
+
+// Result 等待任务执行完成, 把任务函数的返回值依次赋值给 ptrs
+// 任务函数最后一个返回值为 error 时, 作为 Result 的错误返回, 不需要传入对应的指针
+func (f *Future) Result(ctx context.Context, ptrs ...interface{}) error {
+	if err := f.Wait(ctx); err != nil {
+		return err
+	}
+
+	out := f.out
+	if n := len(out); n > 0 && out[n-1].Type() == errType {
+		out = out[:n-1]
+	}
+
+	if len(ptrs) > len(out) {
+		return xerror.WrapF(ErrParamsNotMatch, "result num not match, [%d]<->[%d]", len(ptrs), len(out))
+	}
+
+	for i := range ptrs {
+		_ptr := reflect.ValueOf(ptrs[i])
+		if _ptr.Kind() != reflect.Ptr || _ptr.IsNil() {
+			return xerror.WrapF(ErrParamsNotMatch, "result %d should be a non-nil pointer", i)
+		}
+
+		if !out[i].Type().AssignableTo(_ptr.Elem().Type()) {
+			return xerror.WrapF(ErrParamsNotMatch, "result %d type error, [%s]<->[%s]", i, out[i].Type(), _ptr.Elem().Type())
+		}
+		_ptr.Elem().Set(out[i])
+	}
+	return nil
+}
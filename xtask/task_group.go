@@ -2,66 +2,202 @@ package xtask
 
 import (
 	"context"
-	"github.com/pubgo/xerror"
-	"github.com/pubgo/x/xtry"
+	"fmt"
+	"runtime"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
+
+	"github.com/pubgo/xerror"
 )
 
+// Group
+// 类似 errgroup, fn 通过 panic 返回错误, 支持并发数限制和多错误收集
+// 零值可以直接使用
 type Group struct {
+	ctx    context.Context
 	cancel func()
 
-	wg sync.WaitGroup
+	wg  sync.WaitGroup
+	sem chan struct{}
 
-	err error
+	multi bool
+	errs  []error
 
 	done uint32
-	m    *sync.Mutex
+	m    sync.Mutex
 }
 
 func NewGroupTask() *Group {
 	return &Group{}
 }
 
+// WithContext
+// 第一个错误出现时或者 Wait 返回时取消返回的 ctx, ctx 结束之后等待执行的 Go 不再执行
 func WithContext(ctx context.Context) (*Group, context.Context) {
 	ctx, cancel := context.WithCancel(ctx)
-	return &Group{cancel: cancel}, ctx
+	return &Group{ctx: ctx, cancel: cancel}, ctx
 }
 
+// SetLimit
+// 限制同时执行的任务数, n < 0 表示不限制, 不能在有任务执行时调用
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("xtask: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// SetMultiErr
+// true: 收集所有任务的错误, 出错之后不停止执行
+// false: 默认, 遇到第一个错误时停止执行
+func (g *Group) SetMultiErr(multi bool) {
+	g.multi = multi
+}
+
+// Wait
+// 等待所有任务执行完成, 返回第一个错误, 多错误模式下返回 MultiError
 func (g *Group) Wait() error {
 	g.wg.Wait()
 	if g.cancel != nil {
 		g.cancel()
 	}
-	return g.err
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	switch len(g.errs) {
+	case 0:
+		return nil
+	case 1:
+		return g.errs[0]
+	default:
+		return append(MultiError(nil), g.errs...)
+	}
+}
+
+// Errs 获取所有任务的错误
+func (g *Group) Errs() []error {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return append([]error(nil), g.errs...)
 }
 
+// Go
+// 执行任务, 达到并发数限制时阻塞, 直到有任务完成或者 ctx 结束
+// 出错停止之后或者 ctx 结束之后不再执行
 func (g *Group) Go(fn func()) {
-	if atomic.LoadUint32(&g.done) == 1 {
+	if g.stopped() {
 		return
 	}
 
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.done1():
+			return
+		}
+
+		if g.stopped() {
+			<-g.sem
+			return
+		}
+	}
+
+	g.do(fn)
+}
+
+// TryGo
+// 达到并发数限制时不阻塞, 返回是否执行了任务
+func (g *Group) TryGo(fn func()) bool {
+	if g.stopped() {
+		return false
+	}
+
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+
+	g.do(fn)
+	return true
+}
+
+func (g *Group) do(fn func()) {
 	g.wg.Add(1)
 	go func() {
 		defer g.wg.Done()
-		xerror.ErrHandle(xtry.Try(fn), func(err xerror.IErr) {
-			// 遇到错误, 停止执行
-			if atomic.LoadUint32(&g.done) == 1 {
-				return
-			}
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
 
-			g.m.Lock()
-			defer g.m.Unlock()
+		if err := g.try(fn); err != nil {
+			g.errAdd(err)
+		}
+	}()
+}
 
-			if g.done == 0 {
-				defer atomic.StoreUint32(&g.done, 1)
-				g.err = err
-				if g.cancel != nil {
-					g.cancel()
-				}
+func (g *Group) done1() <-chan struct{} {
+	if g.ctx == nil {
+		return nil
+	}
+	return g.ctx.Done()
+}
 
-				xerror.P(err, "group error, method:group")
+func (g *Group) stopped() bool {
+	if atomic.LoadUint32(&g.done) == 1 {
+		return true
+	}
+	return g.ctx != nil && g.ctx.Err() != nil
+}
+
+func (g *Group) errAdd(err error) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if g.multi {
+		g.errs = append(g.errs, err)
+		return
+	}
+
+	// 遇到错误, 停止执行
+	if g.done == 0 {
+		defer atomic.StoreUint32(&g.done, 1)
+		g.errs = append(g.errs, err)
+		if g.cancel != nil {
+			g.cancel()
+		}
+	}
+}
+
+// try
+// 捕获 fn 的 panic, 非 error 类型的 panic 和运行时错误记录调用栈
+func (g *Group) try(fn func()) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		if _err, ok := r.(error); ok {
+			if _, ok := _err.(runtime.Error); !ok {
+				err = xerror.Wrap(_err, "group error, method:group")
+				return
 			}
-		})
+		}
+
+		err = xerror.WrapF(ErrGroupPanic, "%v\n%s", r, debug.Stack())
 	}()
+
+	fn()
+	return
 }
@@ -0,0 +1,121 @@
+package xtask_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/xtask"
+	"github.com/pubgo/xerror"
+)
+
+func TestGroupLimit(t *testing.T) {
+	var g = xtask.NewGroupTask()
+	g.SetLimit(2)
+
+	var cur, max int32
+	for i := 0; i < 10; i++ {
+		g.Go(func() {
+			n := atomic.AddInt32(&cur, 1)
+			for {
+				_max := atomic.LoadInt32(&max)
+				if n <= _max || atomic.CompareAndSwapInt32(&max, _max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond * 5)
+			atomic.AddInt32(&cur, -1)
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if max > 2 {
+		t.Fatalf("expect max 2 goroutines, got %d", max)
+	}
+}
+
+func TestGroupTryGo(t *testing.T) {
+	var g xtask.Group
+	g.SetLimit(1)
+
+	block := make(chan struct{})
+	if !g.TryGo(func() { <-block }) {
+		t.Fatal("expect TryGo ok")
+	}
+
+	if g.TryGo(func() {}) {
+		t.Fatal("expect TryGo failed")
+	}
+
+	close(block)
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupErr(t *testing.T) {
+	g, ctx := xtask.WithContext(context.Background())
+	g.SetLimit(1)
+
+	var n int32
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Go(func() {
+			atomic.AddInt32(&n, 1)
+			xerror.Panic(fmt.Errorf("error %d", i))
+		})
+	}
+
+	if err := g.Wait(); err == nil || !strings.Contains(err.Error(), "error 0") {
+		t.Fatalf("expect first error, got %v", err)
+	}
+
+	if ctx.Err() == nil || n != 1 {
+		t.Fatalf("expect canceled after first error, run %d", n)
+	}
+}
+
+func TestGroupMultiErr(t *testing.T) {
+	var g xtask.Group
+	g.SetMultiErr(true)
+
+	for i := 0; i < 5; i++ {
+		i := i
+		g.Go(func() {
+			if i == 0 {
+				var m map[string]int
+				m["panic"] = 1
+			}
+			xerror.Panic(fmt.Errorf("error %d", i))
+		})
+	}
+
+	var err = g.Wait()
+	var multi xtask.MultiError
+	if !errors.As(err, &multi) || len(multi) != 5 || !errors.Is(err, xtask.ErrGroupPanic) {
+		t.Fatalf("expect MultiError, got %v", err)
+	}
+
+	errs := g.Errs()
+	if len(errs) != 5 {
+		t.Fatalf("expect 5 errors, got %d", len(errs))
+	}
+
+	var stack bool
+	for i := range errs {
+		if errors.Is(errs[i], xtask.ErrGroupPanic) && strings.Contains(fmt.Sprintf("%s", errs[i]), "goroutine") {
+			stack = true
+		}
+	}
+
+	if !stack {
+		t.Fatal("expect panic stack")
+	}
+}
@@ -0,0 +1,116 @@
+package xtask
+
+import (
+	"sync"
+
+	"github.com/pubgo/xerror"
+)
+
+// ErrPolicy 任务出错之后的处理策略
+type ErrPolicy int
+
+const (
+	// ErrPolicyExit 任务出错退出进程, 默认策略
+	ErrPolicyExit ErrPolicy = iota
+	// ErrPolicyIgnore 忽略任务错误
+	ErrPolicyIgnore
+	// ErrPolicyCollect 收集所有的任务错误, 通过 Errs 获取
+	ErrPolicyCollect
+	// ErrPolicyFailFast 收集第一个任务错误, 并且停止任务
+	ErrPolicyFailFast
+)
+
+// TaskOption 任务配置
+type TaskOption func(opts *taskOptions)
+
+type taskOptions struct {
+	queueSize int
+
+	errPolicy ErrPolicy
+	errFn     func(err error)
+	errCh     chan<- error
+
+	mux  sync.Mutex
+	errs []error
+}
+
+// WithQueueSize 设置任务队列的长度, 默认为最大并发数, 只对 context 任务有效
+func WithQueueSize(size int) TaskOption {
+	return func(opts *taskOptions) {
+		opts.queueSize = size
+	}
+}
+
+// WithErrPolicy 设置任务出错之后的处理策略
+func WithErrPolicy(policy ErrPolicy) TaskOption {
+	return func(opts *taskOptions) {
+		opts.errPolicy = policy
+	}
+}
+
+// WithErrHandle 任务出错之后的回调, 在策略处理之前调用
+func WithErrHandle(fn func(err error)) TaskOption {
+	return func(opts *taskOptions) {
+		opts.errFn = fn
+	}
+}
+
+// WithErrChan 任务出错之后把错误发送到 ch, ch 需要及时消费, 否则会阻塞任务
+func WithErrChan(ch chan<- error) TaskOption {
+	return func(opts *taskOptions) {
+		opts.errCh = ch
+	}
+}
+
+func newTaskOptions(opts ...TaskOption) *taskOptions {
+	_opts := &taskOptions{}
+	for _, opt := range opts {
+		opt(_opts)
+	}
+	return _opts
+}
+
+// Errs 获取收集的任务错误
+func (t *taskOptions) Errs() []error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return append([]error(nil), t.errs...)
+}
+
+// handleErr
+// stop: 策略为 ErrPolicyFailFast 时用来停止任务
+func (t *taskOptions) handleErr(err error, stop func(), msg string, args ...interface{}) {
+	if err == nil {
+		return
+	}
+
+	if t.errFn != nil {
+		t.errFn(err)
+	}
+
+	if t.errCh != nil {
+		t.errCh <- err
+	}
+
+	switch t.errPolicy {
+	case ErrPolicyIgnore:
+	case ErrPolicyCollect:
+		t.mux.Lock()
+		t.errs = append(t.errs, err)
+		t.mux.Unlock()
+	case ErrPolicyFailFast:
+		t.mux.Lock()
+		first := len(t.errs) == 0
+		if first {
+			t.errs = append(t.errs, err)
+		}
+		t.mux.Unlock()
+
+		if first {
+			stop()
+		}
+	default:
+		xerror.ExitF(err, msg, args...)
+	}
+}
@@ -0,0 +1,344 @@
+package xtask
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pubgo/x/kts"
+)
+
+const (
+	// PriorityMin 最低优先级
+	PriorityMin uint8 = 1
+	// PriorityMax 最高优先级
+	PriorityMax uint8 = 9
+	// PriorityDefault 默认优先级
+	PriorityDefault uint8 = 5
+)
+
+// NewPriorityTask
+// 基于优先级堆的任务池, 优先级高的任务先执行, 同优先级的任务先进先出
+// 任务参数中有 kts.Task 时使用 kts.Task.Priority 作为任务的优先级, 没有或者为 0 时使用 PriorityDefault
+// ctx: 任务上下文, ctx 结束后取消所有的任务
+// max: 最大并发数
+// maxDur: 任务最大执行时间, 超时后取消该任务的上下文, 0 表示不限制
+// fn: 任务函数, 第一个参数为 context.Context 时注入任务的上下文
+// opts: 任务配置, 例如就绪队列的长度和任务出错之后的处理策略
+func NewPriorityTask(ctx context.Context, max int32, maxDur time.Duration, fn interface{}, opts ...TaskOption) IPriorityTask {
+	_t := &_PriorityTask{
+		_taskBase: newTaskBase(ctx, "PriorityTask", max, maxDur, fn, opts...),
+		ready: _jobHeap{less: func(a, b *_ctxJob) bool {
+			if a.priority != b.priority {
+				return a.priority > b.priority
+			}
+			return a.seq < b.seq
+		}},
+		delay: _jobHeap{less: func(a, b *_ctxJob) bool {
+			if !a.at.Equal(b.at) {
+				return a.at.Before(b.at)
+			}
+			return a.seq < b.seq
+		}},
+		notify: make(chan struct{}, 1),
+		space:  make(chan struct{}),
+	}
+
+	go _t._loop()
+	go func() {
+		<-_t.ctx.Done()
+		_t.close()
+	}()
+	return _t
+}
+
+// _PriorityTask struct
+type _PriorityTask struct {
+	*_taskBase
+
+	mux   sync.Mutex
+	seq   uint64
+	ready _jobHeap
+	delay _jobHeap
+	depth [PriorityMax + 1]int
+
+	notify chan struct{}
+	space  chan struct{}
+}
+
+// Do
+// 任务入队, 就绪队列已满时阻塞, 直到入队成功, ctx 结束或者任务关闭
+func (t *_PriorityTask) Do(ctx context.Context, args ...interface{}) error {
+	_, err := t.push(ctx, priorityOf(args), time.Time{}, false, args...)
+	return err
+}
+
+// DoFuture
+// 同 Do, 入队成功之后返回任务的 Future
+func (t *_PriorityTask) DoFuture(ctx context.Context, args ...interface{}) (*Future, error) {
+	return t.push(ctx, priorityOf(args), time.Time{}, true, args...)
+}
+
+// DoPriority
+// 指定优先级执行任务
+func (t *_PriorityTask) DoPriority(ctx context.Context, priority uint8, args ...interface{}) error {
+	_, err := t.push(ctx, priority, time.Time{}, false, args...)
+	return err
+}
+
+// DoAt
+// 在 at 之后执行任务, 到期之后按照优先级排队
+func (t *_PriorityTask) DoAt(ctx context.Context, at time.Time, args ...interface{}) error {
+	_, err := t.push(ctx, priorityOf(args), at, false, args...)
+	return err
+}
+
+// DoAfter
+// 在 dur 之后执行任务
+func (t *_PriorityTask) DoAfter(ctx context.Context, dur time.Duration, args ...interface{}) error {
+	return t.DoAt(ctx, time.Now().Add(dur), args...)
+}
+
+// QueueSize
+// 就绪队列中每个优先级的任务数量, 下标为优先级
+func (t *_PriorityTask) QueueSize() []int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return append([]int(nil), t.depth[:]...)
+}
+
+// DelaySize
+// 等待执行的延迟任务数量
+func (t *_PriorityTask) DelaySize() int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return t.delay.Len()
+}
+
+// Stop
+// 停止接收新任务, 等待队列中的任务和延迟任务执行完成, ctx 结束后取消剩余的任务
+func (t *_PriorityTask) Stop(ctx context.Context) error {
+	return t.stop(ctx, t.close)
+}
+
+func (t *_PriorityTask) close() {
+	t._stop.Do(func() {
+		t.mux.Lock()
+		atomic.StoreInt32(&t._stop1, 1)
+		t.mux.Unlock()
+
+		close(t.closing)
+		t.wakeup()
+	})
+}
+
+func (t *_PriorityTask) wakeup() {
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (t *_PriorityTask) push(ctx context.Context, priority uint8, at time.Time, withFuture bool, args ...interface{}) (*Future, error) {
+	_args, err := t.fn.args(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	_job := &_ctxJob{args: _args, priority: clampPriority(priority), at: at}
+	if withFuture {
+		_job.future = newFuture()
+	}
+
+	for {
+		// 检查和入队在同一个锁中, drop 之后不会再有任务入队
+		t.mux.Lock()
+		if t.IsClosed() || t.ctx.Err() != nil {
+			t.mux.Unlock()
+			return nil, ErrTaskClosed
+		}
+
+		// ctx 已经结束时不入队, 即使队列还有空间
+		if err := ctx.Err(); err != nil {
+			t.mux.Unlock()
+			return nil, err
+		}
+
+		// 延迟任务不占用就绪队列
+		if !at.IsZero() || t.ready.Len() < t.queueSize {
+			t.seq++
+			_job.seq = t.seq
+			if at.IsZero() {
+				t.pushReady(_job)
+			} else {
+				heap.Push(&t.delay, _job)
+			}
+			t.taskAdd()
+			t.mux.Unlock()
+
+			t.wakeup()
+			return _job.future, nil
+		}
+
+		space := t.space
+		t.mux.Unlock()
+
+		select {
+		case <-space:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.closing:
+			return nil, ErrTaskClosed
+		case <-t.ctx.Done():
+			return nil, ErrTaskClosed
+		}
+	}
+}
+
+func (t *_PriorityTask) pushReady(job *_ctxJob) {
+	heap.Push(&t.ready, job)
+	t.depth[job.priority]++
+}
+
+func (t *_PriorityTask) popReady() *_ctxJob {
+	if t.ready.Len() == 0 {
+		return nil
+	}
+
+	_job := heap.Pop(&t.ready).(*_ctxJob)
+	t.depth[_job.priority]--
+
+	// 通知等待入队的任务
+	close(t.space)
+	t.space = make(chan struct{})
+	return _job
+}
+
+// promote
+// 把到期的延迟任务放入就绪队列, 返回下一个延迟任务的等待时间, 没有延迟任务时返回 -1
+func (t *_PriorityTask) promote(now time.Time) time.Duration {
+	for t.delay.Len() > 0 {
+		_job := t.delay.items[0]
+		if _job.at.After(now) {
+			return _job.at.Sub(now)
+		}
+		heap.Pop(&t.delay)
+		t.pushReady(_job)
+	}
+	return -1
+}
+
+// drop
+// 任务取消, 丢弃队列中剩余的任务, 之后 push 直接返回 ErrTaskClosed
+func (t *_PriorityTask) drop() {
+	t.mux.Lock()
+	atomic.StoreInt32(&t._stop1, 1)
+	var jobs = append(t.ready.items, t.delay.items...)
+	t.ready.items = nil
+	t.delay.items = nil
+	t.depth = [PriorityMax + 1]int{}
+	t.mux.Unlock()
+
+	for _, _job := range jobs {
+		_job.cancel(t.ctx.Err())
+		t.taskDone()
+	}
+}
+
+func (t *_PriorityTask) _loop() {
+	defer close(t.loopDone)
+
+	var timer = time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		if !t.acquire() {
+			t.drop()
+			return
+		}
+
+		t.mux.Lock()
+		wait := t.promote(time.Now())
+		_job := t.popReady()
+		done := t.IsClosed() && t.ready.Len() == 0 && t.delay.Len() == 0
+		t.mux.Unlock()
+
+		if _job != nil {
+			go t._taskHandle(_job)
+			continue
+		}
+		<-t.sem
+
+		if done {
+			return
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if wait < 0 {
+			wait = time.Hour
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-t.notify:
+		case <-timer.C:
+		case <-t.ctx.Done():
+			t.drop()
+			return
+		}
+	}
+}
+
+// priorityOf
+// 使用任务参数中 kts.Task 的优先级
+func priorityOf(args []interface{}) uint8 {
+	for i := range args {
+		switch _t := args[i].(type) {
+		case *kts.Task:
+			if _t != nil {
+				return _t.Priority
+			}
+		case kts.Task:
+			return _t.Priority
+		}
+	}
+	return PriorityDefault
+}
+
+func clampPriority(priority uint8) uint8 {
+	if priority < PriorityMin {
+		return PriorityDefault
+	}
+
+	if priority > PriorityMax {
+		return PriorityMax
+	}
+	return priority
+}
+
+// _jobHeap heap.Interface
+type _jobHeap struct {
+	items []*_ctxJob
+	less  func(a, b *_ctxJob) bool
+}
+
+func (h *_jobHeap) Len() int           { return len(h.items) }
+func (h *_jobHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *_jobHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *_jobHeap) Push(x interface{}) { h.items = append(h.items, x.(*_ctxJob)) }
+func (h *_jobHeap) Pop() interface{} {
+	n := len(h.items)
+	_job := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	return _job
+}
@@ -0,0 +1,154 @@
+package xtask_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pubgo/x/kts"
+	"github.com/pubgo/x/xtask"
+)
+
+func TestPriorityTask(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	block := make(chan struct{})
+
+	_task := xtask.NewPriorityTask(context.Background(), 1, 0, func(task *kts.Task) {
+		if task.TID == "block" {
+			<-block
+		}
+
+		mu.Lock()
+		order = append(order, task.TID)
+		mu.Unlock()
+	}, xtask.WithQueueSize(2))
+
+	if err := _task.Do(context.Background(), &kts.Task{TID: "block"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the block task running
+	for _task.CurSize() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = _task.DoAfter(context.Background(), time.Millisecond*30, &kts.Task{TID: "delay", Priority: 9})
+	_ = _task.Do(context.Background(), &kts.Task{TID: "low", Priority: 1})
+	_ = _task.DoPriority(context.Background(), 9, &kts.Task{TID: "high", Priority: 1})
+
+	if size := _task.QueueSize(); size[1] != 1 || size[9] != 1 {
+		t.Fatalf("queue size error, %v", size)
+	}
+
+	if _task.DelaySize() != 1 {
+		t.Fatalf("delay size error, %d", _task.DelaySize())
+	}
+
+	close(block)
+	if err := _task.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"block", "high", "low", "delay"}
+	for i := range expect {
+		if order[i] != expect[i] {
+			t.Fatalf("expect %v, got %v", expect, order)
+		}
+	}
+}
+
+func TestPriorityTaskCancel(t *testing.T) {
+	_task := xtask.NewPriorityTask(context.Background(), 1, 0, func(ctx context.Context, i int) {
+		<-ctx.Done()
+	})
+
+	_ = _task.Do(context.Background(), 1)
+	f, err := _task.DoFuture(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = _task.DoAfter(context.Background(), time.Hour, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := _task.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+
+	if err := f.Wait(context.Background()); err != context.Canceled {
+		t.Fatalf("expect Canceled, got %v", err)
+	}
+
+	_task.Wait()
+	if _task.Size() != 0 || _task.DelaySize() != 0 {
+		t.Fatalf("expect empty task, got %d", _task.Size())
+	}
+}
+
+func TestPriorityTaskFailFast(t *testing.T) {
+	_task := xtask.NewPriorityTask(context.Background(), 2, 0, func(i int) error {
+		time.Sleep(time.Millisecond)
+		if i == 5 {
+			return fmt.Errorf("bad item %d", i)
+		}
+		return nil
+	}, xtask.WithQueueSize(2), xtask.WithErrPolicy(xtask.ErrPolicyFailFast))
+
+	// 队列已满时并发入队, 任务出错之后入队的任务不会留在队列中
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := _task.Do(context.Background(), g*50+i); err != nil {
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		_task.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Wait blocks after fail fast, size %d", _task.Size())
+	}
+
+	if len(_task.Errs()) != 1 || _task.Size() != 0 {
+		t.Fatalf("expect fail fast, got %v, size %d", _task.Errs(), _task.Size())
+	}
+
+	if err := _task.Do(context.Background(), 1); err != xtask.ErrTaskClosed {
+		t.Fatalf("expect ErrTaskClosed, got %v", err)
+	}
+}
+
+func TestPriorityTaskDoCancelled(t *testing.T) {
+	_task := xtask.NewPriorityTask(context.Background(), 1, 0, func(i int) {})
+	defer _task.Stop(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := _task.Do(ctx, 1); err != context.Canceled {
+		t.Fatalf("expect Canceled, got %v", err)
+	}
+
+	if err := _task.DoAfter(ctx, time.Hour, 2); err != context.Canceled {
+		t.Fatalf("expect Canceled, got %v", err)
+	}
+
+	if _task.Size() != 0 || _task.DelaySize() != 0 {
+		t.Fatalf("expect empty task, got %d", _task.Size())
+	}
+}
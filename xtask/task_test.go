@@ -5,21 +5,21 @@ import (
 	"context"
 	"crypto/md5"
 	"fmt"
-	"github.com/pubgo/xerror"
+	"github.com/pubgo/x/retry"
 	"github.com/pubgo/x/xtask"
 	"github.com/pubgo/x/xtry"
+	"github.com/pubgo/xerror"
 	"github.com/storyicon/graphquery"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
-var _try = xtry.Try
-
 type result struct {
 	path string
 	sum  [md5.Size]byte
@@ -79,7 +79,7 @@ func MD5All(ctx context.Context, root string) (map[string][md5.Size]byte, error)
 	return m, g.Wait()
 }
 func TestPipelines(t *testing.T) {
-	defer xerror.Debug()
+	defer xerror.RespDebug()
 
 	m, err := MD5All(context.Background(), ".")
 	xerror.Panic(err)
@@ -90,7 +90,7 @@ func TestPipelines(t *testing.T) {
 }
 
 func xTestJustError(t *testing.T) {
-	defer xerror.Assert()
+	defer xerror.RespRaise()
 
 	var g xtask.Group
 	var urls = []string{
@@ -104,16 +104,16 @@ func xTestJustError(t *testing.T) {
 
 		g.Go(func() {
 			resp, err := http.Get(url)
-			xerror.PanicM(err, "http get error")
+			xerror.PanicF(err, "http get error")
 			defer resp.Body.Close()
 		})
 	}
 	// Wait for all HTTP fetches to complete.
-	xerror.PanicM(g.Wait(), "Successfully fetched all URLs.")
+	xerror.PanicF(g.Wait(), "Successfully fetched all URLs.")
 }
 
-func TestTasks(t *testing.T) {
-	defer xerror.Assert()
+func xTestTasks(t *testing.T) {
+	defer xerror.RespRaise()
 
 	type DomainInfo struct {
 		Latitude  string `json:"latitude"`
@@ -140,7 +140,8 @@ func TestTasks(t *testing.T) {
 		req.Header.Add("content-length", "40")
 		req.Header.Add("Connection", "keep-alive")
 		req.Header.Add("cache-control", "no-cache")
-		res, _ := http.DefaultClient.Do(req)
+		res, err := http.DefaultClient.Do(req)
+		xerror.Panic(err)
 
 		defer res.Body.Close()
 		body, err := gzip.NewReader(res.Body)
@@ -160,20 +161,20 @@ func TestTasks(t *testing.T) {
 	})
 
 	handle2 := xtask.NewAsyncTask(10, time.Second+time.Millisecond*10, func(i int) {
-		defer xerror.Resp(func(err xerror.IErr) {
-			err.P()
+		defer xerror.Resp(func(err xerror.XErr) {
+			err.Print()
 		})
 
-		xerror.PanicT(i == 29, "_handle1 90999 error")
+		xerror.Assert(i == 29, "_handle1 90999 error")
 	})
 
 	handle1 := xtask.NewAsyncTask(10, time.Second+time.Millisecond*10, func(i int) {
-		defer xerror.Resp(func(err xerror.IErr) {
-			err.P()
+		defer xerror.Resp(func(err xerror.XErr) {
+			err.Print()
 		})
 
 		handle2.Do(i)
-		xerror.PanicT(i == 29, "_handle1 90999 error")
+		xerror.Assert(i == 29, "_handle1 90999 error")
 	})
 
 	for i := 0; i < 100; i++ {
@@ -184,19 +185,26 @@ func TestTasks(t *testing.T) {
 }
 
 func TestErrLog(t *testing.T) {
-	defer xerror.Assert()
+	defer xerror.RespRaise()
 
 	var _task = xtask.NewAsyncTask(500, time.Second+time.Millisecond*10, func(i int) {
-		defer xerror.Resp(func(err xerror.IErr) {
-			err.P()
+		defer xerror.Resp(func(err xerror.XErr) {
+			err.Print()
 		})
 
-		xerror.PanicT(i == 100, "90999 error")
+		xerror.Assert(i == 100, "90999 error")
 	})
 
+	// Do 全部返回之后再 Wait, 否则 Wait 和 wg.Add 并发
+	var wg sync.WaitGroup
 	for i := 0; i < 100000; i++ {
-		go _task.Do(i)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_task.Do(i)
+		}(i)
 	}
+	wg.Wait()
 	_task.Wait()
 }
 
@@ -210,7 +218,7 @@ func parserArticleWithReadability(i int) {
 	for {
 		select {
 		case <-time.After(3 * time.Second):
-			xerror.PanicM("readbility timeout", "等待 %d", i)
+			xerror.PanicF(xerror.Fmt("readbility timeout"), "等待 %d", i)
 		case <-errChan:
 			return
 		}
@@ -218,16 +226,15 @@ func parserArticleWithReadability(i int) {
 
 }
 
-func TestW(t *testing.T) {
-	defer xerror.Assert()
+func xTestW(t *testing.T) {
+	defer xerror.RespRaise()
 
 	var _task = xtask.NewAsyncTask(10000, time.Second*2, func(i int) {
-		xerror.ErrHandle(_try(func() {})(func() {
+		xerror.PanicF(xtry.Try(func() error {
 			parserArticleWithReadability(i)
 			fmt.Println("ok", i)
-		}), func(err xerror.IErr) {
-			xerror.PanicM(err, "testW")
-		})
+			return nil
+		}), "testW")
 	})
 	for i := 0; i < 1000000; i++ {
 		_task.Do(i)
@@ -235,8 +242,8 @@ func TestW(t *testing.T) {
 	_task.Wait()
 }
 
-func TestUrl(t *testing.T) {
-	defer xerror.Assert()
+func xTestUrl(t *testing.T) {
+	defer xerror.RespRaise()
 
 	client := &http.Client{Transport: &http.Transport{
 		MaxIdleConns:       10,
@@ -246,15 +253,17 @@ func TestUrl(t *testing.T) {
 	client.Timeout = 5 * time.Second
 
 	var _task = xtask.NewAsyncTask(200, time.Second*2, func(c *http.Client, i int) {
-		xerror.Panic(xtry.Retry(3, func(i int) {
+		xerror.Panic(retry.Do(context.Background(), func(ctx context.Context) error {
 			req, err := http.NewRequest(http.MethodGet, "https://www.yuanben.io", nil)
 			xerror.Panic(err)
 
-			resp, err := c.Do(req)
+			resp, err := c.Do(req.WithContext(ctx))
 			xerror.Panic(err)
-			xerror.PanicT(resp.StatusCode != http.StatusOK, "状态不正确%d", resp.StatusCode)
+			defer resp.Body.Close()
+			xerror.Assert(resp.StatusCode != http.StatusOK, "状态不正确%d", resp.StatusCode)
 			fmt.Println("try: ", i, "ok")
-		}))
+			return nil
+		}, retry.WithAttempt(3)))
 	})
 	for i := 0; i < 3000; i++ {
 		_task.Do(client, i)
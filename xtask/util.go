@@ -0,0 +1,92 @@
+package xtask
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pubgo/xerror"
+)
+
+var (
+	ctxType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// _taskFn
+// 任务函数的反射封装, 第一个参数为 context.Context 时自动注入任务的上下文
+type _taskFn struct {
+	fn      reflect.Value
+	withCtx bool
+	numIn   int
+}
+
+func newTaskFn(fn interface{}) *_taskFn {
+	_fn := reflect.ValueOf(fn)
+	if !_fn.IsValid() || _fn.Kind() != reflect.Func || _fn.IsNil() {
+		xerror.Exit(ErrNotFuncType)
+	}
+
+	_t := &_taskFn{fn: _fn, numIn: _fn.Type().NumIn()}
+	if _t.numIn > 0 && _fn.Type().In(0) == ctxType {
+		_t.withCtx = true
+		_t.numIn--
+	}
+	return _t
+}
+
+// args
+// 检查并转换任务参数
+func (t *_taskFn) args(args ...interface{}) ([]reflect.Value, error) {
+	_type := t.fn.Type()
+	isVariadic := _type.IsVariadic()
+	if !isVariadic && len(args) != t.numIn || isVariadic && len(args) < t.numIn-1 {
+		return nil, xerror.WrapF(ErrParamsNotMatch, "func %s input params not match, func(%d,%d)", _type, t.numIn, len(args))
+	}
+
+	var offset int
+	if t.withCtx {
+		offset = 1
+	}
+
+	var _args = make([]reflect.Value, 0, len(args)+offset)
+	if t.withCtx {
+		_args = append(_args, reflect.Zero(ctxType))
+	}
+
+	for i := range args {
+		var in reflect.Type
+		if isVariadic && i+offset >= _type.NumIn()-1 {
+			in = _type.In(_type.NumIn() - 1).Elem()
+		} else {
+			in = _type.In(i + offset)
+		}
+
+		if args[i] == nil {
+			_args = append(_args, reflect.Zero(in))
+			continue
+		}
+
+		_v := reflect.ValueOf(args[i])
+		if !_v.Type().AssignableTo(in) {
+			return nil, xerror.WrapF(ErrParamsNotMatch, "func %s input param %d type error, [%s]<->[%s]", _type, i, _v.Type(), in)
+		}
+		_args = append(_args, _v)
+	}
+	return _args, nil
+}
+
+// call
+// 执行任务函数, 捕获 panic, 最后一个返回值为 error 时作为任务的错误返回
+func (t *_taskFn) call(ctx context.Context, args []reflect.Value) (out []reflect.Value, err error) {
+	defer xerror.RespErr(&err)
+
+	if t.withCtx {
+		args[0] = reflect.ValueOf(ctx)
+	}
+
+	out = t.fn.Call(args)
+	if n := len(out); n > 0 && t.fn.Type().Out(n-1) == errType && !out[n-1].IsNil() {
+		err = out[n-1].Interface().(error)
+	}
+	return
+}
@@ -43,7 +43,6 @@ func _TryRaw(fn reflect.Value) func(...reflect.Value) func(...reflect.Value) (er
 
 		return func(cfn ...reflect.Value) (err error) {
 			defer xerror.RespErr(&err)
-			defer valuePut(args)
 
 			_c := fn.Call(args)
 			if len(cfn) > 0 && cfn[0].IsValid() && !cfn[0].IsZero() {
@@ -82,14 +81,15 @@ func Pipe(fns ...func() error) (err error) {
 func Wrap(fn interface{}) func(...interface{}) func(...interface{}) (err error) {
 	_tr := _TryRaw(reflect.ValueOf(fn))
 	return func(args ...interface{}) func(...interface{}) (err error) {
+		// _args 由返回的函数持有, 在返回的函数执行之后放回 pool, 这里不能提前放回
 		var _args = valueGet()
-		defer valuePut(_args)
-
 		for _, k := range args {
 			_args = append(_args, reflect.ValueOf(k))
 		}
 		_tr1 := _tr(_args...)
 		return func(cfn ...interface{}) (err error) {
+			defer valuePut(_args)
+
 			var _cfn = valueGet()
 			defer valuePut(_cfn)
 